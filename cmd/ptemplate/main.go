@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/cemayan/pulumi-template/internal/cli"
+	"os"
+	"os/signal"
+)
+
+// ptemplate runs the program with the Automation API instead of the pulumi CLI commands in Makefile.
+// Ex: go run cmd/ptemplate/main.go up -s datapipeline-firehose-s3-lambda -c configs/datapipeline/firehose/s3/lambda/config.yaml
+func main() {
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := cli.NewCommand().ExecuteContext(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		stop()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	_ "github.com/cemayan/pulumi-template/internal/cloud"
+	"github.com/cemayan/pulumi-template/internal/schema"
+	"github.com/cemayan/pulumi-template/pipeline"
+	"os"
+)
+
+// schema writes the JSON Schema of the config format, it can be given to editors for autocompletion.
+// Ex: go run cmd/schema/main.go -o configs/config.schema.json
+func main() {
+
+	output := flag.String("o", "", "path of the schema file, schema is written to stdout if it is not given")
+	flag.Parse()
+
+	instructions := []string{}
+	for _, instruction := range pipeline.Instructions() {
+		instructions = append(instructions, instruction.Name)
+	}
+
+	content, err := json.MarshalIndent(schema.JSONSchema(instructions), "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "schema encode error: %v\n", err)
+		os.Exit(1)
+	}
+
+	content = append(content, '\n')
+
+	if *output == "" {
+		os.Stdout.Write(content)
+		return
+	}
+
+	if err := os.WriteFile(*output, content, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "schema write error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,40 @@
+// Package components has the Pulumi component resources that pipelines are built from.
+// They can be used by other Go Pulumi programs without the config yaml, ex:
+//
+//	stream, err := components.NewFirehoseStream(ctx, "events", &components.FirehoseStreamArgs{...})
+package components
+
+import (
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"slices"
+	"strings"
+)
+
+// typePrefix is the prefix of the types of the components
+const typePrefix = "ptemplate:"
+
+// MovedFromRoot returns the option that moves resources that were registered at the stack root into the component.
+// Children of the component get an alias to the stack root, so they are not replaced. oldName returns the name that a child had at the stack root,
+// it can be nil if children have the same names.
+func MovedFromRoot(oldName func(name string) string) pulumi.ResourceOption {
+	return pulumi.Transformations([]pulumi.ResourceTransformation{
+		func(args *pulumi.ResourceTransformationArgs) *pulumi.ResourceTransformationResult {
+			if strings.HasPrefix(args.Type, typePrefix) {
+				return nil
+			}
+
+			name := args.Name
+			if oldName != nil {
+				name = oldName(args.Name)
+			}
+
+			alias := pulumi.Alias{Name: pulumi.String(name), NoParent: pulumi.Bool(true)}
+			return &pulumi.ResourceTransformationResult{Props: args.Props, Opts: append(slices.Clone(args.Opts), pulumi.Aliases([]pulumi.Alias{alias}))}
+		},
+	})
+}
+
+// childName returns the name of a child of component name, key is unique in the component
+func childName(name string, key string) string {
+	return name + "-" + key
+}
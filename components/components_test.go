@@ -0,0 +1,211 @@
+package components
+
+import (
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type testSuite struct {
+	suite.Suite
+}
+
+// registered represents a resource that is registered or read on mocks
+type registered struct {
+	parent   string
+	aliases  []string
+	read     bool
+	importId string
+}
+
+// mocks records the parents and the aliases of the resources by name
+type mocks struct {
+	mu        sync.Mutex
+	resources map[string]registered
+}
+
+func (m *mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	return args.Args, nil
+}
+
+func (m *mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r := registered{parent: args.RegisterRPC.GetParent(), importId: args.RegisterRPC.GetImportId()}
+	if args.ReadRPC != nil {
+		r = registered{parent: args.ReadRPC.GetParent(), read: true}
+	}
+	for _, alias := range args.RegisterRPC.GetAliases() {
+		if spec := alias.GetSpec(); spec != nil && spec.GetNoParent() {
+			r.aliases = append(r.aliases, spec.GetName())
+		}
+	}
+
+	m.resources[args.Name] = r
+	return args.Name + "_id", args.Inputs, nil
+}
+
+// run runs program with mocks and returns the registered resources
+func (ts *testSuite) run(program pulumi.RunFunc) map[string]registered {
+	m := &mocks{resources: map[string]registered{}}
+	ts.Require().NoError(pulumi.RunErr(program, pulumi.WithMocks("project", "stack", m)))
+	return m.resources
+}
+
+func (ts *testSuite) TestFirehoseStream() {
+	resources := ts.run(func(ctx *pulumi.Context) error {
+
+		stream, err := NewFirehoseStream(ctx, "events", &FirehoseStreamArgs{
+			Name:        "ptemplate-events",
+			Destination: FirehoseS3,
+			RoleArn:     pulumi.String("arn:aws:iam::1:role/firehose"),
+			S3:          &FirehoseS3Args{BucketArn: pulumi.String("arn:aws:s3:::events"), BufferingSize: 5, PartitionEnabled: true, Prefix: "data/"},
+		})
+		ts.Require().NoError(err)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		pulumi.All(stream.Name, stream.Stream.ExtendedS3Configuration.Prefix()).ApplyT(func(data []interface{}) error {
+			ts.Equal("ptemplate-events", data[0])
+			ts.Equal("data/", *data[1].(*string))
+			wg.Done()
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	})
+
+	ts.True(strings.HasSuffix(resources["events"].parent, "::"+FirehoseStreamType+"::events"))
+}
+
+func (ts *testSuite) TestFirehoseStreamWithoutDestinationArgs() {
+	ts.run(func(ctx *pulumi.Context) error {
+		_, err := NewFirehoseStream(ctx, "events", &FirehoseStreamArgs{Name: "ptemplate-events", Destination: FirehoseRedshift})
+		ts.EqualError(err, `destination must be s3 or redshift with its args, got "redshift"`)
+		return nil
+	})
+}
+
+func (ts *testSuite) TestIngestApi() {
+	resources := ts.run(func(ctx *pulumi.Context) error {
+
+		_, err := NewIngestApi(ctx, "api", &IngestApiArgs{
+			Name:       "ptemplate-api",
+			Stage:      "dev",
+			Deployment: "0",
+			Routes: []IngestApiRouteArgs{{
+				Path: "streams",
+				Integrations: []IngestApiIntegrationArgs{{
+					Name:   "integration",
+					Method: IngestApiMethodArgs{Name: "post", HttpMethod: "POST", Authorization: "NONE", StatusCode: "200"},
+					Type:   "AWS",
+					Uri:    pulumi.String("arn:aws:apigateway:eu-central-1:firehose:action/PutRecord"),
+				}},
+			}},
+		}, MovedFromRoot(func(name string) string {
+			return strings.TrimPrefix(name, "api-")
+		}))
+		ts.Require().NoError(err)
+
+		return nil
+	})
+
+	// Children are named after the component and have aliases to their names at the stack root
+	for name, old := range map[string]string{
+		"api":                           "api",
+		"api-streams":                   "streams",
+		"api-post":                      "post",
+		"api-response_post":             "response_post",
+		"api-integration":               "integration",
+		"api-integration_post_response": "integration_post_response",
+		"api-deploymentResource0":       "deploymentResource0",
+	} {
+		ts.Contains(resources[name].parent, "::"+IngestApiType+"::api", name)
+		ts.Equal([]string{old}, resources[name].aliases, name)
+	}
+}
+
+func (ts *testSuite) TestIngestApiWithoutAuthorizer() {
+	ts.run(func(ctx *pulumi.Context) error {
+		_, err := NewIngestApi(ctx, "api", &IngestApiArgs{
+			Name: "ptemplate-api",
+			Routes: []IngestApiRouteArgs{{
+				Path:         "streams",
+				Integrations: []IngestApiIntegrationArgs{{Name: "integration", Method: IngestApiMethodArgs{Name: "post", HttpMethod: "POST", Authorization: "COGNITO_USER_POOLS"}}},
+			}},
+		})
+		ts.EqualError(err, "route streams: method post: COGNITO_USER_POOLS authorization needs an authorizer")
+		return nil
+	})
+}
+
+func (ts *testSuite) TestPubSubPipeline() {
+	resources := ts.run(func(ctx *pulumi.Context) error {
+
+		pipeline, err := NewPubSubPipeline(ctx, "events", &PubSubPipelineArgs{
+			TopicName:        "ptemplate-events",
+			SubscriptionName: "ptemplate-events-storage",
+			Destination:      PubSubCloudStorage,
+			CloudStorage:     &PubSubCloudStorageArgs{Bucket: pulumi.String("ptemplate-bucket"), MaxDuration: "300s"},
+		})
+		ts.Require().NoError(err)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		pulumi.All(pipeline.TopicName, pipeline.SubscriptionName, pipeline.Subscription.AckDeadlineSeconds).ApplyT(func(data []interface{}) error {
+			ts.Equal("ptemplate-events", data[0])
+			ts.Equal("ptemplate-events-storage", data[1])
+			ts.Equal(20, data[2])
+			wg.Done()
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	})
+
+	ts.Contains(resources["events"].parent, "::"+PubSubPipelineType+"::events")
+	ts.Contains(resources["events-subscription"].parent, "::"+PubSubPipelineType+"::events")
+	ts.Empty(resources["events-subscription"].aliases)
+}
+
+func (ts *testSuite) TestPubSubPipelineWithExistingTopic() {
+	resources := ts.run(func(ctx *pulumi.Context) error {
+		_, err := NewPubSubPipeline(ctx, "events", &PubSubPipelineArgs{
+			SubscriptionName: "ptemplate-events-pull",
+			ExistingTopicId:  "projects/platform/topics/events",
+		})
+		return err
+	})
+
+	// Existing topic is read in the component, the subscription is created
+	ts.True(resources["events"].read)
+	ts.Contains(resources["events"].parent, "::"+PubSubPipelineType+"::events")
+	ts.False(resources["events-subscription"].read)
+}
+
+func (ts *testSuite) TestPubSubPipelineWithImportedTopic() {
+	resources := ts.run(func(ctx *pulumi.Context) error {
+		_, err := NewPubSubPipeline(ctx, "events", &PubSubPipelineArgs{
+			TopicName:        "events",
+			SubscriptionName: "ptemplate-events-pull",
+			ImportTopicId:    "projects/platform/topics/events",
+		})
+		return err
+	})
+
+	ts.False(resources["events"].read)
+	ts.Equal("projects/platform/topics/events", resources["events"].importId)
+	ts.Empty(resources["events-subscription"].importId)
+}
+
+func TestRunComponentsSuite(t *testing.T) {
+	suite.Run(t, &testSuite{})
+}
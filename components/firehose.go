@@ -0,0 +1,179 @@
+package components
+
+import (
+	"fmt"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/kinesis"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// FirehoseStreamType is the type of FirehoseStream
+const FirehoseStreamType = "ptemplate:aws:FirehoseStream"
+
+// Destinations of FirehoseStream
+const (
+	FirehoseS3       = "s3"
+	FirehoseRedshift = "redshift"
+)
+
+// FirehoseStream is a Kinesis Firehose delivery stream that writes to S3 or Redshift, errors are logged to CloudWatch
+type FirehoseStream struct {
+	pulumi.ResourceState
+
+	Stream *kinesis.FirehoseDeliveryStream
+
+	Name pulumi.StringOutput `pulumi:"name"`
+	Arn  pulumi.StringOutput `pulumi:"arn"`
+}
+
+// FirehoseStreamArgs represents the inputs of FirehoseStream, S3 or Redshift must be given according to Destination
+type FirehoseStreamArgs struct {
+	// Name is the name of the delivery stream, log groups are named after it.
+	Name string
+	// Destination is "s3" or "redshift".
+	Destination string
+	// RoleArn is the role that Firehose assumes to write to the destination.
+	RoleArn  pulumi.StringInput
+	S3       *FirehoseS3Args
+	Redshift *FirehoseRedshiftArgs
+	Tags     pulumi.StringMapInput
+}
+
+// FirehoseS3Args represents the S3 destination, records are partitioned by game_name if PartitionEnabled is true
+type FirehoseS3Args struct {
+	BucketArn         pulumi.StringInput
+	BufferingSize     int
+	BufferingInterval int
+	PartitionEnabled  bool
+	// Prefix is the prefix of the objects if PartitionEnabled is true, ex: data/game_name=!{partitionKeyFromQuery:game_name}/
+	Prefix string
+}
+
+// FirehoseRedshiftArgs represents the Redshift destination, records are copied from BucketArn into DataTableName
+type FirehoseRedshiftArgs struct {
+	ClusterJdbcurl pulumi.StringInput
+	Username       string
+	Password       pulumi.StringInput
+	DataTableName  string
+	CopyOptions    string
+	BucketArn      pulumi.StringInput
+}
+
+// NewFirehoseStream creates the delivery stream, it is named name in the component
+func NewFirehoseStream(ctx *pulumi.Context, name string, args *FirehoseStreamArgs, opts ...pulumi.ResourceOption) (*FirehoseStream, error) {
+
+	streamArgs := &kinesis.FirehoseDeliveryStreamArgs{
+		Name: pulumi.String(args.Name),
+		Tags: args.Tags,
+	}
+
+	switch {
+	case args.Destination == FirehoseS3 && args.S3 != nil:
+		streamArgs.Destination = pulumi.String("extended_s3")
+		streamArgs.ExtendedS3Configuration = extendedS3Configuration(args)
+	case args.Destination == FirehoseRedshift && args.Redshift != nil:
+		streamArgs.Destination = pulumi.String("redshift")
+		streamArgs.RedshiftConfiguration = redshiftConfiguration(args)
+	default:
+		return nil, fmt.Errorf("destination must be %v or %v with its args, got %q", FirehoseS3, FirehoseRedshift, args.Destination)
+	}
+
+	component := &FirehoseStream{}
+	if err := ctx.RegisterComponentResource(FirehoseStreamType, name, component, opts...); err != nil {
+		return nil, err
+	}
+
+	stream, err := kinesis.NewFirehoseDeliveryStream(ctx, name, streamArgs, pulumi.Parent(component))
+	if err != nil {
+		return nil, err
+	}
+
+	component.Stream = stream
+	component.Name = stream.Name
+	component.Arn = stream.Arn
+
+	if err := ctx.RegisterResourceOutputs(component, pulumi.Map{"name": stream.Name, "arn": stream.Arn}); err != nil {
+		return nil, err
+	}
+
+	return component, nil
+}
+
+// extendedS3Configuration returns the S3 destination of args, records are de-aggregated and partitioned by game_name if partitioning is enabled
+func extendedS3Configuration(args *FirehoseStreamArgs) *kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationArgs {
+
+	s3ConfArgs := &kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationArgs{
+		RoleArn:           args.RoleArn,
+		BucketArn:         args.S3.BucketArn,
+		BufferingSize:     pulumi.IntPtr(args.S3.BufferingSize),
+		BufferingInterval: pulumi.IntPtr(args.S3.BufferingInterval),
+		CloudwatchLoggingOptions: kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationCloudwatchLoggingOptionsArgs{
+			Enabled:       pulumi.BoolPtr(true),
+			LogGroupName:  pulumi.String(args.Name),
+			LogStreamName: pulumi.String(fmt.Sprintf("%v-stream", args.Name)),
+		},
+
+		ErrorOutputPrefix: pulumi.String("errors/year=!{timestamp:yyyy}/month=!{timestamp:MM}/day=!{timestamp:dd}/hour=!{timestamp:HH}/!{firehose:error-output-type}/"),
+	}
+
+	if args.S3.PartitionEnabled {
+		s3ConfArgs.Prefix = pulumi.String(args.S3.Prefix)
+		s3ConfArgs.DynamicPartitioningConfiguration = &kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationDynamicPartitioningConfigurationArgs{
+			Enabled: pulumi.Bool(true),
+		}
+		s3ConfArgs.ProcessingConfiguration = &kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationProcessingConfigurationArgs{
+			Enabled: pulumi.Bool(true),
+			Processors: kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationProcessingConfigurationProcessorArray{
+				&kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationProcessingConfigurationProcessorArgs{
+					Type: pulumi.String("RecordDeAggregation"),
+					Parameters: kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationProcessingConfigurationProcessorParameterArray{
+						&kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationProcessingConfigurationProcessorParameterArgs{
+							ParameterName:  pulumi.String("SubRecordType"),
+							ParameterValue: pulumi.String("JSON"),
+						},
+					},
+				},
+				&kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationProcessingConfigurationProcessorArgs{
+					Type: pulumi.String("AppendDelimiterToRecord"),
+				},
+				&kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationProcessingConfigurationProcessorArgs{
+					Type: pulumi.String("MetadataExtraction"),
+					Parameters: kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationProcessingConfigurationProcessorParameterArray{
+						&kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationProcessingConfigurationProcessorParameterArgs{
+							ParameterName:  pulumi.String("JsonParsingEngine"),
+							ParameterValue: pulumi.String("JQ-1.6"),
+						},
+						&kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationProcessingConfigurationProcessorParameterArgs{
+							ParameterName:  pulumi.String("MetadataExtractionQuery"),
+							ParameterValue: pulumi.String("{game_name:.game_name}"),
+						},
+					},
+				},
+			},
+		}
+	}
+
+	return s3ConfArgs
+}
+
+// redshiftConfiguration returns the Redshift destination of args
+func redshiftConfiguration(args *FirehoseStreamArgs) *kinesis.FirehoseDeliveryStreamRedshiftConfigurationArgs {
+	return &kinesis.FirehoseDeliveryStreamRedshiftConfigurationArgs{
+		RoleArn:        args.RoleArn,
+		ClusterJdbcurl: args.Redshift.ClusterJdbcurl,
+		Username:       pulumi.String(args.Redshift.Username),
+		CloudwatchLoggingOptions: &kinesis.FirehoseDeliveryStreamRedshiftConfigurationCloudwatchLoggingOptionsArgs{
+			Enabled:       pulumi.Bool(true),
+			LogStreamName: pulumi.String(fmt.Sprintf("%v-kinesis-stream", args.Name)),
+			LogGroupName:  pulumi.String(fmt.Sprintf("%v-kinesis-loggroup", args.Name)),
+		},
+		Password:      args.Redshift.Password,
+		DataTableName: pulumi.String(args.Redshift.DataTableName),
+		CopyOptions:   pulumi.String(args.Redshift.CopyOptions),
+		S3Configuration: &kinesis.FirehoseDeliveryStreamRedshiftConfigurationS3ConfigurationArgs{
+			RoleArn:           args.RoleArn,
+			BucketArn:         args.Redshift.BucketArn,
+			BufferingSize:     pulumi.Int(10),
+			BufferingInterval: pulumi.Int(0),
+		},
+	}
+}
@@ -0,0 +1,234 @@
+package components
+
+import (
+	"fmt"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/apigateway"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// IngestApiType is the type of IngestApi
+const IngestApiType = "ptemplate:aws:IngestApi"
+
+// IngestApi is an API Gateway REST API that proxies requests to AWS services such as Firehose and deploys them to a stage.
+// Children are named after the component, ex: name-<route>, name-<method>, name-response_<method>
+type IngestApi struct {
+	pulumi.ResourceState
+
+	RestApi *apigateway.RestApi
+
+	RestApiId pulumi.StringOutput `pulumi:"restApiId"`
+	// Url is the invoke url of the stage, paths of the routes are added to it.
+	Url pulumi.StringOutput `pulumi:"url"`
+}
+
+// IngestApiArgs represents the inputs of IngestApi
+type IngestApiArgs struct {
+	// Name is the name of the REST API.
+	Name string
+	// Stage is the stage that the API is deployed to.
+	Stage string
+	// Deployment is added to the name of the deployment, changing it deploys the API again.
+	Deployment string
+	// Authorizer is created if it is given, methods with COGNITO_USER_POOLS authorization use it.
+	Authorizer *IngestApiAuthorizerArgs
+	// CredentialsArn is the role that integrations assume to call the services.
+	CredentialsArn pulumi.StringInput
+	Routes         []IngestApiRouteArgs
+	Tags           pulumi.StringMapInput
+}
+
+// IngestApiAuthorizerArgs represents the authorizer of the API, ProviderArns gives the Cognito user pools
+type IngestApiAuthorizerArgs struct {
+	Name         string
+	Type         string
+	ProviderArns pulumi.StringArrayInput
+}
+
+// IngestApiRouteArgs represents a path of the API and its integrations
+type IngestApiRouteArgs struct {
+	Path         string
+	Integrations []IngestApiIntegrationArgs
+}
+
+// IngestApiIntegrationArgs represents a method of a route and the integration that handles it
+type IngestApiIntegrationArgs struct {
+	Name   string
+	Method IngestApiMethodArgs
+	// Type is the integration type such as AWS.
+	Type string
+	// HttpMethod is the method that the integration calls the service with.
+	HttpMethod         string
+	Uri                pulumi.StringInput
+	RequestParameters  map[string]string
+	RequestTemplates   pulumi.StringMapInput
+	ResponseParameters map[string]string
+	ResponseTemplates  map[string]string
+	// DependsOn gives the resources that the integration calls, such as the delivery stream.
+	DependsOn []pulumi.Resource
+}
+
+// IngestApiMethodArgs represents the method of an integration and its response
+type IngestApiMethodArgs struct {
+	Name string
+	// HttpMethod is the method of the route such as POST.
+	HttpMethod string
+	// Authorization is NONE, AWS_IAM or COGNITO_USER_POOLS.
+	Authorization      string
+	StatusCode         string
+	ResponseParameters map[string]bool
+}
+
+// NewIngestApi creates the REST API, its routes and the deployment
+func NewIngestApi(ctx *pulumi.Context, name string, args *IngestApiArgs, opts ...pulumi.ResourceOption) (*IngestApi, error) {
+
+	component := &IngestApi{}
+	if err := ctx.RegisterComponentResource(IngestApiType, name, component, opts...); err != nil {
+		return nil, err
+	}
+
+	parent := pulumi.Parent(component)
+
+	restApi, err := apigateway.NewRestApi(ctx, name, &apigateway.RestApiArgs{
+		Name: pulumi.String(args.Name),
+		Tags: args.Tags,
+	}, parent)
+	if err != nil {
+		return nil, fmt.Errorf("rest api %v: %w", args.Name, err)
+	}
+
+	var authorizer *apigateway.Authorizer
+	if args.Authorizer != nil {
+		authorizer, err = apigateway.NewAuthorizer(ctx, childName(name, args.Authorizer.Name), &apigateway.AuthorizerArgs{
+			RestApi:      restApi,
+			Name:         pulumi.String(args.Authorizer.Name),
+			ProviderArns: args.Authorizer.ProviderArns,
+			Type:         pulumi.String(args.Authorizer.Type),
+		}, parent, pulumi.DependsOn([]pulumi.Resource{restApi}))
+		if err != nil {
+			return nil, fmt.Errorf("authorizer %v: %w", args.Authorizer.Name, err)
+		}
+	}
+
+	resources := []pulumi.Resource{restApi}
+
+	for _, route := range args.Routes {
+
+		resource, err := apigateway.NewResource(ctx, childName(name, route.Path), &apigateway.ResourceArgs{
+			RestApi:  restApi.ID(),
+			ParentId: restApi.RootResourceId,
+			PathPart: pulumi.String(route.Path),
+		}, parent, pulumi.DependsOn([]pulumi.Resource{restApi}))
+		if err != nil {
+			return nil, fmt.Errorf("route %v: %w", route.Path, err)
+		}
+
+		for _, integration := range route.Integrations {
+			created, err := newIngestApiIntegration(ctx, name, restApi, resource, authorizer, args.CredentialsArn, integration, parent)
+			if err != nil {
+				return nil, fmt.Errorf("route %v: %w", route.Path, err)
+			}
+
+			resources = append(resources, created...)
+		}
+	}
+
+	deployment, err := apigateway.NewDeployment(ctx, childName(name, fmt.Sprintf("deploymentResource%v", args.Deployment)), &apigateway.DeploymentArgs{
+		RestApi:   restApi.ID(),
+		StageName: pulumi.String(args.Stage),
+	}, parent, pulumi.DependsOn(resources))
+	if err != nil {
+		return nil, fmt.Errorf("deployment %v: %w", args.Stage, err)
+	}
+
+	component.RestApi = restApi
+	component.RestApiId = restApi.ID().ToStringOutput()
+	component.Url = deployment.InvokeUrl
+
+	if err := ctx.RegisterResourceOutputs(component, pulumi.Map{"restApiId": component.RestApiId, "url": component.Url}); err != nil {
+		return nil, err
+	}
+
+	return component, nil
+}
+
+// newIngestApiIntegration creates the method, integration and their responses on resource, the method and integration responses are returned
+// so the deployment waits for them.
+func newIngestApiIntegration(ctx *pulumi.Context, name string, restApi *apigateway.RestApi, resource *apigateway.Resource, authorizer *apigateway.Authorizer,
+	credentialsArn pulumi.StringInput, integration IngestApiIntegrationArgs, parent pulumi.ResourceOption) ([]pulumi.Resource, error) {
+
+	methodArgs := &apigateway.MethodArgs{
+		RestApi:       restApi.ID(),
+		ResourceId:    resource.ID(),
+		HttpMethod:    pulumi.String(integration.Method.HttpMethod),
+		Authorization: pulumi.String(integration.Method.Authorization),
+	}
+
+	methodDependsOn := []pulumi.Resource{restApi, resource}
+
+	if integration.Method.Authorization == "COGNITO_USER_POOLS" {
+		if authorizer == nil {
+			return nil, fmt.Errorf("method %v: COGNITO_USER_POOLS authorization needs an authorizer", integration.Method.Name)
+		}
+		methodArgs.AuthorizerId = authorizer.ID()
+		methodDependsOn = append(methodDependsOn, authorizer)
+	}
+
+	method, err := apigateway.NewMethod(ctx, childName(name, integration.Method.Name), methodArgs, parent, pulumi.DependsOn(methodDependsOn))
+	if err != nil {
+		return nil, fmt.Errorf("method %v: %w", integration.Method.Name, err)
+	}
+
+	methodResp, err := apigateway.NewMethodResponse(ctx, childName(name, fmt.Sprintf("response_%v", integration.Method.Name)), &apigateway.MethodResponseArgs{
+		RestApi:            restApi.ID(),
+		ResourceId:         resource.ID(),
+		HttpMethod:         method.HttpMethod,
+		StatusCode:         pulumi.String(integration.Method.StatusCode),
+		ResponseParameters: pulumi.ToBoolMap(integration.Method.ResponseParameters),
+	}, parent, pulumi.DependsOn([]pulumi.Resource{restApi, resource}))
+	if err != nil {
+		return nil, fmt.Errorf("method response %v: %w", integration.Method.Name, err)
+	}
+
+	integrationArgs := &apigateway.IntegrationArgs{
+		RestApi:               restApi.ID(),
+		ResourceId:            resource.ID(),
+		HttpMethod:            method.HttpMethod,
+		Type:                  pulumi.String(integration.Type),
+		IntegrationHttpMethod: pulumi.String(integration.HttpMethod),
+		Credentials:           credentialsArn,
+		Uri:                   integration.Uri,
+		RequestTemplates:      integration.RequestTemplates,
+	}
+
+	if len(integration.RequestParameters) > 0 {
+		integrationArgs.RequestParameters = pulumi.ToStringMap(integration.RequestParameters)
+	}
+
+	_integration, err := apigateway.NewIntegration(ctx, childName(name, integration.Name), integrationArgs, parent, pulumi.DependsOn(integration.DependsOn))
+	if err != nil {
+		return nil, fmt.Errorf("integration %v: %w", integration.Name, err)
+	}
+
+	integrationResponseArgs := &apigateway.IntegrationResponseArgs{
+		RestApi:    restApi.ID(),
+		ResourceId: resource.ID(),
+		HttpMethod: methodResp.HttpMethod,
+		StatusCode: methodResp.StatusCode,
+	}
+
+	if len(integration.ResponseTemplates) > 0 {
+		integrationResponseArgs.ResponseTemplates = pulumi.ToStringMap(integration.ResponseTemplates)
+	}
+
+	if len(integration.ResponseParameters) > 0 {
+		integrationResponseArgs.ResponseParameters = pulumi.ToStringMap(integration.ResponseParameters)
+	}
+
+	integrationResp, err := apigateway.NewIntegrationResponse(ctx, childName(name, fmt.Sprintf("integration_%v_response", integration.Method.Name)),
+		integrationResponseArgs, parent, pulumi.DependsOn([]pulumi.Resource{_integration}))
+	if err != nil {
+		return nil, fmt.Errorf("integration response %v: %w", integration.Name, err)
+	}
+
+	return []pulumi.Resource{method, _integration, integrationResp}, nil
+}
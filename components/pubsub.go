@@ -0,0 +1,140 @@
+package components
+
+import (
+	"fmt"
+	"github.com/pulumi/pulumi-gcp/sdk/v7/go/gcp/pubsub"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// PubSubPipelineType is the type of PubSubPipeline
+const PubSubPipelineType = "ptemplate:gcp:PubSubPipeline"
+
+// Destinations of PubSubPipeline
+const (
+	PubSubCloudStorage = "cloudstorage"
+	PubSubBigQuery     = "bigquery"
+)
+
+// defaultAckDeadlineSeconds is the ack deadline of the subscription if it is not given
+const defaultAckDeadlineSeconds = 20
+
+// PubSubPipeline is a Pub/Sub topic and the subscription that writes its messages to Cloud Storage or BigQuery.
+// The topic is named name and the subscription name-subscription in the component.
+type PubSubPipeline struct {
+	pulumi.ResourceState
+
+	Topic        *pubsub.Topic
+	Subscription *pubsub.Subscription
+
+	TopicId          pulumi.StringOutput `pulumi:"topicId"`
+	TopicName        pulumi.StringOutput `pulumi:"topicName"`
+	SubscriptionName pulumi.StringOutput `pulumi:"subscriptionName"`
+}
+
+// PubSubPipelineArgs represents the inputs of PubSubPipeline, CloudStorage or BigQuery must be given if Destination is given
+type PubSubPipelineArgs struct {
+	TopicName        string
+	SubscriptionName string
+	// Destination is "cloudstorage" or "bigquery", messages are pulled from the subscription if it is not given.
+	Destination string
+	// AckDeadlineSeconds is 20 if it is not given.
+	AckDeadlineSeconds int
+	CloudStorage       *PubSubCloudStorageArgs
+	BigQuery           *PubSubBigQueryArgs
+	Labels             pulumi.StringMapInput
+	// DependsOn gives the resources that the subscription writes to, such as the table.
+	DependsOn []pulumi.Resource
+	// ExistingTopicId is the id of a topic that is read instead of created, such as projects/<project>/topics/<name>.
+	ExistingTopicId string
+	// ImportTopicId is the id of a topic that is adopted into the stack, TopicName and Labels must match the topic.
+	ImportTopicId string
+}
+
+// PubSubCloudStorageArgs represents the Cloud Storage destination, MaxDuration is the time that a file is written for such as 300s
+type PubSubCloudStorageArgs struct {
+	Bucket      pulumi.StringInput
+	MaxDuration string
+}
+
+// PubSubBigQueryArgs represents the BigQuery destination, Table is given as project.dataset.table and its schema is used
+type PubSubBigQueryArgs struct {
+	Table pulumi.StringInput
+}
+
+// NewPubSubPipeline creates the topic and the subscription, an existing topic is read or imported if its id is given
+func NewPubSubPipeline(ctx *pulumi.Context, name string, args *PubSubPipelineArgs, opts ...pulumi.ResourceOption) (*PubSubPipeline, error) {
+
+	subsArgs := &pubsub.SubscriptionArgs{
+		Name:               pulumi.String(args.SubscriptionName),
+		AckDeadlineSeconds: pulumi.Int(defaultAckDeadlineSeconds),
+		Labels:             args.Labels,
+	}
+
+	if args.AckDeadlineSeconds > 0 {
+		subsArgs.AckDeadlineSeconds = pulumi.Int(args.AckDeadlineSeconds)
+	}
+
+	switch {
+	case args.Destination == "":
+	case args.Destination == PubSubCloudStorage && args.CloudStorage != nil:
+		subsArgs.CloudStorageConfig = &pubsub.SubscriptionCloudStorageConfigArgs{
+			Bucket:      args.CloudStorage.Bucket,
+			MaxDuration: pulumi.String(args.CloudStorage.MaxDuration),
+		}
+	case args.Destination == PubSubBigQuery && args.BigQuery != nil:
+		subsArgs.BigqueryConfig = &pubsub.SubscriptionBigqueryConfigArgs{
+			Table:          args.BigQuery.Table,
+			UseTableSchema: pulumi.Bool(true),
+		}
+	default:
+		return nil, fmt.Errorf("destination must be %v or %v with its args, got %q", PubSubCloudStorage, PubSubBigQuery, args.Destination)
+	}
+
+	component := &PubSubPipeline{}
+	if err := ctx.RegisterComponentResource(PubSubPipelineType, name, component, opts...); err != nil {
+		return nil, err
+	}
+
+	var topic *pubsub.Topic
+	var err error
+
+	if args.ExistingTopicId != "" {
+		topic, err = pubsub.GetTopic(ctx, name, pulumi.ID(args.ExistingTopicId), nil, pulumi.Parent(component))
+	} else {
+		topicOpts := []pulumi.ResourceOption{pulumi.Parent(component)}
+		if args.ImportTopicId != "" {
+			topicOpts = append(topicOpts, pulumi.Import(pulumi.ID(args.ImportTopicId)))
+		}
+
+		topic, err = pubsub.NewTopic(ctx, name, &pubsub.TopicArgs{
+			Name:   pulumi.String(args.TopicName),
+			Labels: args.Labels,
+		}, topicOpts...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pubsub topic %v: %w", args.TopicName, err)
+	}
+
+	subsArgs.Topic = topic.ID()
+
+	subscription, err := pubsub.NewSubscription(ctx, childName(name, "subscription"), subsArgs, pulumi.Parent(component), pulumi.DependsOn(args.DependsOn))
+	if err != nil {
+		return nil, fmt.Errorf("pubsub subscription %v: %w", args.SubscriptionName, err)
+	}
+
+	component.Topic = topic
+	component.Subscription = subscription
+	component.TopicId = topic.ID().ToStringOutput()
+	component.TopicName = topic.Name
+	component.SubscriptionName = subscription.Name
+
+	if err := ctx.RegisterResourceOutputs(component, pulumi.Map{
+		"topicId":          component.TopicId,
+		"topicName":        component.TopicName,
+		"subscriptionName": component.SubscriptionName,
+	}); err != nil {
+		return nil, err
+	}
+
+	return component, nil
+}
@@ -0,0 +1,26 @@
+const {app} = require('@azure/functions');
+const {EventHubProducerClient} = require('@azure/event-hubs');
+const {DefaultAzureCredential} = require('@azure/identity');
+
+const namespace = process.env.EVENTHUB_NAMESPACE;
+const eventHubName = process.env.EVENTHUB_NAME;
+
+const producer = new EventHubProducerClient(namespace, eventHubName, new DefaultAzureCredential());
+
+app.http('event', {
+    methods: ['POST'],
+    authLevel: 'anonymous',
+    handler: async (request, context) => {
+        const event = await request.json();
+
+        try {
+            await producer.sendBatch([{body: event}]);
+            context.log('Event Hub Successful');
+        } catch (err) {
+            context.error(err);
+            return {status: 500, jsonBody: {message: 'failed!'}};
+        }
+
+        return {status: 200, jsonBody: {message: 'success!'}};
+    }
+});
@@ -0,0 +1,14 @@
+// Package adopt has the helpers of resources that exist before the program.
+// A config block that has existing_id is read with the Get function of the resource, it is not managed by the stack.
+// A config block that has import_id is adopted into the stack with pulumi.Import, its args must match the existing resource.
+package adopt
+
+import "github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+// Options returns opts with the import option if importId is given, the resource is adopted instead of created on the next update
+func Options(importId string, opts ...pulumi.ResourceOption) []pulumi.ResourceOption {
+	if importId == "" {
+		return opts
+	}
+	return append(opts, pulumi.Import(pulumi.ID(importId)))
+}
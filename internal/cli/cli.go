@@ -0,0 +1,211 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/cemayan/pulumi-template/internal/program"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// projectName is the name of the pulumi project in Pulumi.yaml
+const projectName = "pulumi-template"
+
+// options represents the flags that are shared by the commands
+type options struct {
+	stack      string
+	configPath string
+	env        string
+	gcpProject string
+	gcpRegion  string
+	backend    string
+	stackFile  string
+	workDir    string
+	// stderr is where warnings are written, os.Stderr is used if it is nil
+	stderr io.Writer
+}
+
+// NewCommand returns the ptemplate command.
+// Commands run the program inline with the Automation API, so only the pulumi CLI needs to be installed.
+func NewCommand() *cobra.Command {
+
+	opts := &options{stderr: os.Stderr}
+
+	root := &cobra.Command{
+		Use:           "ptemplate",
+		Short:         "Creates data pipelines on AWS, GCP and Azure from a config yaml",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	root.CompletionOptions.DisableDefaultCmd = true
+
+	flags := root.PersistentFlags()
+	flags.StringVarP(&opts.stack, "stack", "s", "", "name of the stack, ex: datapipeline-firehose-s3-lambda, it is required except by plan, graph, policy and cost")
+	flags.StringVarP(&opts.configPath, "config", "c", "", "path of the config yaml, it is set as config:path")
+	flags.StringVar(&opts.env, "env", "", "env overlay of the config such as prod, it is set as config:env")
+	flags.StringVar(&opts.gcpProject, "gcp-project", "", "gcp project, it is set as gcp:project")
+	flags.StringVar(&opts.gcpRegion, "gcp-region", "", "gcp region, it is set as gcp:region")
+	flags.StringVar(&opts.backend, "backend", "", "backend url such as file://.pulumi-state for a local file backend, the backend of pulumi login is used if it is not given")
+	flags.StringVar(&opts.stackFile, "stack-file", "", "stack config file, stacks/Pulumi.<stack>.yaml is used if it exists")
+	flags.StringVar(&opts.workDir, "work-dir", ".", "directory of Pulumi.yaml, stack settings are kept there")
+
+	root.AddCommand(
+		newUpCommand(opts),
+		newPreviewCommand(opts),
+		newDestroyCommand(opts),
+		newRefreshCommand(opts),
+		newOutputsCommand(opts),
+		newStackCommand(opts),
+		newPlanCommand(opts),
+		newGraphCommand(opts),
+		newPolicyCommand(opts),
+		newCostCommand(opts),
+	)
+
+	return root
+}
+
+// stackConfig returns the config of the stack.
+// Values in the stack file are used first, then the flags that are given override them.
+// Secrets such as config:userpass: {secure: ...} are skipped with a warning, they are encrypted for the stack and read from its settings.
+func (o *options) stackConfig() (auto.ConfigMap, error) {
+
+	configs := auto.ConfigMap{}
+
+	path := o.stackFile
+	if path == "" {
+		path = filepath.Join("stacks", fmt.Sprintf("Pulumi.%v.yaml", o.stack))
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil && (o.stackFile != "" || !errors.Is(err, os.ErrNotExist)) {
+		return nil, fmt.Errorf("stack file: %w", err)
+	}
+
+	if err == nil {
+		settings := struct {
+			Config map[string]interface{} `yaml:"config"`
+		}{}
+
+		if err := yaml.Unmarshal(content, &settings); err != nil {
+			return nil, fmt.Errorf("stack file %v: %w", path, err)
+		}
+
+		for key, value := range settings.Config {
+			if value, ok := value.(map[string]interface{}); ok {
+				if _, secure := value["secure"]; secure {
+					o.warn("stack file %v: %v is a secret, it is read from the settings of stack %v, set it with pulumi config set --secret if it is missing", path, key, o.stack)
+					continue
+				}
+				return nil, fmt.Errorf("stack file %v: %v is not a plain value, structured config is not supported", path, key)
+			}
+			configs[key] = auto.ConfigValue{Value: fmt.Sprint(value)}
+		}
+	}
+
+	for key, value := range map[string]string{
+		"config:path": o.configPath,
+		"config:env":  o.env,
+		"gcp:project": o.gcpProject,
+		"gcp:region":  o.gcpRegion,
+	} {
+		if value != "" {
+			configs[key] = auto.ConfigValue{Value: value}
+		}
+	}
+
+	return configs, nil
+}
+
+// warn writes a warning to stderr
+func (o *options) warn(format string, args ...interface{}) {
+	w := o.stderr
+	if w == nil {
+		w = os.Stderr
+	}
+	fmt.Fprintf(w, "warning: "+format+"\n", args...)
+}
+
+// backendURL returns the url of the backend, relative paths of file backends are made absolute and created.
+// Ex: file://.pulumi-state is file:///home/user/pulumi-template/.pulumi-state
+func (o *options) backendURL() (string, error) {
+
+	path, ok := strings.CutPrefix(o.backend, "file://")
+	if !ok || path == "" || path == "~" || strings.HasPrefix(path, "~/") || filepath.IsAbs(path) {
+		return o.backend, nil
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("backend %v: %w", o.backend, err)
+	}
+
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return "", fmt.Errorf("backend %v: %w", o.backend, err)
+	}
+
+	return "file://" + filepath.ToSlash(path), nil
+}
+
+// workspaceOptions returns the options of the workspace that the stack is created or selected in.
+// Secrets of file backends are encrypted with PULUMI_CONFIG_PASSPHRASE like the pulumi CLI does.
+func (o *options) workspaceOptions() ([]auto.LocalWorkspaceOption, error) {
+
+	workspaceOpts := []auto.LocalWorkspaceOption{auto.WorkDir(o.workDir)}
+
+	if o.backend == "" {
+		return workspaceOpts, nil
+	}
+
+	url, err := o.backendURL()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(workspaceOpts, auto.EnvVars(map[string]string{"PULUMI_BACKEND_URL": url})), nil
+}
+
+// selectStack selects the stack and sets its config, the stack is created if create is true.
+func (o *options) selectStack(ctx context.Context, create bool) (auto.Stack, error) {
+
+	if o.stack == "" {
+		return auto.Stack{}, errors.New("stack is required, give it with -s/--stack")
+	}
+
+	configs, err := o.stackConfig()
+	if err != nil {
+		return auto.Stack{}, err
+	}
+
+	workspaceOpts, err := o.workspaceOptions()
+	if err != nil {
+		return auto.Stack{}, err
+	}
+
+	var stack auto.Stack
+	if create {
+		stack, err = auto.NewStackInlineSource(ctx, o.stack, projectName, program.Run, workspaceOpts...)
+	} else {
+		stack, err = auto.SelectStackInlineSource(ctx, o.stack, projectName, program.Run, workspaceOpts...)
+	}
+	if err != nil {
+		if !create && auto.IsSelectStack404Error(err) {
+			return stack, fmt.Errorf("stack %v is not found, create it with ptemplate stack init -s %v", o.stack, o.stack)
+		}
+		return stack, fmt.Errorf("stack %v: %w", o.stack, err)
+	}
+
+	if len(configs) > 0 {
+		if err := stack.SetAllConfig(ctx, configs); err != nil {
+			return stack, fmt.Errorf("stack %v config: %w", o.stack, err)
+		}
+	}
+
+	return stack, nil
+}
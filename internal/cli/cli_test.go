@@ -0,0 +1,249 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/cemayan/pulumi-template/internal/cost"
+	"github.com/cemayan/pulumi-template/internal/plan"
+	"github.com/cemayan/pulumi-template/internal/policy"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type cliTestSuite struct {
+	suite.Suite
+}
+
+// writeStackFile writes given content as a stack file and returns its path
+func (ts *cliTestSuite) writeStackFile(content string) string {
+	path := filepath.Join(ts.T().TempDir(), "Pulumi.game.yaml")
+	ts.Require().NoError(os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func (ts *cliTestSuite) TestStackConfigFlagsOverrideStackFile() {
+	opts := &options{
+		stack:     "game",
+		stackFile: ts.writeStackFile("config:\n  config:path: configs/game.yaml\n  gcp:project: pulumitemplate\n  gcp:region: europe-west3\n"),
+		gcpRegion: "europe-west1",
+		env:       "prod",
+	}
+
+	configs, err := opts.stackConfig()
+
+	ts.NoError(err)
+	ts.Equal(auto.ConfigMap{
+		"config:path": {Value: "configs/game.yaml"},
+		"config:env":  {Value: "prod"},
+		"gcp:project": {Value: "pulumitemplate"},
+		"gcp:region":  {Value: "europe-west1"},
+	}, configs)
+}
+
+func (ts *cliTestSuite) TestStackConfigWithoutStackFile() {
+	// stacks/Pulumi.<stack>.yaml is optional, a stack file that is given must exist
+	configs, err := (&options{stack: "missing", configPath: "configs/game.yaml"}).stackConfig()
+	ts.NoError(err)
+	ts.Equal(auto.ConfigMap{"config:path": {Value: "configs/game.yaml"}}, configs)
+
+	_, err = (&options{stack: "game", stackFile: filepath.Join(ts.T().TempDir(), "Pulumi.game.yaml")}).stackConfig()
+	ts.ErrorContains(err, "stack file: ")
+}
+
+func (ts *cliTestSuite) TestStackConfigSkipsSecrets() {
+	// Secrets are read from the stack settings, other values that are not plain are rejected
+	stderr := &bytes.Buffer{}
+	path := ts.writeStackFile("config:\n  config:path: configs/game.yaml\n  config:userpass:\n    secure: v1:abc\n")
+
+	configs, err := (&options{stack: "game", stackFile: path, stderr: stderr}).stackConfig()
+	ts.NoError(err)
+	ts.Equal(auto.ConfigMap{"config:path": {Value: "configs/game.yaml"}}, configs)
+	ts.Equal("warning: stack file "+path+": config:userpass is a secret, it is read from the settings of stack game, set it with pulumi config set --secret if it is missing\n", stderr.String())
+
+	_, err = (&options{stack: "game", stackFile: ts.writeStackFile("config:\n  config:tags:\n    team: data\n")}).stackConfig()
+	ts.ErrorContains(err, "config:tags is not a plain value, structured config is not supported")
+}
+
+func (ts *cliTestSuite) TestStackConfigOfShippedStacks() {
+	paths, err := filepath.Glob("../../stacks/Pulumi.*.yaml")
+	ts.Require().NoError(err)
+	ts.Require().NotEmpty(paths)
+
+	for _, path := range paths {
+		_, err := (&options{stack: "shipped", stackFile: path, stderr: &bytes.Buffer{}}).stackConfig()
+		ts.NoError(err, path)
+	}
+}
+
+func (ts *cliTestSuite) TestBackendURL() {
+	dir, err := filepath.EvalSymlinks(ts.T().TempDir())
+	ts.Require().NoError(err)
+
+	wd, err := os.Getwd()
+	ts.Require().NoError(err)
+	ts.Require().NoError(os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	url, err := (&options{backend: "file://.pulumi-state"}).backendURL()
+	ts.NoError(err)
+	ts.Equal("file://"+filepath.ToSlash(filepath.Join(dir, ".pulumi-state")), url)
+	ts.DirExists(filepath.Join(dir, ".pulumi-state"))
+
+	for _, backend := range []string{"file://~", "file:///var/pulumi", "s3://ptemplate-state", "https://api.pulumi.com"} {
+		url, err := (&options{backend: backend}).backendURL()
+		ts.NoError(err)
+		ts.Equal(backend, url)
+	}
+}
+
+func (ts *cliTestSuite) TestCommandsCheckFlagsBeforeSelectingStack() {
+	cmd := NewCommand()
+	cmd.SetArgs([]string{"up"})
+	ts.EqualError(cmd.Execute(), "stack is required, give it with -s/--stack")
+
+	cmd = NewCommand()
+	cmd.SetArgs([]string{"destroy", "-s", "game"})
+	ts.EqualError(cmd.Execute(), "destroy deletes every resource of the stack, give --yes to confirm")
+}
+
+func (ts *cliTestSuite) TestOutputValuesMaskSecrets() {
+	outputs := auto.OutputMap{
+		"function_url":        {Value: "https://ptemplate.lambda-url.eu-west-1.on.aws/"},
+		"redshift_secret_arn": {Value: "arn:aws:secretsmanager:eu-west-1:1:secret:redshift", Secret: true},
+	}
+
+	ts.Equal(map[string]interface{}{
+		"function_url":        "https://ptemplate.lambda-url.eu-west-1.on.aws/",
+		"redshift_secret_arn": "[secret]",
+	}, outputValues(outputs, false))
+
+	ts.Equal("arn:aws:secretsmanager:eu-west-1:1:secret:redshift", outputValues(outputs, true)["redshift_secret_arn"])
+}
+
+func (ts *cliTestSuite) TestPlan() {
+	path, err := filepath.Abs("../../configs/datapipeline/firehose/s3/lambda/config.yaml")
+	ts.Require().NoError(err)
+
+	// Plan does not need a stack or a backend
+	var out bytes.Buffer
+	cmd := NewCommand()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"plan", "-c", path, "-o", "json"})
+	ts.Require().NoError(cmd.Execute())
+
+	var p plan.Plan
+	ts.Require().NoError(json.Unmarshal(out.Bytes(), &p))
+	ts.Equal(planStack, p.Stack)
+
+	resources := map[string]plan.Resource{}
+	for _, r := range p.Resources {
+		resources[r.Type] = r
+	}
+
+	ts.Contains(resources, "aws:lambda/functionUrl:FunctionUrl")
+	ts.Equal([]string{resources["aws:lambda/function:Function"].URN}, resources["aws:lambda/functionUrl:FunctionUrl"].Dependencies)
+
+	cmd = NewCommand()
+	cmd.SetArgs([]string{"plan"})
+	ts.EqualError(cmd.Execute(), "config path is required, give it with -c/--config or in the stack file")
+
+	cmd = NewCommand()
+	cmd.SetArgs([]string{"plan", "-c", path, "-o", "yaml"})
+	ts.EqualError(cmd.Execute(), `output must be one of tree, json, got "yaml"`)
+
+	out.Reset()
+	cmd = NewCommand()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"graph", "-c", path, "-o", "dot"})
+	ts.Require().NoError(cmd.Execute())
+	ts.Contains(out.String(), `digraph "pulumi-template-plan" {`)
+
+	cmd = NewCommand()
+	cmd.SetArgs([]string{"graph", "-c", path, "-o", "svg"})
+	ts.EqualError(cmd.Execute(), `output must be one of mermaid, dot, got "svg"`)
+}
+
+func (ts *cliTestSuite) TestPolicyCheck() {
+	path, err := filepath.Abs("../../configs/datapipeline/firehose/s3/lambda/config.yaml")
+	ts.Require().NoError(err)
+
+	// Advisory violations do not fail the check
+	var out bytes.Buffer
+	cmd := NewCommand()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"policy", "check", "-c", path, "-o", "json"})
+	ts.Require().NoError(cmd.Execute())
+
+	var report policy.Report
+	ts.Require().NoError(json.Unmarshal(out.Bytes(), &report))
+
+	violations := map[string]policy.Level{}
+	for _, violation := range report.Violations {
+		violations[violation.Policy] = violation.Level
+	}
+	ts.Equal(map[string]policy.Level{
+		policy.ForceDestroy:         policy.Advisory,
+		policy.WildcardPolicy:       policy.Advisory,
+		policy.FunctionUrlAnyOrigin: policy.Advisory,
+	}, violations)
+
+	// Storage of the prod overlay is not force destroyed, so it passes the mandatory policies of prod
+	cmd = NewCommand()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"policy", "check", "-c", path, "--env", "prod"})
+	ts.NoError(cmd.Execute())
+
+	// Levels are overridden by policies in config
+	strict := filepath.Join(ts.T().TempDir(), "config.yaml")
+	ts.Require().NoError(os.WriteFile(strict, []byte("extends: "+path+"\npolicies:\n  function-url-any-origin: mandatory\n"), 0644))
+
+	cmd = NewCommand()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"policy", "check", "-c", strict})
+	ts.EqualError(cmd.Execute(), "policy check failed with 1 mandatory violations")
+}
+
+func (ts *cliTestSuite) TestPolicyCheckOnAzure() {
+	path, err := filepath.Abs("../../configs/datapipeline/eventhub/storage/apigateway/config.yaml")
+	ts.Require().NoError(err)
+
+	// Function app uses the keys of the storage account that the provider generates, they are planned with placeholders
+	var out bytes.Buffer
+	cmd := NewCommand()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"policy", "check", "-c", path, "--env", "prod"})
+	ts.Require().NoError(cmd.Execute())
+	ts.Equal("no violations\n", out.String())
+}
+
+func (ts *cliTestSuite) TestCost() {
+	path, err := filepath.Abs("../../configs/datapipeline/firehose/redshift/apigateway/config.yaml")
+	ts.Require().NoError(err)
+
+	var out bytes.Buffer
+	cmd := NewCommand()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"cost", "-c", path, "-o", "json"})
+	ts.Require().NoError(cmd.Execute())
+
+	var estimate cost.Estimate
+	ts.Require().NoError(json.Unmarshal(out.Bytes(), &estimate))
+
+	components := map[string]float64{}
+	for _, item := range estimate.Items {
+		components[item.Component] = item.Monthly
+	}
+	ts.Equal(182.5, components["dwh"])
+	ts.Contains(components, "stream")
+
+	cmd = NewCommand()
+	cmd.SetArgs([]string{"cost", "-c", path, "--prices", filepath.Join(ts.T().TempDir(), "prices.yaml")})
+	ts.ErrorContains(cmd.Execute(), "price table: open ")
+}
+
+func TestRunCliSuite(t *testing.T) {
+	suite.Run(t, &cliTestSuite{})
+}
@@ -0,0 +1,171 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optdestroy"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optpreview"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optrefresh"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optup"
+	"github.com/spf13/cobra"
+)
+
+// newUpCommand returns the command that creates or updates the resources of the stack
+func newUpCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "up",
+		Short: "Creates or updates the resources of the stack",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stack, err := opts.selectStack(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+
+			_, err = stack.Up(cmd.Context(), optup.ProgressStreams(cmd.OutOrStdout()), optup.ErrorProgressStreams(cmd.ErrOrStderr()))
+			return err
+		},
+	}
+}
+
+// newPreviewCommand returns the command that shows the changes without applying them
+func newPreviewCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "preview",
+		Short: "Shows the changes that up would make",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stack, err := opts.selectStack(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+
+			_, err = stack.Preview(cmd.Context(), optpreview.ProgressStreams(cmd.OutOrStdout()), optpreview.ErrorProgressStreams(cmd.ErrOrStderr()))
+			return err
+		},
+	}
+}
+
+// newDestroyCommand returns the command that deletes the resources of the stack, --yes must be given since nothing is asked.
+func newDestroyCommand(opts *options) *cobra.Command {
+
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "destroy",
+		Short: "Deletes the resources of the stack",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !yes {
+				return errors.New("destroy deletes every resource of the stack, give --yes to confirm")
+			}
+
+			stack, err := opts.selectStack(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+
+			_, err = stack.Destroy(cmd.Context(), optdestroy.ProgressStreams(cmd.OutOrStdout()), optdestroy.ErrorProgressStreams(cmd.ErrOrStderr()))
+			return err
+		},
+	}
+
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "confirms that the resources are deleted")
+
+	return cmd
+}
+
+// newRefreshCommand returns the command that updates the state from the cloud
+func newRefreshCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "refresh",
+		Short: "Updates the state of the stack from the cloud",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stack, err := opts.selectStack(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+
+			_, err = stack.Refresh(cmd.Context(), optrefresh.ProgressStreams(cmd.OutOrStdout()), optrefresh.ErrorProgressStreams(cmd.ErrOrStderr()))
+			return err
+		},
+	}
+}
+
+// newOutputsCommand returns the command that prints the outputs of the stack as JSON
+func newOutputsCommand(opts *options) *cobra.Command {
+
+	var showSecrets bool
+
+	cmd := &cobra.Command{
+		Use:   "outputs",
+		Short: "Prints the outputs of the stack as JSON",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stack, err := opts.selectStack(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+
+			outputs, err := stack.Outputs(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("stack %v outputs: %w", opts.stack, err)
+			}
+
+			content, err := json.MarshalIndent(outputValues(outputs, showSecrets), "", "  ")
+			if err != nil {
+				return fmt.Errorf("stack %v outputs: %w", opts.stack, err)
+			}
+
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), string(content))
+			return err
+		},
+	}
+
+	cmd.Flags().BoolVar(&showSecrets, "show-secrets", false, "prints the values of secret outputs")
+
+	return cmd
+}
+
+// newStackCommand returns the commands that manage the stack
+func newStackCommand(opts *options) *cobra.Command {
+
+	cmd := &cobra.Command{
+		Use:   "stack",
+		Short: "Manages the stack",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "init",
+		Short: "Creates the stack and sets its config from the stack file and flags",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := opts.selectStack(cmd.Context(), true); err != nil {
+				return err
+			}
+
+			_, err := fmt.Fprintf(cmd.OutOrStdout(), "stack %v is created\n", opts.stack)
+			return err
+		},
+	})
+
+	return cmd
+}
+
+// outputValues returns the values of outputs, secrets are masked unless showSecrets is true
+func outputValues(outputs auto.OutputMap, showSecrets bool) map[string]interface{} {
+
+	values := map[string]interface{}{}
+	for name, output := range outputs {
+		if output.Secret && !showSecrets {
+			values[name] = "[secret]"
+			continue
+		}
+		values[name] = output.Value
+	}
+
+	return values
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/cemayan/pulumi-template/internal/cost"
+	"github.com/cemayan/pulumi-template/internal/interpolate"
+	"github.com/cemayan/pulumi-template/internal/loader"
+	"github.com/spf13/cobra"
+)
+
+// newCostCommand returns the command that prints the monthly cost estimate of the config
+func newCostCommand(opts *options) *cobra.Command {
+
+	var output string
+	var pricesPath string
+
+	cmd := &cobra.Command{
+		Use:   "cost",
+		Short: "Prints a rough monthly cost of the config by component with the local price table, nothing is created and no backend or cloud credentials are needed",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "text" && output != "json" {
+				return fmt.Errorf("output must be one of text, json, got %q", output)
+			}
+
+			prices, err := cost.LoadPrices(pricesPath)
+			if err != nil {
+				return err
+			}
+
+			config, err := opts.planConfig()
+			if err != nil {
+				return err
+			}
+
+			appConfig, _, err := loader.Load(config["config:path"], config["config:env"])
+			if err != nil {
+				return err
+			}
+
+			// Names in the estimate can have references such as ${env}
+			appConfig, err = interpolate.Config(appConfig, map[string]string{"env": appConfig.Env, "stack": opts.planStackName()})
+			if err != nil {
+				return err
+			}
+
+			estimate, err := cost.New(appConfig, prices)
+			if err != nil {
+				return fmt.Errorf("cost: %w", err)
+			}
+
+			if output == "json" {
+				return estimate.WriteJSON(cmd.OutOrStdout())
+			}
+			return estimate.Write(cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "format of the estimate, text or json")
+	cmd.Flags().StringVar(&pricesPath, "prices", "", "path of a price table with the format of internal/cost/prices.yaml, the built-in table is used if it is not given")
+
+	return cmd
+}
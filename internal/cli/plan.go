@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cemayan/pulumi-template/internal/plan"
+	"github.com/cemayan/pulumi-template/internal/program"
+	"github.com/spf13/cobra"
+	"slices"
+	"strings"
+)
+
+// planStack is the stack name of plans if --stack is not given, it is used in ${stack}, names and tags.
+const planStack = "plan"
+
+// planDefaults gives the provider config that is used in plans if it is not given, so a plan does not need a stack file.
+var planDefaults = map[string]string{
+	"gcp:project":    "ptemplate-plan",
+	"gcp:region":     "europe-west3",
+	"azure:location": "westeurope",
+}
+
+// newPlanCommand returns the command that prints the resources of the config without a backend or cloud credentials
+func newPlanCommand(opts *options) *cobra.Command {
+
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Prints the resources that the config creates, nothing is created and no backend or cloud credentials are needed",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "tree" && output != "json" {
+				return fmt.Errorf("output must be one of tree, json, got %q", output)
+			}
+
+			p, err := opts.plan()
+			if err != nil {
+				return err
+			}
+
+			if output == "json" {
+				return p.WriteJSON(cmd.OutOrStdout())
+			}
+			return p.WriteTree(cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "tree", "format of the plan, tree or json")
+
+	return cmd
+}
+
+// newGraphCommand returns the command that prints the resources of the config and their dependencies as a Mermaid or DOT graph
+func newGraphCommand(opts *options) *cobra.Command {
+
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Prints the resources that the config creates and their dependencies as a Mermaid or DOT graph, nothing is created",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !slices.Contains(plan.Formats, output) {
+				return fmt.Errorf("output must be one of %v, got %q", strings.Join(plan.Formats, ", "), output)
+			}
+
+			p, err := opts.plan()
+			if err != nil {
+				return err
+			}
+
+			return p.WriteGraph(cmd.OutOrStdout(), output)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", plan.Mermaid, "format of the graph, mermaid or dot")
+
+	return cmd
+}
+
+// planStackName returns the stack name of plans
+func (o *options) planStackName() string {
+	if o.stack == "" {
+		return planStack
+	}
+	return o.stack
+}
+
+// planConfig returns the pulumi config of plans, config is read from the stack file and flags like the other commands.
+func (o *options) planConfig() (map[string]string, error) {
+
+	opts := *o
+	opts.stack = o.planStackName()
+
+	configs, err := opts.stackConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	config := map[string]string{}
+	for key, value := range planDefaults {
+		config[key] = value
+	}
+	for key, value := range configs {
+		config[key] = value.Value
+	}
+
+	if config["config:path"] == "" {
+		return nil, errors.New("config path is required, give it with -c/--config or in the stack file")
+	}
+
+	return config, nil
+}
+
+// plan runs the program with mocks
+func (o *options) plan() (plan.Plan, error) {
+
+	config, err := o.planConfig()
+	if err != nil {
+		return plan.Plan{}, err
+	}
+
+	p, err := plan.Run(program.Run, projectName, o.planStackName(), config)
+	if err != nil {
+		return p, fmt.Errorf("plan: %w", err)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/cemayan/pulumi-template/internal/loader"
+	"github.com/cemayan/pulumi-template/internal/policy"
+	"github.com/spf13/cobra"
+)
+
+// newPolicyCommand returns the command that groups the policy commands
+func newPolicyCommand(opts *options) *cobra.Command {
+
+	cmd := &cobra.Command{
+		Use:   "policy",
+		Short: "Checks the resources that the config creates against the built-in policies",
+	}
+
+	cmd.AddCommand(newPolicyCheckCommand(opts))
+
+	return cmd
+}
+
+// newPolicyCheckCommand returns the command that checks the plan of the config against the policies.
+// It fails if a mandatory policy is violated, so it can be run in CI before up.
+func newPolicyCheckCommand(opts *options) *cobra.Command {
+
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Prints the violations of the policies, it fails if a mandatory policy is violated. Nothing is created and no backend or cloud credentials are needed",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "text" && output != "json" {
+				return fmt.Errorf("output must be one of text, json, got %q", output)
+			}
+
+			config, err := opts.planConfig()
+			if err != nil {
+				return err
+			}
+
+			p, err := opts.plan()
+			if err != nil {
+				return err
+			}
+
+			// Levels are overridden by the policies in config and its env overlays
+			appConfig, _, err := loader.Load(config["config:path"], config["config:env"])
+			if err != nil {
+				return err
+			}
+
+			report := policy.Check(p, policy.LevelsOf(appConfig))
+
+			if output == "json" {
+				err = report.WriteJSON(cmd.OutOrStdout())
+			} else {
+				err = report.Write(cmd.OutOrStdout())
+			}
+			if err != nil {
+				return err
+			}
+
+			return report.Err()
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "format of the violations, text or json")
+
+	return cmd
+}
@@ -1,7 +1,18 @@
 package aws
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/cemayan/pulumi-template/components"
+	"github.com/cemayan/pulumi-template/internal/adopt"
+	"github.com/cemayan/pulumi-template/internal/contract"
+	"github.com/cemayan/pulumi-template/internal/interpolate"
+	"github.com/cemayan/pulumi-template/internal/lifecycle"
+	"github.com/cemayan/pulumi-template/internal/naming"
+	"github.com/cemayan/pulumi-template/internal/secret"
+	"github.com/cemayan/pulumi-template/internal/stackref"
+	"github.com/cemayan/pulumi-template/internal/tags"
 	"github.com/cemayan/pulumi-template/types"
 	"github.com/pulumi/pulumi-archive/sdk/go/archive"
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/apigateway"
@@ -12,23 +23,45 @@ import (
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/redshift"
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/redshiftdata"
 	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/s3"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/secretsmanager"
+	"github.com/pulumi/pulumi-random/sdk/v4/go/random"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+	"maps"
 	"strings"
 )
 
+// rolePrefixes gives the prefix of the role names in iam.roles by what the roles are used for, case of the names is ignored.
+// Ex: kinesis_firehose_role is assumed by Firehose
+var rolePrefixes = map[string]string{
+	"apigateway":     "api_gateway",
+	"firehose":       "kinesis_firehose",
+	"redshift":       "redshift_service",
+	"lambdafirehose": "lambda_firehose",
+}
+
+// passwordSpecials gives the special characters of generated passwords, Redshift does not accept /, @, ", ', \ and space
+const passwordSpecials = "!#$%^&*()-_=+[]{}<>:?"
+
 // Aws represents the AWS related resources and configs
 type Aws struct {
 	ctx               *pulumi.Context
+	outputs           pulumi.Map
+	contract          contract.Values
+	references        map[string]pulumi.StringOutput
+	stacks            *stackref.Resolver
+	namer             *naming.Namer
+	tags              pulumi.StringMap
 	config            types.Config
 	roles             map[string]*iam.Role
 	s3Bucket          *s3.Bucket
 	firehose          *kinesis.FirehoseDeliveryStream
 	redshift          *redshift.Cluster
+	redshiftPassword  pulumi.StringOutput
 	redshiftStatement *redshiftdata.Statement
+	function          *lambda.Function
 	restApi           *apigateway.RestApi
 	userPool          *cognito.UserPool
-	authorizer        *apigateway.Authorizer
 }
 
 // CreateIdentityManagement creates a identity platform on AWS with Cognito
@@ -36,26 +69,72 @@ type Aws struct {
 // In order to take id_token from Cognito you need to sign in first with defined user below (Click View hosted UI button on AWS or
 // https://<your domain>/oauth2/authorize?response_type=code&client_id=<your app client id>&redirect_uri=<your callback url>)
 // After that you are able to be request to endpoint that created by API Gateway.
+// An existing user pool is read if authorizer.user_pool.existing_id is given, or adopted if authorizer.user_pool.import_id is given.
 func (a *Aws) CreateIdentityManagement() error {
 
-	userPool, err := cognito.NewUserPool(a.ctx, a.config.Authorizer.UserPool.Name, &cognito.UserPoolArgs{
-		Name: pulumi.String(a.config.Authorizer.UserPool.Name),
-	})
+	userPoolName, err := a.namer.Name(naming.CognitoUserPool, a.config.Authorizer.UserPool.Name)
+	if err != nil {
+		return fmt.Errorf("user pool %v: %w", a.config.Authorizer.UserPool.Name, err)
+	}
+
+	clientName, err := a.namer.Name(naming.CognitoUserPoolClient, a.config.Authorizer.UserPool.UserClient.Name)
+	if err != nil {
+		return fmt.Errorf("user pool client %v: %w", a.config.Authorizer.UserPool.UserClient.Name, err)
+	}
+
+	var userPool *cognito.UserPool
+
+	if a.config.Authorizer.UserPool.ExistingId != "" {
+		userPool, err = cognito.GetUserPool(a.ctx, a.config.LogicalName(a.config.Authorizer.UserPool.Name), pulumi.ID(a.config.Authorizer.UserPool.ExistingId), nil)
+	} else {
+		userPool, err = cognito.NewUserPool(a.ctx, a.config.LogicalName(a.config.Authorizer.UserPool.Name), &cognito.UserPoolArgs{
+			Name: pulumi.String(userPoolName),
+			Tags: a.tags,
+		}, adopt.Options(a.config.Authorizer.UserPool.ImportId, lifecycle.Options(a.config, lifecycle.Authorizer)...)...)
+	}
+	if err != nil {
+		return fmt.Errorf("user pool %v: %w", a.config.Authorizer.UserPool.Name, err)
+	}
+
+	// If config:userpass is not given, password is generated and kept in Secrets Manager
+	userPass, err := config.TrySecret(a.ctx, "config:userpass")
+	if err != nil {
+		var userPassSecret *secretsmanager.Secret
+		userPass, userPassSecret, err = a.generatePassword(a.config.Authorizer.UserPool.User.Username, fmt.Sprintf("%v-user", userPoolName))
+		if err != nil {
+			return fmt.Errorf("user pool user %v: %w", a.config.Authorizer.UserPool.User.Username, err)
+		}
 
-	conf := config.New(a.ctx, "config")
-	userPass := conf.RequireSecret("userpass")
+		a.outputs["cognito_user_secret_arn"] = userPassSecret.Arn
+	}
 
-	_, err = cognito.NewUser(a.ctx, "user", &cognito.UserArgs{
+	_, err = cognito.NewUser(a.ctx, a.config.LogicalName("user"), &cognito.UserArgs{
 		Enabled:    pulumi.Bool(true),
 		Password:   userPass,
 		UserPoolId: userPool.ID(),
 		Username:   pulumi.String(a.config.Authorizer.UserPool.User.Username),
 	}, pulumi.DependsOn([]pulumi.Resource{userPool}))
+	if err != nil {
+		return fmt.Errorf("user pool user %v: %w", a.config.Authorizer.UserPool.User.Username, err)
+	}
 
-	userPoolDomain, err := cognito.NewUserPoolDomain(a.ctx, a.config.Authorizer.UserPool.UserDomain.Name, &cognito.UserPoolDomainArgs{
-		Domain:     pulumi.String(a.config.Authorizer.UserPool.UserDomain.Name),
-		UserPoolId: userPool.ID(),
-	}, pulumi.DependsOn([]pulumi.Resource{userPool}))
+	// A user pool has one domain, so the domain of an existing user pool does not have to be given
+	if a.config.Authorizer.UserPool.UserDomain.Name != "" {
+		domainName, err := a.namer.Name(naming.CognitoDomain, a.config.Authorizer.UserPool.UserDomain.Name)
+		if err != nil {
+			return fmt.Errorf("user pool domain %v: %w", a.config.Authorizer.UserPool.UserDomain.Name, err)
+		}
+
+		userPoolDomain, err := cognito.NewUserPoolDomain(a.ctx, a.config.LogicalName(a.config.Authorizer.UserPool.UserDomain.Name), &cognito.UserPoolDomainArgs{
+			Domain:     pulumi.String(domainName),
+			UserPoolId: userPool.ID(),
+		}, pulumi.DependsOn([]pulumi.Resource{userPool}))
+		if err != nil {
+			return fmt.Errorf("user pool domain %v: %w", a.config.Authorizer.UserPool.UserDomain.Name, err)
+		}
+
+		a.outputs["CognitoUserPoolDomain"] = userPoolDomain.Domain
+	}
 
 	allowedScopes := pulumi.StringArray{}
 
@@ -81,8 +160,8 @@ func (a *Aws) CreateIdentityManagement() error {
 		exAuthFlows = append(exAuthFlows, pulumi.String(v))
 	}
 
-	userPoolClient, err := cognito.NewUserPoolClient(a.ctx, a.config.Authorizer.UserPool.UserClient.Name, &cognito.UserPoolClientArgs{
-		Name:               pulumi.String(a.config.Authorizer.UserPool.UserClient.Name),
+	userPoolClient, err := cognito.NewUserPoolClient(a.ctx, a.config.LogicalName(a.config.Authorizer.UserPool.UserClient.Name), &cognito.UserPoolClientArgs{
+		Name:               pulumi.String(clientName),
 		UserPoolId:         userPool.ID(),
 		ExplicitAuthFlows:  exAuthFlows,
 		CallbackUrls:       callbackUrls,
@@ -90,11 +169,16 @@ func (a *Aws) CreateIdentityManagement() error {
 		AllowedOauthFlows:  allowedFlows,
 		GenerateSecret:     pulumi.Bool(true),
 	}, pulumi.DependsOn([]pulumi.Resource{userPool}))
+	if err != nil {
+		return fmt.Errorf("user pool client %v: %w", a.config.Authorizer.UserPool.UserClient.Name, err)
+	}
 
 	a.userPool = userPool
+	a.references["outputs.userpool.id"] = userPool.ID().ToStringOutput()
+	a.references["outputs.userpool.arn"] = userPool.Arn
 
-	_, err = cognito.NewManagedUserPoolClient(a.ctx, "managed", &cognito.ManagedUserPoolClientArgs{
-		NamePattern:                pulumi.String(a.config.Authorizer.UserPool.UserClient.Name),
+	_, err = cognito.NewManagedUserPoolClient(a.ctx, a.config.LogicalName("managed"), &cognito.ManagedUserPoolClientArgs{
+		NamePattern:                pulumi.String(clientName),
 		AllowedOauthFlows:          allowedFlows,
 		AllowedOauthScopes:         allowedScopes,
 		CallbackUrls:               callbackUrls,
@@ -102,17 +186,20 @@ func (a *Aws) CreateIdentityManagement() error {
 		SupportedIdentityProviders: pulumi.StringArray{pulumi.String("COGNITO")},
 		UserPoolId:                 userPool.ID(),
 	}, pulumi.DependsOn([]pulumi.Resource{userPool}))
+	if err != nil {
+		return fmt.Errorf("managed user pool client %v: %w", a.config.Authorizer.UserPool.UserClient.Name, err)
+	}
 
-	a.ctx.Export("CognitoUserPoolClientId", userPoolClient.ID())
-	a.ctx.Export("CognitoUserPoolDomain", userPoolDomain.Domain)
-	return err
+	a.outputs["CognitoUserPoolClientId"] = userPoolClient.ID()
+	a.contract.AuthIssuer = pulumi.Sprintf("https://%v", userPool.Endpoint)
+	a.contract.AuthClientId = userPoolClient.ID().ToStringOutput()
+	return nil
 }
 
 // CreateFunction creates Lambda function according to given values
 // You can upload zip to lambda
 // If lambda function creation operation is successful URL will be exported.
 func (a *Aws) CreateFunction() error {
-	var err error
 
 	// With lookup file it will be captured the changes.
 	arch, err := archive.LookupFile(a.ctx, &archive.LookupFileArgs{
@@ -120,24 +207,48 @@ func (a *Aws) CreateFunction() error {
 		SourceDir:  pulumi.StringRef(a.config.Function.Build.Source.Zip),
 		OutputPath: a.config.Function.Build.Source.OutputPath,
 	}, nil)
+	if err != nil {
+		return fmt.Errorf("archive %v: %w", a.config.Function.Build.Source.Zip, err)
+	}
+
+	functionName, err := a.namer.Name(naming.LambdaFunction, a.config.Function.Name)
+	if err != nil {
+		return fmt.Errorf("lambda function %v: %w", a.config.Function.Name, err)
+	}
+
+	role, err := a.role("lambdafirehose")
+	if err != nil {
+		return fmt.Errorf("lambda function %v: %w", a.config.Function.Name, err)
+	}
 
 	envMap := pulumi.StringMap{}
 	envMap["firehose_name"] = a.firehose.Name
 
-	_func, err := lambda.NewFunction(a.ctx, a.config.Function.Name, &lambda.FunctionArgs{
+	for k, v := range a.config.Function.Build.Envs {
+		envMap[k], err = a.resolve(v)
+		if err != nil {
+			return fmt.Errorf("lambda function %v env %v: %w", a.config.Function.Name, k, err)
+		}
+	}
+
+	_func, err := lambda.NewFunction(a.ctx, a.config.LogicalName(a.config.Function.Name), &lambda.FunctionArgs{
 		Code:           pulumi.NewFileArchive(a.config.Function.Build.Source.OutputPath),
-		Name:           pulumi.String(a.config.Function.Name),
-		Role:           a.roles["lambdafirehose"].Arn,
+		Name:           pulumi.String(functionName),
+		Role:           role.Arn,
 		Handler:        pulumi.String(a.config.Function.Build.Handler),
 		Runtime:        pulumi.String(a.config.Function.Build.Runtime),
+		Tags:           a.tags,
 		SourceCodeHash: pulumi.String(arch.OutputBase64sha256),
 		Environment: &lambda.FunctionEnvironmentArgs{
 			Variables: envMap,
 		},
-	}, pulumi.DependsOn([]pulumi.Resource{a.firehose}))
+	}, lifecycle.Options(a.config, lifecycle.Function, pulumi.DependsOn([]pulumi.Resource{a.firehose}))...)
+	if err != nil {
+		return fmt.Errorf("lambda function %v: %w", a.config.Function.Name, err)
+	}
 
-	functionUrl, err := lambda.NewFunctionUrl(a.ctx, fmt.Sprintf("%v-url", a.config.Function.Name), &lambda.FunctionUrlArgs{
-		FunctionName:      pulumi.String(a.config.Function.Name),
+	functionUrl, err := lambda.NewFunctionUrl(a.ctx, a.config.LogicalName(fmt.Sprintf("%v-url", a.config.Function.Name)), &lambda.FunctionUrlArgs{
+		FunctionName:      _func.Name,
 		AuthorizationType: pulumi.String(a.config.Function.Auth),
 		Cors: &lambda.FunctionUrlCorsArgs{
 			AllowCredentials: pulumi.Bool(true),
@@ -149,57 +260,193 @@ func (a *Aws) CreateFunction() error {
 			},
 		},
 	}, pulumi.DependsOn([]pulumi.Resource{_func}))
+	if err != nil {
+		return fmt.Errorf("lambda function url %v-url: %w", a.config.Function.Name, err)
+	}
 
-	a.ctx.Export("lambda_function_url", functionUrl.FunctionUrl)
+	a.function = _func
+	a.references["outputs.lambda.arn"] = _func.Arn
+	a.outputs["lambda_function_url"] = functionUrl.FunctionUrl
 
-	return err
+	// API Gateway is the ingest endpoint if it is created
+	if a.contract.IngestUrl == nil {
+		a.contract.IngestUrl = functionUrl.FunctionUrl
+		a.contract.IngestAuthType = pulumi.String(contract.AuthType(a.config.Function.Auth))
+	}
+
+	return nil
 }
 
 // CreateDWH creates Redshift cluster according to given values
-// Initial SQL will be executed
+// Initial SQL will be executed on the created cluster if it is given
+// An existing cluster is read if dwh.redshift.existing_id is given, or adopted if dwh.redshift.import_id is given.
 func (a *Aws) CreateDWH() error {
 
-	cluster, err := redshift.NewCluster(a.ctx, a.config.Dwh.Redshift.Identifier, &redshift.ClusterArgs{
-		ClusterIdentifier:  pulumi.String(a.config.Dwh.Redshift.Identifier),
-		DatabaseName:       pulumi.String(a.config.Dwh.Redshift.DbName),
-		MasterUsername:     pulumi.String(a.config.Dwh.Redshift.MasterUser),
-		MasterPassword:     pulumi.String(a.config.Dwh.Redshift.MasterPass),
-		NodeType:           pulumi.String(a.config.Dwh.Redshift.NodeType),
-		NumberOfNodes:      pulumi.Int(a.config.Dwh.Redshift.NumberOfNodes),
-		ClusterType:        pulumi.String(a.config.Dwh.Redshift.ClusterType),
-		SkipFinalSnapshot:  pulumi.Bool(a.config.Dwh.Redshift.SkipSnapshot),
-		PubliclyAccessible: pulumi.Bool(a.config.Dwh.Redshift.PublicAccess),
-		IamRoles: pulumi.StringArray{
-			a.roles["redshift"].Arn,
-		},
-	}, pulumi.DependsOn([]pulumi.Resource{a.roles["redshift"]}))
+	identifier, err := a.namer.Name(naming.RedshiftCluster, a.config.Dwh.Redshift.Identifier)
+	if err != nil {
+		return fmt.Errorf("redshift cluster %v: %w", a.config.Dwh.Redshift.Identifier, err)
+	}
+
+	var cluster *redshift.Cluster
+	var masterPass pulumi.StringOutput
+
+	if a.config.Dwh.Redshift.ExistingId != "" {
+		// Password of an existing cluster is not known, Firehose needs stream.redshift_conf.password
+		cluster, err = redshift.GetCluster(a.ctx, a.config.LogicalName(a.config.Dwh.Redshift.Identifier), pulumi.ID(a.config.Dwh.Redshift.ExistingId), nil)
+		if err != nil {
+			return fmt.Errorf("redshift cluster %v: %w", a.config.Dwh.Redshift.Identifier, err)
+		}
+	} else {
+		masterPass, err = a.masterPassword(identifier)
+		if err != nil {
+			return fmt.Errorf("redshift cluster %v master password: %w", a.config.Dwh.Redshift.Identifier, err)
+		}
+
+		role, err := a.role("redshift")
+		if err != nil {
+			return fmt.Errorf("redshift cluster %v: %w", a.config.Dwh.Redshift.Identifier, err)
+		}
+
+		cluster, err = redshift.NewCluster(a.ctx, a.config.LogicalName(a.config.Dwh.Redshift.Identifier), &redshift.ClusterArgs{
+			ClusterIdentifier:  pulumi.String(identifier),
+			DatabaseName:       pulumi.String(a.config.Dwh.Redshift.DbName),
+			MasterUsername:     pulumi.String(a.config.Dwh.Redshift.MasterUser),
+			MasterPassword:     masterPass,
+			NodeType:           pulumi.String(a.config.Dwh.Redshift.NodeType),
+			NumberOfNodes:      pulumi.Int(a.config.Dwh.Redshift.NumberOfNodes),
+			ClusterType:        pulumi.String(a.config.Dwh.Redshift.ClusterType),
+			SkipFinalSnapshot:  pulumi.Bool(a.config.Dwh.Redshift.SkipSnapshot),
+			PubliclyAccessible: pulumi.Bool(a.config.Dwh.Redshift.PublicAccess),
+			Tags:               a.tags,
+			IamRoles: pulumi.StringArray{
+				role.Arn,
+			},
+		}, adopt.Options(a.config.Dwh.Redshift.ImportId,
+			lifecycle.Options(a.config, lifecycle.Dwh, pulumi.DependsOn([]pulumi.Resource{role}))...)...)
+		if err != nil {
+			return fmt.Errorf("redshift cluster %v: %w", a.config.Dwh.Redshift.Identifier, err)
+		}
+	}
 
 	a.redshift = cluster
+	a.redshiftPassword = masterPass
+	a.references["outputs.redshift.endpoint"] = cluster.Endpoint
+
+	// Existing clusters are only read, their tables are not changed
+	if a.config.Dwh.Redshift.ExistingId != "" || a.config.Dwh.Redshift.Sql == "" {
+		return nil
+	}
 
 	statement := &redshiftdata.StatementArgs{
-		ClusterIdentifier: pulumi.String(a.config.Dwh.Redshift.Identifier),
+		ClusterIdentifier: cluster.ClusterIdentifier,
 		Database:          pulumi.String(a.config.Dwh.Redshift.DbName),
 		DbUser:            pulumi.String(a.config.Dwh.Redshift.MasterUser),
 		Sql:               pulumi.String(a.config.Dwh.Redshift.Sql),
 	}
 
-	newStatement, err := redshiftdata.NewStatement(a.ctx, "statement", statement, pulumi.DependsOn([]pulumi.Resource{cluster}))
+	newStatement, err := redshiftdata.NewStatement(a.ctx, a.config.LogicalName("statement"), statement, pulumi.DependsOn([]pulumi.Resource{cluster}))
+	if err != nil {
+		return fmt.Errorf("redshift statement on %v: %w", a.config.Dwh.Redshift.Identifier, err)
+	}
+
 	a.redshiftStatement = newStatement
-	return err
+	return nil
+}
+
+// masterPassword returns the master password of the cluster with given identifier.
+// If it is not given, password is generated and kept in Secrets Manager, Firehose uses the same password. It must be given to adopt a cluster.
+// Otherwise it can be a secret reference such as secret://config/redshift_pass, it is kept as secret in state.
+func (a *Aws) masterPassword(identifier string) (pulumi.StringOutput, error) {
+
+	if a.config.Dwh.Redshift.MasterPass != "" {
+		return secret.Resolve(a.ctx, a.config.Dwh.Redshift.MasterPass)
+	}
+
+	// Generated password would replace the password of an adopted cluster
+	if a.config.Dwh.Redshift.ImportId != "" {
+		return pulumi.StringOutput{}, errors.New("dwh.redshift.master_pass must be given to adopt a cluster")
+	}
+
+	masterPass, masterPassSecret, err := a.generatePassword(a.config.Dwh.Redshift.MasterUser, fmt.Sprintf("%v-master", identifier))
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+
+	a.outputs["redshift_secret_arn"] = masterPassSecret.Arn
+	return masterPass, nil
+}
+
+// generatePassword generates a password with the random provider and stores it in Secrets Manager with username.
+// Secret value is a JSON document such as {"username": "master", "password": "..."}, name gives the prefix of the secret name.
+func (a *Aws) generatePassword(username string, name string) (pulumi.StringOutput, *secretsmanager.Secret, error) {
+
+	password, err := random.NewRandomPassword(a.ctx, a.config.LogicalName(fmt.Sprintf("%v-password", name)), &random.RandomPasswordArgs{
+		Length:          pulumi.Int(32),
+		MinUpper:        pulumi.Int(1),
+		MinLower:        pulumi.Int(1),
+		MinNumeric:      pulumi.Int(1),
+		MinSpecial:      pulumi.Int(1),
+		OverrideSpecial: pulumi.String(passwordSpecials),
+	})
+	if err != nil {
+		return pulumi.StringOutput{}, nil, fmt.Errorf("random password %v-password: %w", name, err)
+	}
+
+	// Name prefix is used since a deleted secret keeps its name during the recovery window
+	secret_, err := secretsmanager.NewSecret(a.ctx, a.config.LogicalName(fmt.Sprintf("%v-secret", name)), &secretsmanager.SecretArgs{
+		NamePrefix:  pulumi.String(fmt.Sprintf("%v-", name)),
+		Description: pulumi.String(fmt.Sprintf("password of %v, generated by pulumi-template", username)),
+		Tags:        a.tags,
+	})
+	if err != nil {
+		return pulumi.StringOutput{}, nil, fmt.Errorf("secret %v-secret: %w", name, err)
+	}
+
+	_, err = secretsmanager.NewSecretVersion(a.ctx, a.config.LogicalName(fmt.Sprintf("%v-secret-version", name)), &secretsmanager.SecretVersionArgs{
+		SecretId: secret_.ID(),
+		SecretString: password.Result.ApplyT(func(result string) (string, error) {
+			document, err := json.Marshal(map[string]string{"username": username, "password": result})
+			return string(document), err
+		}).(pulumi.StringOutput),
+	}, pulumi.DependsOn([]pulumi.Resource{secret_}))
+	if err != nil {
+		return pulumi.StringOutput{}, nil, fmt.Errorf("secret version %v-secret-version: %w", name, err)
+	}
+
+	return pulumi.ToSecret(password.Result).(pulumi.StringOutput), secret_, nil
 }
 
 // CreateStorage created S3 according to given values
 // ForceDestroy may set the false if files are important.
+// An existing bucket is read if storage.existing_id is given, or adopted if storage.import_id is given.
 func (a *Aws) CreateStorage() error {
 
-	s3Bucket, err := s3.NewBucket(a.ctx, a.config.Storage.Name, &s3.BucketArgs{
-		Bucket:       pulumi.String(a.config.Storage.Name),
-		ForceDestroy: pulumi.Bool(a.config.Storage.ForceDestroy),
-	})
+	bucketName, err := a.namer.Name(naming.S3Bucket, a.config.Storage.Name)
+	if err != nil {
+		return fmt.Errorf("s3 bucket %v: %w", a.config.Storage.Name, err)
+	}
+
+	var s3Bucket *s3.Bucket
+
+	if a.config.Storage.ExistingId != "" {
+		s3Bucket, err = s3.GetBucket(a.ctx, a.config.LogicalName(a.config.Storage.Name), pulumi.ID(a.config.Storage.ExistingId), nil)
+	} else {
+		s3Bucket, err = s3.NewBucket(a.ctx, a.config.LogicalName(a.config.Storage.Name), &s3.BucketArgs{
+			Bucket:       pulumi.String(bucketName),
+			ForceDestroy: pulumi.Bool(a.config.Storage.ForceDestroy),
+			Tags:         a.tags,
+		}, adopt.Options(a.config.Storage.ImportId, lifecycle.Options(a.config, lifecycle.Storage)...)...)
+	}
+	if err != nil {
+		return fmt.Errorf("s3 bucket %v: %w", a.config.Storage.Name, err)
+	}
 
 	a.s3Bucket = s3Bucket
+	a.references["outputs.s3.bucket"] = s3Bucket.Bucket
+	a.references["outputs.s3.arn"] = s3Bucket.Arn
+	a.contract.StorageUri = pulumi.Sprintf("s3://%v", s3Bucket.Bucket)
 
-	return err
+	return nil
 }
 
 // CreateStream creates Kinesis Firehose according to given values
@@ -207,102 +454,88 @@ func (a *Aws) CreateStorage() error {
 // Also you can set partition enabled config for S3.(You can set the prefix)
 func (a *Aws) CreateStream() error {
 
-	args := &kinesis.FirehoseDeliveryStreamArgs{
-		Name: pulumi.String(a.config.Stream.Name),
+	streamName, err := a.namer.Name(naming.FirehoseStream, a.config.Stream.Name)
+	if err != nil {
+		return fmt.Errorf("firehose delivery stream %v: %w", a.config.Stream.Name, err)
 	}
 
-	resources := []pulumi.Resource{}
+	roleArn, err := a.arn(a.config.Stream.RoleArn, a.roleArn("firehose"))
+	if err != nil {
+		return fmt.Errorf("firehose delivery stream %v role: %w", a.config.Stream.Name, err)
+	}
 
-	if a.config.Stream.Destination == "s3" {
-		s3ConfArgs := &kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationArgs{
-			RoleArn:           a.roles["firehose"].Arn,
-			BucketArn:         a.s3Bucket.Arn,
-			BufferingSize:     pulumi.IntPtr(a.config.Stream.S3Conf.BufferingSize),
-			BufferingInterval: pulumi.IntPtr(a.config.Stream.S3Conf.BufferingInterval),
-			CloudwatchLoggingOptions: kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationCloudwatchLoggingOptionsArgs{
-				Enabled:       pulumi.BoolPtr(true),
-				LogGroupName:  pulumi.String(a.config.Stream.Name),
-				LogStreamName: pulumi.String(fmt.Sprintf("%v-stream", a.config.Stream.Name)),
-			},
+	bucketArn, err := a.arn(a.config.Stream.S3Conf.BucketArn, func() (pulumi.StringOutput, error) { return a.s3Bucket.Arn, nil })
+	if err != nil {
+		return fmt.Errorf("firehose delivery stream %v bucket: %w", a.config.Stream.Name, err)
+	}
+
+	args := &components.FirehoseStreamArgs{
+		Name:        streamName,
+		Destination: a.config.Stream.Destination,
+		RoleArn:     roleArn,
+		Tags:        a.tags,
+	}
 
-			ErrorOutputPrefix: pulumi.String("errors/year=!{timestamp:yyyy}/month=!{timestamp:MM}/day=!{timestamp:dd}/hour=!{timestamp:HH}/!{firehose:error-output-type}/"),
+	if a.config.Stream.Destination == components.FirehoseS3 {
+		args.S3 = &components.FirehoseS3Args{
+			BucketArn:         bucketArn,
+			BufferingSize:     a.config.Stream.S3Conf.BufferingSize,
+			BufferingInterval: a.config.Stream.S3Conf.BufferingInterval,
+			PartitionEnabled:  a.config.Stream.S3Conf.PartitionEnabled,
+			Prefix:            a.config.Stream.S3Conf.S3Prefix,
 		}
+	} else if a.config.Stream.Destination == components.FirehoseRedshift {
 
-		if a.config.Stream.S3Conf.PartitionEnabled {
-			s3ConfArgs.Prefix = pulumi.String(a.config.Stream.S3Conf.S3Prefix)
-			s3ConfArgs.DynamicPartitioningConfiguration = &kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationDynamicPartitioningConfigurationArgs{
-				Enabled: pulumi.Bool(true),
+		// Password of the cluster is used with its master user if it is not given
+		password, username := a.redshiftPassword, a.config.Stream.RedshiftConf.Username
+		if a.config.Stream.RedshiftConf.Password == "" && a.config.Dwh.Redshift.ExistingId != "" {
+			return fmt.Errorf("firehose delivery stream %v: redshift password must be given in stream.redshift_conf.password for an existing cluster", a.config.Stream.Name)
+		}
+		if a.config.Stream.RedshiftConf.Password == "" {
+			if username != "" && username != a.config.Dwh.Redshift.MasterUser {
+				return fmt.Errorf("firehose delivery stream %v: redshift user %v is not the master user %v, its password must be given in stream.redshift_conf.password",
+					a.config.Stream.Name, username, a.config.Dwh.Redshift.MasterUser)
 			}
-			s3ConfArgs.ProcessingConfiguration = &kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationProcessingConfigurationArgs{
-				Enabled: pulumi.Bool(true),
-				Processors: kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationProcessingConfigurationProcessorArray{
-					&kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationProcessingConfigurationProcessorArgs{
-						Type: pulumi.String("RecordDeAggregation"),
-						Parameters: kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationProcessingConfigurationProcessorParameterArray{
-							&kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationProcessingConfigurationProcessorParameterArgs{
-								ParameterName:  pulumi.String("SubRecordType"),
-								ParameterValue: pulumi.String("JSON"),
-							},
-						},
-					},
-					&kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationProcessingConfigurationProcessorArgs{
-						Type: pulumi.String("AppendDelimiterToRecord"),
-					},
-					&kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationProcessingConfigurationProcessorArgs{
-						Type: pulumi.String("MetadataExtraction"),
-						Parameters: kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationProcessingConfigurationProcessorParameterArray{
-							&kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationProcessingConfigurationProcessorParameterArgs{
-								ParameterName:  pulumi.String("JsonParsingEngine"),
-								ParameterValue: pulumi.String("JQ-1.6"),
-							},
-							&kinesis.FirehoseDeliveryStreamExtendedS3ConfigurationProcessingConfigurationProcessorParameterArgs{
-								ParameterName:  pulumi.String("MetadataExtractionQuery"),
-								ParameterValue: pulumi.String("{game_name:.game_name}"),
-							},
-						},
-					},
-				},
+			username = a.config.Dwh.Redshift.MasterUser
+		} else {
+			var err error
+			password, err = secret.Resolve(a.ctx, a.config.Stream.RedshiftConf.Password)
+			if err != nil {
+				return fmt.Errorf("firehose delivery stream %v redshift password: %w", a.config.Stream.Name, err)
 			}
 		}
 
-		args.Destination = pulumi.String("extended_s3")
-		args.ExtendedS3Configuration = s3ConfArgs
-		resources = append(resources, a.s3Bucket)
-	} else if a.config.Stream.Destination == "redshift" {
-
-		redshiftConf := &kinesis.FirehoseDeliveryStreamRedshiftConfigurationArgs{
-			RoleArn: a.roles["firehose"].Arn,
+		args.Redshift = &components.FirehoseRedshiftArgs{
 			ClusterJdbcurl: pulumi.All(a.redshift.Endpoint, a.redshift.DatabaseName).ApplyT(func(_args []interface{}) (string, error) {
 				endpoint := _args[0].(string)
 				databaseName := _args[1].(string)
 				return fmt.Sprintf("jdbc:redshift://%v/%v", endpoint, databaseName), nil
 			}).(pulumi.StringOutput),
-			Username: pulumi.String(a.config.Stream.RedshiftConf.Username),
-			CloudwatchLoggingOptions: &kinesis.FirehoseDeliveryStreamRedshiftConfigurationCloudwatchLoggingOptionsArgs{
-				Enabled:       pulumi.Bool(true),
-				LogStreamName: pulumi.String(fmt.Sprintf("%v-kinesis-stream", a.config.Stream.Name)),
-				LogGroupName:  pulumi.String(fmt.Sprintf("%v-kinesis-loggroup", a.config.Stream.Name)),
-			},
-			Password:      pulumi.String(a.config.Stream.RedshiftConf.Password),
-			DataTableName: pulumi.String(a.config.Stream.RedshiftConf.DataTableName),
-			CopyOptions:   pulumi.String(a.config.Stream.RedshiftConf.CopyOptions),
-			S3Configuration: &kinesis.FirehoseDeliveryStreamRedshiftConfigurationS3ConfigurationArgs{
-				RoleArn:           a.roles["firehose"].Arn,
-				BucketArn:         a.s3Bucket.Arn,
-				BufferingSize:     pulumi.Int(10),
-				BufferingInterval: pulumi.Int(0),
-			},
+			Username:      username,
+			Password:      password,
+			DataTableName: a.config.Stream.RedshiftConf.DataTableName,
+			CopyOptions:   a.config.Stream.RedshiftConf.CopyOptions,
+			BucketArn:     bucketArn,
 		}
+	}
 
-		args.RedshiftConfiguration = redshiftConf
-		args.Destination = pulumi.String("redshift")
-		resources = append(resources, a.redshift)
+	stream, err := components.NewFirehoseStream(a.ctx, a.config.LogicalName(a.config.Stream.Name), args, components.MovedFromRoot(nil),
+		lifecycle.Children(a.config, lifecycle.Stream))
+	if err != nil {
+		return fmt.Errorf("firehose delivery stream %v: %w", a.config.Stream.Name, err)
 	}
 
-	firehose_, err := kinesis.NewFirehoseDeliveryStream(a.ctx, a.config.Stream.Name, args, pulumi.DependsOn(resources))
+	firehose_ := stream.Stream
 	a.firehose = firehose_
+	a.references["outputs.firehose.name"] = firehose_.Name
+	a.references["outputs.firehose.arn"] = firehose_.Arn
+	a.contract.StreamId = firehose_.Arn
 
-	return err
+	if a.config.Stream.Destination == components.FirehoseRedshift {
+		a.contract.DwhTable = pulumi.Sprintf("%v.%v", a.redshift.DatabaseName, a.config.Stream.RedshiftConf.DataTableName)
+	}
+
+	return nil
 }
 
 // CreateApiGateway create API Gateway according to given values
@@ -310,157 +543,131 @@ func (a *Aws) CreateStream() error {
 // Example can be found in configs/datapipeline/redshift/apigateway/config.yaml
 func (a *Aws) CreateApiGateway() error {
 
-	restApi, err := apigateway.NewRestApi(a.ctx, a.config.APIGateway.Name, &apigateway.RestApiArgs{
-		Name: pulumi.String(a.config.APIGateway.Name),
-	})
-
-	a.restApi = restApi
+	apiName, err := a.namer.Name(naming.RestApi, a.config.APIGateway.Name)
+	if err != nil {
+		return fmt.Errorf("rest api %v: %w", a.config.APIGateway.Name, err)
+	}
 
-	if a.userPool != nil {
-		authorizer, _ := apigateway.NewAuthorizer(a.ctx, a.config.Authorizer.Name, &apigateway.AuthorizerArgs{
-			RestApi: restApi,
-			Name:    pulumi.String(a.config.Authorizer.Name),
-			ProviderArns: pulumi.StringArray{
-				a.userPool.Arn,
-			},
-			Type: pulumi.String(a.config.Authorizer.Type),
-		}, pulumi.DependsOn([]pulumi.Resource{restApi, a.userPool}))
+	credentialsArn, err := a.arn(a.config.APIGateway.RoleArn, a.roleArn("apigateway"))
+	if err != nil {
+		return fmt.Errorf("rest api %v role: %w", a.config.APIGateway.Name, err)
+	}
 
-		a.authorizer = authorizer
+	args := &components.IngestApiArgs{
+		Name:           apiName,
+		Stage:          a.config.APIGateway.Stage,
+		Deployment:     fmt.Sprint(a.config.APIGateway.DeploymentId),
+		CredentialsArn: credentialsArn,
+		Tags:           a.tags,
 	}
 
-	resources := []pulumi.Resource{}
-	resources = append(resources, restApi)
+	// An existing user pool is used instead of the created one if its ARN is given
+	if a.config.Authorizer.UserPool.Arn != "" || a.userPool != nil {
+		userPoolArn, err := a.arn(a.config.Authorizer.UserPool.Arn, func() (pulumi.StringOutput, error) { return a.userPool.Arn, nil })
+		if err != nil {
+			return fmt.Errorf("rest api %v user pool: %w", a.config.APIGateway.Name, err)
+		}
+
+		args.Authorizer = &components.IngestApiAuthorizerArgs{
+			Name:         a.config.Authorizer.Name,
+			Type:         a.config.Authorizer.Type,
+			ProviderArns: pulumi.StringArray{userPoolArn},
+		}
+	}
 
 	for _, route := range a.config.APIGateway.Routes {
 
-		resource, _ := apigateway.NewResource(a.ctx, route.Name, &apigateway.ResourceArgs{
-			RestApi:  restApi.ID(),
-			ParentId: restApi.RootResourceId,
-			PathPart: pulumi.String(route.Name),
-		}, pulumi.DependsOn([]pulumi.Resource{restApi}))
+		routeArgs := components.IngestApiRouteArgs{Path: route.Name}
 
 		for _, integration := range route.Integrations {
-			var err error
 
-			methodArgs := &apigateway.MethodArgs{
-				RestApi:    restApi.ID(),
-				ResourceId: resource.ID(),
-				HttpMethod: pulumi.String(integration.Method.Type),
+			uri, err := a.resolve(integration.URI)
+			if err != nil {
+				return fmt.Errorf("integration %v uri on route %v: %w", integration.Name, route.Name, err)
 			}
 
-			if integration.Method.Auth == "COGNITO_USER_POOLS" {
-				methodArgs.AuthorizerId = a.authorizer.ID()
+			integrationArgs := components.IngestApiIntegrationArgs{
+				Name: integration.Name,
+				Method: components.IngestApiMethodArgs{
+					Name:               integration.Method.Name,
+					HttpMethod:         integration.Method.Type,
+					Authorization:      integration.Method.Auth,
+					StatusCode:         integration.Method.Response.StatusCode,
+					ResponseParameters: map[string]bool{},
+				},
+				Type:               integration.Type,
+				HttpMethod:         integration.HTTPMethod,
+				Uri:                uri,
+				RequestParameters:  map[string]string{},
+				ResponseParameters: map[string]string{},
+				ResponseTemplates:  map[string]string{},
 			}
 
-			methodArgs.Authorization = pulumi.String(integration.Method.Auth)
-
-			method, err := apigateway.NewMethod(a.ctx, integration.Method.Name, methodArgs, pulumi.DependsOn([]pulumi.Resource{restApi, resource, a.authorizer}))
-
-			resources = append(resources, method)
-
-			respParamMap := pulumi.BoolMap{}
-
 			for _, respPar := range integration.Method.Response.ResponseParams {
-				respParamMap[respPar.Key] = pulumi.Bool(respPar.Val)
-			}
-
-			methodResp, err := apigateway.NewMethodResponse(a.ctx, fmt.Sprintf("response_%v", integration.Method.Name), &apigateway.MethodResponseArgs{
-				RestApi:            restApi.ID(),
-				ResourceId:         resource.ID(),
-				HttpMethod:         method.HttpMethod,
-				StatusCode:         pulumi.String(integration.Method.Response.StatusCode),
-				ResponseParameters: respParamMap,
-			}, pulumi.DependsOn([]pulumi.Resource{restApi, resource}))
-
-			integrationArgs := &apigateway.IntegrationArgs{
-				RestApi:               restApi.ID(),
-				ResourceId:            resource.ID(),
-				HttpMethod:            method.HttpMethod,
-				Type:                  pulumi.String(integration.Type),
-				IntegrationHttpMethod: pulumi.String(integration.HTTPMethod),
-				Credentials:           a.roles["apigateway"].Arn,
-				Uri:                   pulumi.String(integration.URI),
+				integrationArgs.Method.ResponseParameters[respPar.Key] = respPar.Val
 			}
 
-			if len(integration.ReqParams) > 0 {
-				reqParamMap := pulumi.StringMap{}
-
-				for _, respPar := range integration.ReqParams {
-					reqParamMap[respPar.Key] = pulumi.String(respPar.Val)
-				}
-				integrationArgs.RequestParameters = reqParamMap
+			for _, reqPar := range integration.ReqParams {
+				integrationArgs.RequestParameters[reqPar.Key] = reqPar.Val
 			}
 
 			if len(integration.ReqTemplate) > 0 {
 				reqTemplateMap := pulumi.StringMap{}
 
 				for _, reqTemp := range integration.ReqTemplate {
-					reqTemplateMap[reqTemp.Key] = pulumi.String(reqTemp.Val)
+					reqTemplateMap[reqTemp.Key], err = a.resolve(reqTemp.Val)
+					if err != nil {
+						return fmt.Errorf("integration %v request template %v on route %v: %w", integration.Name, reqTemp.Key, route.Name, err)
+					}
 				}
 				integrationArgs.RequestTemplates = reqTemplateMap
 			}
 
-			integrationDependsOn := []pulumi.Resource{}
-
-			if strings.Contains(integration.URI, "firehose:action") {
-				integrationDependsOn = append(integrationDependsOn, a.firehose)
-			}
-
-			_integration, err := apigateway.NewIntegration(a.ctx, integration.Name, integrationArgs,
-				pulumi.DependsOn(integrationDependsOn))
-
-			resources = append(resources, _integration)
-
-			integrationResponseArgs := &apigateway.IntegrationResponseArgs{
-				RestApi:    restApi.ID(),
-				ResourceId: resource.ID(),
-				HttpMethod: methodResp.HttpMethod,
-				StatusCode: methodResp.StatusCode,
+			for _, respTemp := range integration.ResTemplate {
+				integrationArgs.ResponseTemplates[respTemp.Key] = respTemp.Val
 			}
 
-			if len(integration.ResTemplate) > 0 {
-				resTemplateMap := pulumi.StringMap{}
-
-				for _, respTemp := range integration.ResTemplate {
-					resTemplateMap[respTemp.Key] = pulumi.String(respTemp.Val)
-				}
-
-				integrationResponseArgs.ResponseTemplates = resTemplateMap
+			for _, respParam := range integration.ResParams {
+				integrationArgs.ResponseParameters[respParam.Key] = respParam.Val
 			}
 
-			if len(integration.ResParams) > 0 {
-				resParamMap := pulumi.StringMap{}
-
-				for _, respParam := range integration.ResParams {
-					resParamMap[respParam.Key] = pulumi.String(respParam.Val)
-				}
-
-				integrationResponseArgs.ResponseParameters = resParamMap
+			if strings.Contains(integration.URI, "firehose:action") {
+				integrationArgs.DependsOn = append(integrationArgs.DependsOn, a.firehose)
 			}
 
-			integrationResp, err := apigateway.NewIntegrationResponse(a.ctx,
-				fmt.Sprintf("integration_%v_response", integration.Method.Name),
-				integrationResponseArgs, pulumi.DependsOn([]pulumi.Resource{_integration}))
+			routeArgs.Integrations = append(routeArgs.Integrations, integrationArgs)
+		}
 
-			resources = append(resources, integrationResp)
+		args.Routes = append(args.Routes, routeArgs)
+	}
 
-			if err != nil {
-				a.ctx.Log.Error(err.Error(), nil)
-			}
+	// Children were registered at the stack root with the names in the config before, ex: streams instead of <api>-streams
+	name := a.config.LogicalName(a.config.APIGateway.Name)
+	oldName := func(child string) string {
+		if key, ok := strings.CutPrefix(child, name+"-"); ok {
+			return a.config.LogicalName(key)
 		}
+		return child
+	}
+
+	api, err := components.NewIngestApi(a.ctx, name, args, components.MovedFromRoot(oldName), lifecycle.Children(a.config, lifecycle.ApiGateway))
+	if err != nil {
+		return fmt.Errorf("rest api %v: %w", a.config.APIGateway.Name, err)
 	}
 
-	deployment, err := apigateway.NewDeployment(a.ctx, fmt.Sprintf("deploymentResource%v", a.config.APIGateway.DeploymentId), &apigateway.DeploymentArgs{
-		RestApi:   restApi.ID(),
-		StageName: pulumi.String(a.config.APIGateway.Stage),
-	}, pulumi.DependsOn(resources))
+	a.restApi = api.RestApi
 
-	a.ctx.Export("apiGatewayUrl", deployment.InvokeUrl)
+	a.outputs["apiGatewayUrl"] = api.Url
 
-	return err
+	if route, method, ok := contract.IngestRoute(a.config.APIGateway.Routes); ok {
+		a.contract.IngestUrl = pulumi.Sprintf("%v/%v", api.Url, route.Name)
+		a.contract.IngestAuthType = pulumi.String(contract.AuthType(method.Auth))
+	}
+
+	return nil
 }
 
+// CreateVpc does nothing since resources are created in the default VPC of the account
 func (a *Aws) CreateVpc() error {
 	return nil
 }
@@ -473,9 +680,15 @@ func (a *Aws) ConfigureIAM() error {
 
 	for _, role := range a.config.Iam.Roles {
 
+		roleName, err := a.namer.Name(naming.IamRole, role.Name)
+		if err != nil {
+			return fmt.Errorf("role %v: %w", role.Name, err)
+		}
+
 		args := &iam.RoleArgs{
-			Name:                pulumi.String(role.Name),
+			Name:                pulumi.String(roleName),
 			ForceDetachPolicies: pulumi.Bool(role.ForceDetachPolicies),
+			Tags:                a.tags,
 		}
 
 		if role.AssumePolicy != "" {
@@ -483,37 +696,192 @@ func (a *Aws) ConfigureIAM() error {
 		}
 
 		if role.InlinePolicy != "" {
+			policy, err := a.resolve(role.InlinePolicy)
+			if err != nil {
+				return fmt.Errorf("role %v inline policy: %w", role.Name, err)
+			}
+
 			args.InlinePolicies = iam.RoleInlinePolicyArray{
 				&iam.RoleInlinePolicyArgs{
-					Name:   pulumi.String(fmt.Sprintf("%s-inline-role", role.Name)),
-					Policy: pulumi.String(role.InlinePolicy),
+					Name:   pulumi.String(fmt.Sprintf("%s-inline-role", roleName)),
+					Policy: policy,
 				},
 			}
 		}
 
-		iamRole, err := iam.NewRole(a.ctx, role.Name, args)
+		iamRole, err := iam.NewRole(a.ctx, a.config.LogicalName(role.Name), args)
+		if err != nil {
+			return fmt.Errorf("role %v: %w", role.Name, err)
+		}
+
+		for use, prefix := range rolePrefixes {
+			if strings.HasPrefix(strings.ToLower(role.Name), prefix) {
+				a.roles[use] = iamRole
+			}
+		}
+	}
+
+	return nil
+}
+
+// Dependencies returns what each instruction needs and produces on AWS according to given values.
+// Ex: createStream needs the S3 bucket and also the Redshift cluster if destination is "redshift".
+// Resources that are given by their ARN, such as stream.s3Config.bucket_arn, are not needed.
+func Dependencies(config types.Config) map[string]types.Dependency {
+
+	stream := types.Dependency{
+		Produces: []types.Resource{types.StreamResource},
+	}
+
+	if config.Stream.RoleArn == "" {
+		stream.Needs = append(stream.Needs, types.RolesResource)
+	}
+
+	if config.Stream.S3Conf.BucketArn == "" {
+		stream.Needs = append(stream.Needs, types.StorageResource)
+	}
+
+	if config.Stream.Destination == "redshift" {
+		stream.Needs = append(stream.Needs, types.DwhResource)
+	}
+
+	// An existing cluster is read, so the role of the cluster is not needed
+	dwh := types.Dependency{
+		Produces: []types.Resource{types.DwhResource},
+	}
+
+	if config.Dwh.Redshift.ExistingId == "" {
+		dwh.Needs = append(dwh.Needs, types.RolesResource)
+	}
+
+	apiGateway := types.Dependency{}
+
+	if config.APIGateway.RoleArn == "" {
+		apiGateway.Needs = append(apiGateway.Needs, types.RolesResource)
+	}
+
+	needsUserPool, needsStream := false, false
 
-		if strings.HasPrefix(strings.ToLower(role.Name), "api_gateway") {
-			a.roles["apigateway"] = iamRole
-		} else if strings.HasPrefix(strings.ToLower(role.Name), "kinesis_firehose") {
-			a.roles["firehose"] = iamRole
-		} else if strings.HasPrefix(strings.ToLower(role.Name), "redshift_service") {
-			a.roles["redshift"] = iamRole
-		} else if strings.HasPrefix(strings.ToLower(role.Name), "lambda_firehose") {
-			a.roles["lambdafirehose"] = iamRole
+	for _, route := range config.APIGateway.Routes {
+		for _, integration := range route.Integrations {
+			if integration.Method.Auth == "COGNITO_USER_POOLS" {
+				needsUserPool = true
+			}
+			if strings.Contains(integration.URI, "firehose:action") {
+				needsStream = true
+			}
 		}
+	}
+
+	if needsUserPool && config.Authorizer.UserPool.Arn == "" {
+		apiGateway.Needs = append(apiGateway.Needs, types.UserPoolResource)
+	}
+
+	if needsStream {
+		apiGateway.Needs = append(apiGateway.Needs, types.StreamResource)
+	}
+
+	return map[string]types.Dependency{
+		"configureIAM":  {Produces: []types.Resource{types.RolesResource}},
+		"createVpc":     {},
+		"createStorage": {Produces: []types.Resource{types.StorageResource}},
+		"createDWH":     dwh,
+		"createStream":  stream,
+		"createFunction": {
+			Needs:    []types.Resource{types.RolesResource, types.StreamResource},
+			Produces: []types.Resource{types.FunctionResource},
+		},
+		"createIdentityManagement": {Produces: []types.Resource{types.UserPoolResource}},
+		"createApiGateway":         apiGateway,
+	}
+}
 
+// resolve resolves the references to outputs of created resources in value, such as ${outputs.firehose.name}
+func (a *Aws) resolve(value string) (pulumi.StringOutput, error) {
+	return interpolate.Output(value, a.references)
+}
+
+// arn returns the ARN that value gives, value can be a stack reference. created returns the ARN of the resource that is created in the program,
+// it is used if value is empty.
+func (a *Aws) arn(value string, created func() (pulumi.StringOutput, error)) (pulumi.StringOutput, error) {
+	if value == "" {
+		return created()
+	}
+	return a.stacks.Resolve(value)
+}
+
+// role returns the role that configureIAM created for given use, an error is returned if no role in iam.roles has its prefix.
+func (a *Aws) role(use string) (*iam.Role, error) {
+	role, ok := a.roles[use]
+	if !ok {
+		return nil, fmt.Errorf("a role whose name starts with %v must be given in iam.roles", rolePrefixes[use])
+	}
+	return role, nil
+}
+
+// roleArn returns the ARN of the role for given use
+func (a *Aws) roleArn(use string) func() (pulumi.StringOutput, error) {
+	return func() (pulumi.StringOutput, error) {
+		role, err := a.role(use)
 		if err != nil {
-			a.ctx.Log.Error(err.Error(), nil)
-			continue
+			return pulumi.StringOutput{}, err
 		}
+		return role.Arn, nil
+	}
+}
+
+// Context returns the pulumi context that resources are registered on.
+func (a *Aws) Context() *pulumi.Context {
+	return a.ctx
+}
+
+// Config returns the config that resources are created from.
+func (a *Aws) Config() types.Config {
+	return a.config
+}
 
+// Outputs returns the values that are exported by created resources such as urls.
+func (a *Aws) Outputs() pulumi.Map {
+	return a.outputs
+}
+
+// Resources returns the created S3 bucket, Redshift cluster, Firehose delivery stream, Cognito user pool and Lambda function.
+func (a *Aws) Resources() map[types.Resource]pulumi.Resource {
+
+	resources := map[types.Resource]pulumi.Resource{}
+
+	if a.s3Bucket != nil {
+		resources[types.StorageResource] = a.s3Bucket
+	}
+	if a.redshift != nil {
+		resources[types.DwhResource] = a.redshift
+	}
+	if a.firehose != nil {
+		resources[types.StreamResource] = a.firehose
+	}
+	if a.userPool != nil {
+		resources[types.UserPoolResource] = a.userPool
+	}
+	if a.function != nil {
+		resources[types.FunctionResource] = a.function
 	}
 
-	return nil
+	return resources
+}
+
+// References returns a copy of the values that can be used in config as ${outputs.<name>}.
+func (a *Aws) References() map[string]pulumi.StringOutput {
+	return maps.Clone(a.references)
+}
+
+// Contract returns the values of the outputs contract, the ingest endpoint is API Gateway if it is created, otherwise the Lambda function url.
+func (a *Aws) Contract() contract.Values {
+	return a.contract
 }
 
 // New returns Aws struct
 func New(ctx *pulumi.Context, config types.Config) *Aws {
-	return &Aws{ctx: ctx, config: config}
+	return &Aws{ctx: ctx, outputs: pulumi.Map{}, references: map[string]pulumi.StringOutput{},
+		stacks: stackref.NewResolver(ctx, config.LogicalName), namer: naming.New(config, ctx.Project(), ctx.Stack()),
+		tags: pulumi.ToStringMap(tags.New(config, ctx.Stack())), config: config}
 }
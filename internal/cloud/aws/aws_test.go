@@ -2,6 +2,7 @@ package aws
 
 import (
 	"github.com/cemayan/pulumi-template/types"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
 	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 	"github.com/stretchr/testify/suite"
@@ -59,7 +60,27 @@ func (m mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
 }
 
 func (m mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
-	return args.Name + "_id", args.Inputs, nil
+	outputs := args.Inputs.Copy()
+
+	// Generated password is given by the random provider
+	if args.TypeToken == "random:index/randomPassword:RandomPassword" {
+		outputs["result"] = resource.MakeSecret(resource.NewStringProperty("generated-pass"))
+	}
+
+	// Referenced stacks export shared resources
+	if args.TypeToken == "pulumi:pulumi:StackReference" {
+		outputs["outputs"] = resource.NewObjectProperty(resource.NewPropertyMapFromMap(map[string]interface{}{
+			"firehoseRoleArn": "arn:aws:iam::1:role/firehose",
+			"bucketArn":       "arn:aws:s3:::platform-events",
+		}))
+	}
+
+	// Read resources keep the id that they are read with
+	if args.ID != "" {
+		return args.ID, outputs, nil
+	}
+
+	return args.Name + "_id", outputs, nil
 }
 
 func (ts *testSuite) TestCreateStorage() {
@@ -85,6 +106,184 @@ func (ts *testSuite) TestCreateStorage() {
 	}, pulumi.WithMocks("project", "stack", mocks(0)))
 	ts.NoError(err)
 }
+func (ts *testSuite) TestCreateStorageContract() {
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		aws := New(ctx, ts.config)
+		err := aws.CreateStorage()
+
+		ts.NoError(err)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		// Storage uri is exported in the contract, groups that are not created are left out.
+		pulumi.Any(aws.Contract().Map()).ApplyT(func(data interface{}) error {
+			ts.Equal(map[string]interface{}{"storage": map[string]interface{}{"uri": "s3://test-bucket"}}, data)
+			wg.Done()
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)))
+	ts.NoError(err)
+}
+
+func (ts *testSuite) TestCreateStorageResources() {
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		aws := New(ctx, ts.config)
+		ts.Empty(aws.Resources())
+
+		err := aws.CreateStorage()
+		ts.NoError(err)
+
+		// Only the created bucket is given, references are copied so callers can not change them
+		ts.Equal(map[types.Resource]pulumi.Resource{types.StorageResource: aws.s3Bucket}, aws.Resources())
+
+		references := aws.References()
+		ts.Contains(references, "outputs.s3.arn")
+		delete(references, "outputs.s3.arn")
+		ts.Contains(aws.References(), "outputs.s3.arn")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)))
+	ts.NoError(err)
+}
+
+func (ts *testSuite) TestCreateStorageInPipeline() {
+	config := ts.config
+	config.Name = "studio-a"
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		aws := New(ctx, config)
+		err := aws.CreateStorage()
+
+		ts.NoError(err)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		// Logical name is prefixed with the pipeline name, bucket name is not changed.
+		pulumi.All(aws.s3Bucket.URN(), aws.s3Bucket.Bucket).ApplyT(func(data []interface{}) error {
+			ts.Contains(string(data[0].(pulumi.URN)), "::studio-a-test-bucket")
+			ts.Equal("test-bucket", data[1])
+			wg.Done()
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)))
+	ts.NoError(err)
+}
+
+func (ts *testSuite) TestCreateStorageWithNamingPattern() {
+	config := ts.config
+	config.Env = "prod"
+	config.Naming = types.Naming{Pattern: "{project}-{env}-{component}"}
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		aws := New(ctx, config)
+		err := aws.CreateStorage()
+
+		ts.NoError(err)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		// Physical name is derived from the pattern, logical name is not changed.
+		pulumi.All(aws.s3Bucket.URN(), aws.s3Bucket.Bucket).ApplyT(func(data []interface{}) error {
+			ts.Contains(string(data[0].(pulumi.URN)), "::test-bucket")
+			ts.Equal("project-prod-test-bucket", data[1])
+			wg.Done()
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)))
+	ts.NoError(err)
+}
+
+func (ts *testSuite) TestCreateStorageWithTags() {
+	config := ts.config
+	config.Name = "studio-a"
+	config.Env = "prod"
+	config.Template = types.Template{Name: "data-pipeline"}
+	config.Tags = map[string]string{"team": "data"}
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		aws := New(ctx, config)
+		ts.NoError(aws.CreateStorage())
+		ts.NoError(aws.ConfigureIAM())
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		// Tags in config are added with env, stack, template and pipeline
+		pulumi.All(aws.s3Bucket.Tags, aws.roles["apigateway"].Tags).ApplyT(func(data []interface{}) error {
+			expected := map[string]string{"team": "data", "env": "prod", "stack": "stack", "template": "data-pipeline", "pipeline": "studio-a"}
+			ts.Equal(expected, data[0])
+			ts.Equal(expected, data[1])
+			wg.Done()
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)))
+	ts.NoError(err)
+}
+
+func (ts *testSuite) TestCreateStorageWithInvalidName() {
+	config := ts.config
+	config.Storage.Name = "ptemplate_events"
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		return New(ctx, config).CreateStorage()
+	}, pulumi.WithMocks("project", "stack", mocks(0)))
+
+	ts.ErrorContains(err, `s3 bucket ptemplate_events: s3 bucket name "ptemplate_events" is not valid, it can have lowercase letters, numbers, dots and hyphens, starting and ending with a letter or number`)
+}
+
+func (ts *testSuite) TestCreateStreamWithStackReferences() {
+	config := ts.config
+	config.Stream = types.Stream{
+		Name:        "events",
+		Destination: "s3",
+		RoleArn:     "stackref://acme/platform/prod#firehoseRoleArn",
+		S3Conf:      types.S3Conf{BufferingSize: 5, BufferingInterval: 60, BucketArn: "stackref://acme/platform/prod#bucketArn"},
+	}
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		// Role and bucket are not created in the program
+		aws := New(ctx, config)
+		err := aws.CreateStream()
+
+		ts.NoError(err)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		pulumi.All(aws.firehose.ExtendedS3Configuration.RoleArn().Elem(), aws.firehose.ExtendedS3Configuration.BucketArn().Elem()).ApplyT(func(data []interface{}) error {
+			ts.Equal("arn:aws:iam::1:role/firehose", data[0])
+			ts.Equal("arn:aws:s3:::platform-events", data[1])
+			wg.Done()
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)))
+	ts.NoError(err)
+}
+
 func (ts *testSuite) TestConfigureIAM() {
 	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
 
@@ -112,6 +311,236 @@ func (ts *testSuite) TestConfigureIAM() {
 	ts.NoError(err)
 }
 
+func (ts *testSuite) TestConfigureIAMWithOutputReference() {
+	config := ts.config
+	config.Iam.Roles = []types.Roles{{
+		Name:         "kinesis_firehose_role",
+		AssumePolicy: ts.awsConfigureIamPolicy,
+		InlinePolicy: `{"Statement": [{"Action": ["s3:PutObject"], "Resource": ["arn:aws:s3:::${outputs.s3.bucket}/*"]}]}`,
+	}}
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		aws := New(ctx, config)
+		ts.NoError(aws.CreateStorage())
+		ts.NoError(aws.ConfigureIAM())
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		// Reference is resolved with the bucket name
+		aws.roles["firehose"].InlinePolicies.ApplyT(func(policies []iam.RoleInlinePolicy) error {
+			ts.Equal(`{"Statement": [{"Action": ["s3:PutObject"], "Resource": ["arn:aws:s3:::test-bucket/*"]}]}`, *policies[0].Policy)
+			wg.Done()
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)))
+	ts.NoError(err)
+}
+
+func (ts *testSuite) TestConfigureIAMWithUnknownOutputReference() {
+	config := ts.config
+	config.Iam.Roles = []types.Roles{{
+		Name:         "kinesis_firehose_role",
+		InlinePolicy: `{"Resource": "${outputs.firehose.arn}"}`,
+	}}
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		return New(ctx, config).ConfigureIAM()
+	}, pulumi.WithMocks("project", "stack", mocks(0)))
+
+	ts.ErrorContains(err, "role kinesis_firehose_role inline policy: unknown reference ${outputs.firehose.arn}, available references are: none")
+}
+
+func (ts *testSuite) TestCreateDWHWithSecretReference() {
+	config := ts.config
+	config.Iam.Roles = []types.Roles{{Name: "redshift_service_role", AssumePolicy: ts.awsConfigureIamPolicy}}
+	config.Dwh.Redshift = types.Redshift{Identifier: "cluster", DbName: "db", MasterUser: "master", MasterPass: "secret://env/PTEMPLATE_TEST_REDSHIFT_PASS"}
+
+	ts.T().Setenv("PTEMPLATE_TEST_REDSHIFT_PASS", "Verysecretpass1!!")
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		aws := New(ctx, config)
+		ts.NoError(aws.ConfigureIAM())
+		ts.NoError(aws.CreateDWH())
+
+		// Password is kept as secret
+		ts.True(pulumi.IsSecret(aws.redshift.MasterPassword))
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		aws.redshift.MasterPassword.ApplyT(func(password *string) error {
+			ts.Equal("Verysecretpass1!!", *password)
+			wg.Done()
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)))
+	ts.NoError(err)
+}
+
+func (ts *testSuite) TestCreateWithoutRole() {
+	config := ts.config
+	config.Iam.Roles = []types.Roles{{Name: "kinesis_firehose_role", AssumePolicy: ts.awsConfigureIamPolicy}}
+	config.Dwh.Redshift = types.Redshift{Identifier: "cluster", DbName: "db", MasterUser: "master", MasterPass: "Verysecretpass1!!"}
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		aws := New(ctx, config)
+		ts.NoError(aws.ConfigureIAM())
+		ts.EqualError(aws.CreateDWH(), "redshift cluster cluster: a role whose name starts with redshift_service must be given in iam.roles")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)))
+	ts.NoError(err)
+}
+
+func (ts *testSuite) TestCreateDWHWithGeneratedPassword() {
+	config := ts.config
+	config.Iam.Roles = []types.Roles{
+		{Name: "redshift_service_role", AssumePolicy: ts.awsConfigureIamPolicy},
+		{Name: "kinesis_firehose_role", AssumePolicy: ts.awsConfigureIamPolicy},
+	}
+	config.Dwh.Redshift = types.Redshift{Identifier: "cluster", DbName: "db", MasterUser: "master"}
+	config.Stream = types.Stream{Name: "events", Destination: "redshift", RedshiftConf: types.RedshiftConf{DataTableName: "events"}}
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		aws := New(ctx, config)
+		ts.NoError(aws.ConfigureIAM())
+		ts.NoError(aws.CreateStorage())
+		ts.NoError(aws.CreateDWH())
+		ts.NoError(aws.CreateStream())
+
+		// Secret arn is exported
+		ts.Contains(aws.Outputs(), "redshift_secret_arn")
+		ts.True(pulumi.IsSecret(aws.redshift.MasterPassword))
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		// Cluster and Firehose use the same generated password with the master user, the stream is a child of the FirehoseStream component
+		pulumi.All(aws.redshift.MasterPassword, aws.firehose.RedshiftConfiguration.Password(), aws.firehose.URN(), aws.firehose.RedshiftConfiguration.Username()).ApplyT(func(data []interface{}) error {
+			ts.Equal("generated-pass", *data[0].(*string))
+			ts.Equal("generated-pass", *data[1].(*string))
+			ts.Equal("urn:pulumi:stack::project::ptemplate:aws:FirehoseStream$aws:kinesis/firehoseDeliveryStream:FirehoseDeliveryStream::events", string(data[2].(pulumi.URN)))
+			ts.Equal("master", *data[3].(*string))
+			wg.Done()
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)))
+	ts.NoError(err)
+
+	// Password of the master user can not be used by another user
+	config.Stream.RedshiftConf.Username = "loader"
+
+	err = pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		aws := New(ctx, config)
+		ts.NoError(aws.ConfigureIAM())
+		ts.NoError(aws.CreateStorage())
+		ts.NoError(aws.CreateDWH())
+		ts.EqualError(aws.CreateStream(), "firehose delivery stream events: redshift user loader is not the master user master, its password must be given in stream.redshift_conf.password")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)))
+	ts.NoError(err)
+}
+
+func (ts *testSuite) TestCreateDWHWithExistingCluster() {
+	config := ts.config
+	config.Dwh.Redshift = types.Redshift{Identifier: "cluster", ExistingId: "legacy-cluster", Sql: "CREATE TABLE events (name varchar(64));"}
+	config.Stream = types.Stream{Name: "events", Destination: "redshift", RoleArn: "arn:aws:iam::1:role/firehose",
+		S3Conf: types.S3Conf{BucketArn: "arn:aws:s3:::events"}, RedshiftConf: types.RedshiftConf{Username: "master", DataTableName: "events"}}
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		// Cluster is read without its role, password is not known and sql is not executed on it
+		aws := New(ctx, config)
+		ts.NoError(aws.CreateDWH())
+		ts.NotContains(aws.Outputs(), "redshift_secret_arn")
+		ts.Nil(aws.redshiftStatement)
+
+		ts.EqualError(aws.CreateStream(), "firehose delivery stream events: redshift password must be given in stream.redshift_conf.password for an existing cluster")
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		aws.redshift.ID().ApplyT(func(id pulumi.ID) error {
+			ts.Equal(pulumi.ID("legacy-cluster"), id)
+			wg.Done()
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)))
+	ts.NoError(err)
+}
+
+func (ts *testSuite) TestCreateDWHWithImportedCluster() {
+	config := ts.config
+	config.Dwh.Redshift = types.Redshift{Identifier: "cluster", DbName: "db", MasterUser: "master", NodeType: "dc2.large", ImportId: "legacy-cluster"}
+	config.Iam.Roles = []types.Roles{{Name: "redshift_service_role", AssumePolicy: ts.awsConfigureIamPolicy}}
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		aws := New(ctx, config)
+		ts.NoError(aws.ConfigureIAM())
+
+		// Password of an adopted cluster is not generated, sql is not executed since it is not given
+		ts.EqualError(aws.CreateDWH(), "redshift cluster cluster master password: dwh.redshift.master_pass must be given to adopt a cluster")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)))
+	ts.NoError(err)
+
+	config.Dwh.Redshift.MasterPass = "secret://env/REDSHIFT_PASS"
+	ts.T().Setenv("REDSHIFT_PASS", "Adopted-pass1")
+
+	err = pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		aws := New(ctx, config)
+		ts.NoError(aws.ConfigureIAM())
+		ts.NoError(aws.CreateDWH())
+		ts.Nil(aws.redshiftStatement)
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)))
+	ts.NoError(err)
+}
+
+func (ts *testSuite) TestCreateIdentityManagementWithGeneratedPassword() {
+	config := ts.config
+	config.Authorizer.UserPool = types.UserPool{
+		Name:       "pool",
+		User:       types.User{Username: "ptemplate"},
+		UserClient: types.UserClient{Name: "client"},
+		UserDomain: types.UserDomain{Name: "domain"},
+	}
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		aws := New(ctx, config)
+		ts.NoError(aws.CreateIdentityManagement())
+
+		// config:userpass is not given, so the password is generated
+		ts.Contains(aws.Outputs(), "cognito_user_secret_arn")
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)))
+	ts.NoError(err)
+}
+
 func TestRunSuite(t *testing.T) {
 	suite.Run(t, &testSuite{})
 }
@@ -0,0 +1,819 @@
+package azure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/cemayan/pulumi-template/internal/adopt"
+	"github.com/cemayan/pulumi-template/internal/contract"
+	"github.com/cemayan/pulumi-template/internal/interpolate"
+	"github.com/cemayan/pulumi-template/internal/lifecycle"
+	"github.com/cemayan/pulumi-template/internal/naming"
+	"github.com/cemayan/pulumi-template/internal/tags"
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/pulumi/pulumi-archive/sdk/go/archive"
+	"github.com/pulumi/pulumi-azure/sdk/v5/go/azure/apimanagement"
+	"github.com/pulumi/pulumi-azure/sdk/v5/go/azure/appservice"
+	"github.com/pulumi/pulumi-azure/sdk/v5/go/azure/authorization"
+	"github.com/pulumi/pulumi-azure/sdk/v5/go/azure/core"
+	"github.com/pulumi/pulumi-azure/sdk/v5/go/azure/eventhub"
+	"github.com/pulumi/pulumi-azure/sdk/v5/go/azure/kusto"
+	"github.com/pulumi/pulumi-azure/sdk/v5/go/azure/storage"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+	"maps"
+	"strconv"
+	"strings"
+)
+
+// Azure represents the Azure related resources and configs
+type Azure struct {
+	ctx            *pulumi.Context
+	outputs        pulumi.Map
+	contract       contract.Values
+	references     map[string]pulumi.StringOutput
+	config         types.Config
+	namer          *naming.Namer
+	tags           pulumi.StringMap
+	location       string
+	resourceGroup  *core.ResourceGroup
+	identities     map[string]*authorization.UserAssignedIdentity
+	storageAccount *storage.Account
+	container      *storage.Container
+	namespace      *eventhub.EventHubNamespace
+	eventHub       *eventhub.EventHub
+	functionApp    *appservice.LinuxFunctionApp
+	apiManagement  *apimanagement.Service
+	kustoCluster   *kusto.Cluster
+	kustoDatabase  *kusto.Database
+	kustoScript    *kusto.Script
+	dataConnection *kusto.EventhubDataConnection
+}
+
+// getResourceGroup returns the resource group that every resource is created in.
+// It will be created on first call according to given values.
+func (az *Azure) getResourceGroup() (*core.ResourceGroup, error) {
+
+	if az.resourceGroup != nil {
+		return az.resourceGroup, nil
+	}
+
+	name, err := az.namer.Name(naming.ResourceGroup, az.config.ResourceGroup)
+	if err != nil {
+		return nil, fmt.Errorf("resource group %v: %w", az.config.ResourceGroup, err)
+	}
+
+	resourceGroup, err := core.NewResourceGroup(az.ctx, az.config.LogicalName(az.config.ResourceGroup), &core.ResourceGroupArgs{
+		Name:     pulumi.String(name),
+		Location: pulumi.String(az.location),
+		Tags:     az.tags,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("resource group %v: %w", az.config.ResourceGroup, err)
+	}
+
+	az.resourceGroup = resourceGroup
+
+	return resourceGroup, nil
+}
+
+func (az *Azure) CreateIdentityManagement() error {
+	return errors.New("identity management is not implemented on azure")
+}
+
+// CreateFunction creates Function App according to given values
+// Zipped source code will be deployed to the app, the storage account created by createStorage is used by the Functions runtime.
+// Event Hub name and namespace are given to the app as settings, managed identities created by configureIAM are assigned to the app.
+// If function app creation operation is successful URL will be exported.
+func (az *Azure) CreateFunction() error {
+
+	resourceGroup, err := az.getResourceGroup()
+	if err != nil {
+		return err
+	}
+
+	// Zip deploy does not notice the changes of a file at the same path, hash of the package is given as an app setting so the app is updated with the changes.
+	arch, err := archive.LookupFile(az.ctx, &archive.LookupFileArgs{
+		Type:       "zip",
+		SourceDir:  pulumi.StringRef(az.config.Function.Build.Source.Zip),
+		OutputPath: az.config.Function.Build.Source.OutputPath,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("archive %v: %w", az.config.Function.Build.Source.Zip, err)
+	}
+
+	functionName, err := az.namer.Name(naming.FunctionApp, az.config.Function.Name)
+	if err != nil {
+		return fmt.Errorf("function app %v: %w", az.config.Function.Name, err)
+	}
+
+	planName, err := az.namer.Name(naming.ServicePlan, fmt.Sprintf("%v-plan", az.config.Function.Name))
+	if err != nil {
+		return fmt.Errorf("service plan %v-plan: %w", az.config.Function.Name, err)
+	}
+
+	plan, err := appservice.NewServicePlan(az.ctx, az.config.LogicalName(fmt.Sprintf("%v-plan", az.config.Function.Name)), &appservice.ServicePlanArgs{
+		Name:              pulumi.String(planName),
+		ResourceGroupName: resourceGroup.Name,
+		Location:          resourceGroup.Location,
+		OsType:            pulumi.String("Linux"),
+		SkuName:           pulumi.String("Y1"),
+		Tags:              az.tags,
+	})
+	if err != nil {
+		return fmt.Errorf("service plan %v-plan: %w", az.config.Function.Name, err)
+	}
+
+	appSettings := pulumi.StringMap{
+		"FUNCTIONS_WORKER_RUNTIME": pulumi.String(az.config.Function.Build.Runtime),
+		"WEBSITE_RUN_FROM_PACKAGE": pulumi.String("1"),
+		"PACKAGE_SHA256":           pulumi.String(arch.OutputBase64sha256),
+		"EVENTHUB_NAME":            az.eventHub.Name,
+		"EVENTHUB_NAMESPACE": az.namespace.Name.ApplyT(func(name string) (string, error) {
+			return fmt.Sprintf("%v.servicebus.windows.net", name), nil
+		}).(pulumi.StringOutput),
+	}
+
+	for k, v := range az.config.Function.Build.Envs {
+		appSettings[k], err = az.resolve(v)
+		if err != nil {
+			return fmt.Errorf("function app %v setting %v: %w", az.config.Function.Name, k, err)
+		}
+	}
+
+	applicationStack := &appservice.LinuxFunctionAppSiteConfigApplicationStackArgs{}
+
+	switch az.config.Function.Build.Runtime {
+	case "node":
+		applicationStack.NodeVersion = pulumi.String(az.config.Function.Build.RuntimeVersion)
+	case "python":
+		applicationStack.PythonVersion = pulumi.String(az.config.Function.Build.RuntimeVersion)
+	case "dotnet":
+		applicationStack.DotnetVersion = pulumi.String(az.config.Function.Build.RuntimeVersion)
+	case "java":
+		applicationStack.JavaVersion = pulumi.String(az.config.Function.Build.RuntimeVersion)
+	}
+
+	funcArgs := &appservice.LinuxFunctionAppArgs{
+		Name:                    pulumi.String(functionName),
+		ResourceGroupName:       resourceGroup.Name,
+		Location:                resourceGroup.Location,
+		ServicePlanId:           plan.ID(),
+		StorageAccountName:      az.storageAccount.Name,
+		StorageAccountAccessKey: az.storageAccount.PrimaryAccessKey,
+		ZipDeployFile:           pulumi.String(az.config.Function.Build.Source.OutputPath),
+		AppSettings:             appSettings,
+		Tags:                    az.tags,
+		SiteConfig: &appservice.LinuxFunctionAppSiteConfigArgs{
+			ApplicationStack: applicationStack,
+		},
+	}
+
+	resources := []pulumi.Resource{plan, az.storageAccount, az.eventHub}
+
+	if len(az.identities) > 0 {
+		identityIds := pulumi.StringArray{}
+		for _, role := range az.config.Iam.Roles {
+			identity, ok := az.identities[role.Name]
+			if !ok {
+				continue
+			}
+
+			// DefaultAzureCredential in the function uses the client id to pick the identity, first one is used
+			if len(identityIds) == 0 {
+				appSettings["AZURE_CLIENT_ID"] = identity.ClientId
+			}
+
+			identityIds = append(identityIds, identity.ID())
+			resources = append(resources, identity)
+		}
+
+		funcArgs.Identity = &appservice.LinuxFunctionAppIdentityArgs{
+			Type:        pulumi.String("UserAssigned"),
+			IdentityIds: identityIds,
+		}
+	}
+
+	functionApp, err := appservice.NewLinuxFunctionApp(az.ctx, az.config.LogicalName(az.config.Function.Name), funcArgs, lifecycle.Options(az.config, lifecycle.Function, pulumi.DependsOn(resources))...)
+	if err != nil {
+		return fmt.Errorf("function app %v: %w", az.config.Function.Name, err)
+	}
+
+	az.functionApp = functionApp
+
+	functionUrl := functionApp.DefaultHostname.ApplyT(func(hostname string) (string, error) {
+		return fmt.Sprintf("https://%v/api", hostname), nil
+	}).(pulumi.StringOutput)
+
+	az.outputs["function_url"] = functionUrl
+
+	// API Management is the ingest endpoint if it is created, function.auth gives the auth level that the function code declares
+	if az.contract.IngestUrl == nil {
+		az.contract.IngestUrl = functionUrl
+		az.contract.IngestAuthType = pulumi.String(contract.AuthType(az.config.Function.Auth))
+	}
+
+	return nil
+}
+
+// kustoTypes maps the column types that can be given in schema to Kusto types.
+// BigQuery style types are accepted so the same schema can be used on both clouds.
+var kustoTypes = map[string]string{
+	"STRING":    "string",
+	"JSON":      "dynamic",
+	"INTEGER":   "long",
+	"INT64":     "long",
+	"FLOAT":     "real",
+	"FLOAT64":   "real",
+	"NUMERIC":   "decimal",
+	"BOOLEAN":   "bool",
+	"BOOL":      "bool",
+	"TIMESTAMP": "datetime",
+	"DATETIME":  "datetime",
+}
+
+// kustoTableScript returns the commands that create the table and its JSON ingestion mapping from given JSON schema.
+// Schema is an array of columns such as [{"name": "game_name", "type": "STRING"}], mapping name is "<table>_mapping".
+func kustoTableScript(table string, schema string) (string, error) {
+
+	var columns []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	}
+
+	if err := json.Unmarshal([]byte(schema), &columns); err != nil {
+		return "", fmt.Errorf("schema: %w", err)
+	}
+
+	if len(columns) == 0 {
+		return "", errors.New("schema: no column is given")
+	}
+
+	type mapping struct {
+		Column   string `json:"column"`
+		Path     string `json:"path"`
+		Datatype string `json:"datatype"`
+	}
+
+	definitions := []string{}
+	mappings := []mapping{}
+
+	for _, column := range columns {
+		kustoType, ok := kustoTypes[strings.ToUpper(column.Type)]
+		if !ok {
+			kustoType = strings.ToLower(column.Type)
+		}
+
+		definitions = append(definitions, fmt.Sprintf("%v:%v", column.Name, kustoType))
+		mappings = append(mappings, mapping{Column: column.Name, Path: fmt.Sprintf("$.%v", column.Name), Datatype: kustoType})
+	}
+
+	mappingJson, err := json.Marshal(mappings)
+	if err != nil {
+		return "", fmt.Errorf("schema: %w", err)
+	}
+
+	return fmt.Sprintf(".create-merge table %v (%v)\n\n.create-or-alter table %v ingestion json mapping \"%v_mapping\" '%v'\n",
+		table, strings.Join(definitions, ", "), table, table, string(mappingJson)), nil
+}
+
+// CreateDWH creates Azure Data Explorer cluster, database and table according to given values
+// Table and its JSON ingestion mapping will be created from given schema.
+func (az *Azure) CreateDWH() error {
+
+	resourceGroup, err := az.getResourceGroup()
+	if err != nil {
+		return err
+	}
+
+	adx := az.config.Dwh.Adx
+
+	script, err := kustoTableScript(adx.Table, adx.Schema)
+	if err != nil {
+		return fmt.Errorf("adx table %v: %w", adx.Table, err)
+	}
+
+	clusterName, err := az.namer.Name(naming.AdxCluster, adx.Cluster)
+	if err != nil {
+		return fmt.Errorf("adx cluster %v: %w", adx.Cluster, err)
+	}
+
+	databaseName, err := az.namer.Name(naming.AdxDatabase, adx.Database)
+	if err != nil {
+		return fmt.Errorf("adx database %v: %w", adx.Database, err)
+	}
+
+	cluster, err := kusto.NewCluster(az.ctx, az.config.LogicalName(adx.Cluster), &kusto.ClusterArgs{
+		Name:              pulumi.String(clusterName),
+		ResourceGroupName: resourceGroup.Name,
+		Location:          resourceGroup.Location,
+		Sku: &kusto.ClusterSkuArgs{
+			Name:     pulumi.String(adx.Sku),
+			Capacity: pulumi.Int(adx.Capacity),
+		},
+		Tags: az.tags,
+	}, lifecycle.Options(az.config, lifecycle.Dwh)...)
+	if err != nil {
+		return fmt.Errorf("adx cluster %v: %w", adx.Cluster, err)
+	}
+
+	az.kustoCluster = cluster
+
+	database, err := kusto.NewDatabase(az.ctx, az.config.LogicalName(adx.Database), &kusto.DatabaseArgs{
+		Name:              pulumi.String(databaseName),
+		ResourceGroupName: resourceGroup.Name,
+		Location:          resourceGroup.Location,
+		ClusterName:       cluster.Name,
+		HotCachePeriod:    pulumi.String(adx.HotCachePeriod),
+		SoftDeletePeriod:  pulumi.String(adx.SoftDeletePeriod),
+	}, lifecycle.Options(az.config, lifecycle.Dwh, pulumi.DependsOn([]pulumi.Resource{cluster}))...)
+	if err != nil {
+		return fmt.Errorf("adx database %v: %w", adx.Database, err)
+	}
+
+	az.kustoDatabase = database
+
+	tableScript, err := kusto.NewScript(az.ctx, az.config.LogicalName(fmt.Sprintf("%v-script", adx.Table)), &kusto.ScriptArgs{
+		Name:          pulumi.String(fmt.Sprintf("%v-script", adx.Table)),
+		DatabaseId:    database.ID(),
+		ScriptContent: pulumi.String(script),
+	}, pulumi.DependsOn([]pulumi.Resource{database}))
+	if err != nil {
+		return fmt.Errorf("adx table %v: %w", adx.Table, err)
+	}
+
+	az.kustoScript = tableScript
+	az.contract.DwhTable = pulumi.Sprintf("%v.%v", database.Name, adx.Table)
+
+	return nil
+}
+
+// createDataConnection creates a consumer group and Event Hub data connection according to given values
+// Events are ingested to the Azure Data Explorer table with the mapping created by CreateDWH.
+func (az *Azure) createDataConnection(resourceGroup *core.ResourceGroup) error {
+
+	adx := az.config.Dwh.Adx
+
+	consumerGroup, err := eventhub.NewConsumerGroup(az.ctx, az.config.LogicalName(fmt.Sprintf("%v-%v", az.config.Stream.Name, adx.Cluster)), &eventhub.ConsumerGroupArgs{
+		Name:              pulumi.String(adx.Cluster),
+		NamespaceName:     az.namespace.Name,
+		EventhubName:      az.eventHub.Name,
+		ResourceGroupName: resourceGroup.Name,
+	}, pulumi.DependsOn([]pulumi.Resource{az.eventHub}))
+	if err != nil {
+		return fmt.Errorf("consumer group %v: %w", adx.Cluster, err)
+	}
+
+	dataConnection, err := kusto.NewEventhubDataConnection(az.ctx, az.config.LogicalName(fmt.Sprintf("%v-connection", adx.Table)), &kusto.EventhubDataConnectionArgs{
+		Name:              pulumi.String(fmt.Sprintf("%v-connection", adx.Table)),
+		ResourceGroupName: resourceGroup.Name,
+		Location:          resourceGroup.Location,
+		ClusterName:       az.kustoCluster.Name,
+		DatabaseName:      az.kustoDatabase.Name,
+		EventhubId:        az.eventHub.ID(),
+		ConsumerGroup:     consumerGroup.Name,
+		TableName:         pulumi.String(adx.Table),
+		MappingRuleName:   pulumi.String(fmt.Sprintf("%v_mapping", adx.Table)),
+		DataFormat:        pulumi.String("MULTIJSON"),
+	}, pulumi.DependsOn([]pulumi.Resource{consumerGroup, az.kustoScript}))
+	if err != nil {
+		return fmt.Errorf("adx data connection %v-connection: %w", adx.Table, err)
+	}
+
+	az.dataConnection = dataConnection
+
+	return nil
+}
+
+// CreateStorage creates Storage Account and Blob container according to given values
+// Container is used by Event Hubs Capture as destination.
+// An existing account is read if storage.existing_id is given, or adopted if storage.import_id is given.
+func (az *Azure) CreateStorage() error {
+
+	resourceGroup, err := az.getResourceGroup()
+	if err != nil {
+		return err
+	}
+
+	var account *storage.Account
+
+	accountName, err := az.namer.Name(naming.StorageAccount, az.config.Storage.Name)
+	if err != nil {
+		return fmt.Errorf("storage account %v: %w", az.config.Storage.Name, err)
+	}
+
+	if az.config.Storage.ExistingId != "" {
+		account, err = storage.GetAccount(az.ctx, az.config.LogicalName(az.config.Storage.Name), pulumi.ID(az.config.Storage.ExistingId), nil)
+	} else {
+		account, err = storage.NewAccount(az.ctx, az.config.LogicalName(az.config.Storage.Name), &storage.AccountArgs{
+			Name:                   pulumi.String(accountName),
+			ResourceGroupName:      resourceGroup.Name,
+			Location:               resourceGroup.Location,
+			AccountTier:            pulumi.String(az.config.Storage.AccountTier),
+			AccountReplicationType: pulumi.String(az.config.Storage.Replication),
+			Tags:                   az.tags,
+		}, adopt.Options(az.config.Storage.ImportId, lifecycle.Options(az.config, lifecycle.Storage)...)...)
+	}
+	if err != nil {
+		return fmt.Errorf("storage account %v: %w", az.config.Storage.Name, err)
+	}
+
+	az.storageAccount = account
+	az.references["outputs.storage.name"] = account.Name
+	az.contract.StorageUri = account.PrimaryBlobEndpoint
+
+	if az.config.Storage.Bucket.Name != "" {
+		containerName, err := az.namer.Name(naming.BlobContainer, az.config.Storage.Bucket.Name)
+		if err != nil {
+			return fmt.Errorf("blob container %v: %w", az.config.Storage.Bucket.Name, err)
+		}
+
+		container, err := storage.NewContainer(az.ctx, az.config.LogicalName(az.config.Storage.Bucket.Name), &storage.ContainerArgs{
+			Name:                pulumi.String(containerName),
+			StorageAccountName:  account.Name,
+			ContainerAccessType: pulumi.String("private"),
+		}, lifecycle.Options(az.config, lifecycle.Storage, pulumi.DependsOn([]pulumi.Resource{account}))...)
+		if err != nil {
+			return fmt.Errorf("blob container %v: %w", az.config.Storage.Bucket.Name, err)
+		}
+
+		az.container = container
+		az.contract.StorageUri = pulumi.Sprintf("https://%v.blob.core.windows.net/%v", account.Name, container.Name)
+	}
+
+	return nil
+}
+
+// CreateStream creates Event Hubs namespace and Event Hub according to given values
+// You can set the destination such as "blob,adx"
+// If destination is "blob" events are written to the Blob container with Capture,
+// if destination is "adx" events are ingested to the Azure Data Explorer table with a data connection.
+func (az *Azure) CreateStream() error {
+
+	// Capture writes to the container that createStorage creates for storage.bucket.name
+	if az.config.Stream.Destination == "blob" && az.container == nil {
+		return fmt.Errorf("event hub %v: blob destination needs a container, storage.bucket.name must be given", az.config.Stream.Name)
+	}
+
+	resourceGroup, err := az.getResourceGroup()
+	if err != nil {
+		return err
+	}
+
+	namespaceConf := az.config.Stream.EventHubConf.Namespace
+
+	namespaceName, err := az.namer.Name(naming.EventHubNamespace, namespaceConf.Name)
+	if err != nil {
+		return fmt.Errorf("event hub namespace %v: %w", namespaceConf.Name, err)
+	}
+
+	eventHubName, err := az.namer.Name(naming.EventHub, az.config.Stream.Name)
+	if err != nil {
+		return fmt.Errorf("event hub %v: %w", az.config.Stream.Name, err)
+	}
+
+	namespace, err := eventhub.NewEventHubNamespace(az.ctx, az.config.LogicalName(namespaceConf.Name), &eventhub.EventHubNamespaceArgs{
+		Name:              pulumi.String(namespaceName),
+		ResourceGroupName: resourceGroup.Name,
+		Location:          resourceGroup.Location,
+		Sku:               pulumi.String(namespaceConf.Sku),
+		Capacity:          pulumi.Int(namespaceConf.Capacity),
+		Tags:              az.tags,
+	}, lifecycle.Options(az.config, lifecycle.Stream)...)
+	if err != nil {
+		return fmt.Errorf("event hub namespace %v: %w", namespaceConf.Name, err)
+	}
+
+	az.namespace = namespace
+	az.references["outputs.eventhub.namespace"] = namespace.Name
+
+	args := &eventhub.EventHubArgs{
+		Name:              pulumi.String(eventHubName),
+		NamespaceName:     namespace.Name,
+		ResourceGroupName: resourceGroup.Name,
+		PartitionCount:    pulumi.Int(az.config.Stream.EventHubConf.PartitionCount),
+		MessageRetention:  pulumi.Int(az.config.Stream.EventHubConf.MessageRetention),
+	}
+
+	resources := []pulumi.Resource{namespace}
+
+	if az.config.Stream.Destination == "blob" {
+		capture := az.config.Stream.EventHubConf.Capture
+
+		args.CaptureDescription = &eventhub.EventHubCaptureDescriptionArgs{
+			Enabled:           pulumi.Bool(true),
+			Encoding:          pulumi.String(capture.Encoding),
+			IntervalInSeconds: pulumi.Int(capture.Interval),
+			SizeLimitInBytes:  pulumi.Int(capture.SizeLimit),
+			Destination: &eventhub.EventHubCaptureDescriptionDestinationArgs{
+				Name:              pulumi.String("EventHubArchive.AzureBlockBlob"),
+				ArchiveNameFormat: pulumi.String(capture.ArchiveNameFormat),
+				BlobContainerName: az.container.Name,
+				StorageAccountId:  az.storageAccount.ID(),
+			},
+		}
+
+		resources = append(resources, az.container)
+	}
+
+	eventHub, err := eventhub.NewEventHub(az.ctx, az.config.LogicalName(az.config.Stream.Name), args, lifecycle.Options(az.config, lifecycle.Stream, pulumi.DependsOn(resources))...)
+	if err != nil {
+		return fmt.Errorf("event hub %v: %w", az.config.Stream.Name, err)
+	}
+
+	az.eventHub = eventHub
+	az.references["outputs.eventhub.name"] = eventHub.Name
+	az.contract.StreamId = eventHub.ID().ToStringOutput()
+
+	if az.config.Stream.Destination == "adx" {
+		return az.createDataConnection(resourceGroup)
+	}
+
+	return nil
+}
+
+// CreateApiGateway creates API Management service according to given values
+// Each route will be an API whose backend is the function app, each integration will be an operation of that API.
+// Example can be found in configs/datapipeline/eventhub/storage/apigateway/config.yaml
+func (az *Azure) CreateApiGateway() error {
+
+	resourceGroup, err := az.getResourceGroup()
+	if err != nil {
+		return err
+	}
+
+	serviceName, err := az.namer.Name(naming.ApiManagement, az.config.APIGateway.Name)
+	if err != nil {
+		return fmt.Errorf("api management %v: %w", az.config.APIGateway.Name, err)
+	}
+
+	service, err := apimanagement.NewService(az.ctx, az.config.LogicalName(az.config.APIGateway.Name), &apimanagement.ServiceArgs{
+		Name:              pulumi.String(serviceName),
+		ResourceGroupName: resourceGroup.Name,
+		Location:          resourceGroup.Location,
+		PublisherName:     pulumi.String(az.config.APIGateway.PublisherName),
+		PublisherEmail:    pulumi.String(az.config.APIGateway.PublisherEmail),
+		SkuName:           pulumi.String(az.config.APIGateway.Sku),
+		Tags:              az.tags,
+	}, lifecycle.Options(az.config, lifecycle.ApiGateway)...)
+	if err != nil {
+		return fmt.Errorf("api management %v: %w", az.config.APIGateway.Name, err)
+	}
+
+	az.apiManagement = service
+
+	serviceUrl := az.functionApp.DefaultHostname.ApplyT(func(hostname string) (string, error) {
+		return fmt.Sprintf("https://%v/api", hostname), nil
+	}).(pulumi.StringOutput)
+
+	for _, route := range az.config.APIGateway.Routes {
+
+		api, err := apimanagement.NewApi(az.ctx, az.config.LogicalName(route.Name), &apimanagement.ApiArgs{
+			Name:                 pulumi.String(route.Name),
+			ResourceGroupName:    resourceGroup.Name,
+			ApiManagementName:    service.Name,
+			Revision:             pulumi.String("1"),
+			DisplayName:          pulumi.String(route.Name),
+			Path:                 pulumi.String(route.Name),
+			Protocols:            pulumi.StringArray{pulumi.String("https")},
+			ServiceUrl:           serviceUrl,
+			SubscriptionRequired: pulumi.Bool(false),
+		}, pulumi.DependsOn([]pulumi.Resource{service, az.functionApp}))
+		if err != nil {
+			return fmt.Errorf("api %v: %w", route.Name, err)
+		}
+
+		for _, integration := range route.Integrations {
+
+			operationArgs := &apimanagement.ApiOperationArgs{
+				OperationId:       pulumi.String(integration.Name),
+				ApiName:           api.Name,
+				ApiManagementName: service.Name,
+				ResourceGroupName: resourceGroup.Name,
+				DisplayName:       pulumi.String(integration.Method.Name),
+				Method:            pulumi.String(integration.Method.Type),
+				UrlTemplate:       pulumi.String(integration.URI),
+			}
+
+			if integration.Method.Response.StatusCode != "" {
+				statusCode, err := strconv.Atoi(integration.Method.Response.StatusCode)
+				if err != nil {
+					return fmt.Errorf("operation %v on route %v: status code: %w", integration.Name, route.Name, err)
+				}
+
+				operationArgs.Responses = apimanagement.ApiOperationResponseArray{
+					&apimanagement.ApiOperationResponseArgs{
+						StatusCode: pulumi.Int(statusCode),
+					},
+				}
+			}
+
+			_, err := apimanagement.NewApiOperation(az.ctx, az.config.LogicalName(fmt.Sprintf("%v-%v", route.Name, integration.Name)), operationArgs, pulumi.DependsOn([]pulumi.Resource{api}))
+			if err != nil {
+				return fmt.Errorf("operation %v on route %v: %w", integration.Name, route.Name, err)
+			}
+		}
+	}
+
+	az.outputs["apiGatewayUrl"] = service.GatewayUrl
+
+	// APIs do not require a subscription key
+	if route, _, ok := contract.IngestRoute(az.config.APIGateway.Routes); ok {
+		az.contract.IngestUrl = pulumi.Sprintf("%v/%v", service.GatewayUrl, route.Name)
+		az.contract.IngestAuthType = pulumi.String(types.AuthNone)
+	}
+
+	return nil
+}
+
+// CreateVpc returns an error since virtual networks are not supported on Azure
+func (az *Azure) CreateVpc() error {
+	return errors.New("vpc is not implemented on azure")
+}
+
+// scopeOf returns the resource id that a role is assigned on according to given scope.
+// Scope can be "storage", "stream" or "resource_group".
+func (az *Azure) scopeOf(scope string) (pulumi.StringInput, pulumi.Resource, error) {
+	switch scope {
+	case "storage":
+		return az.storageAccount.ID(), az.storageAccount, nil
+	case "stream":
+		return az.eventHub.ID(), az.eventHub, nil
+	case "resource_group":
+		return az.resourceGroup.ID(), az.resourceGroup, nil
+	}
+	return nil, nil, fmt.Errorf("scope %q is not supported", scope)
+}
+
+// ConfigureIAM creates managed identities and role assignments according to given values.
+// Roles with "managedidentity" type create a user assigned identity.
+// Roles with "roleassignment" type assign the role to the identity given in member on the given scope.
+func (az *Azure) ConfigureIAM() error {
+
+	resourceGroup, err := az.getResourceGroup()
+	if err != nil {
+		return err
+	}
+
+	az.identities = map[string]*authorization.UserAssignedIdentity{}
+
+	for _, role := range az.config.Iam.Roles {
+		if role.Type != "managedidentity" {
+			continue
+		}
+
+		identityName, err := az.namer.Name(naming.ManagedIdentity, role.Name)
+		if err != nil {
+			return fmt.Errorf("managed identity %v: %w", role.Name, err)
+		}
+
+		identity, err := authorization.NewUserAssignedIdentity(az.ctx, az.config.LogicalName(role.Name), &authorization.UserAssignedIdentityArgs{
+			Name:              pulumi.String(identityName),
+			ResourceGroupName: resourceGroup.Name,
+			Location:          resourceGroup.Location,
+			Tags:              az.tags,
+		})
+		if err != nil {
+			return fmt.Errorf("managed identity %v: %w", role.Name, err)
+		}
+
+		az.identities[role.Name] = identity
+	}
+
+	for _, role := range az.config.Iam.Roles {
+		if role.Type != "roleassignment" {
+			continue
+		}
+
+		identity, ok := az.identities[role.Member]
+		if !ok {
+			return fmt.Errorf("role assignment %v: managed identity %v is not defined", role.Name, role.Member)
+		}
+
+		scope, scopeResource, err := az.scopeOf(role.Scope)
+		if err != nil {
+			return fmt.Errorf("role assignment %v: %w", role.Name, err)
+		}
+
+		_, err = authorization.NewAssignment(az.ctx, az.config.LogicalName(role.Name), &authorization.AssignmentArgs{
+			Scope:              scope,
+			RoleDefinitionName: pulumi.String(role.Role),
+			PrincipalId:        identity.PrincipalId,
+			PrincipalType:      pulumi.String("ServicePrincipal"),
+		}, pulumi.DependsOn([]pulumi.Resource{identity, scopeResource}))
+		if err != nil {
+			return fmt.Errorf("role assignment %v: %w", role.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Dependencies returns what each instruction needs and produces on Azure according to given values.
+// Ex: createStream needs the Blob container if destination is "blob" and the Azure Data Explorer table if destination is "adx".
+func Dependencies(config types.Config) map[string]types.Dependency {
+
+	stream := types.Dependency{
+		Produces: []types.Resource{types.StreamResource},
+	}
+
+	if config.Stream.Destination == "blob" {
+		stream.Needs = append(stream.Needs, types.StorageResource)
+	} else if config.Stream.Destination == "adx" {
+		stream.Needs = append(stream.Needs, types.DwhResource)
+	}
+
+	iam := types.Dependency{
+		Produces: []types.Resource{types.RolesResource},
+	}
+
+	function := types.Dependency{
+		Needs:    []types.Resource{types.StorageResource, types.StreamResource},
+		Produces: []types.Resource{types.FunctionResource},
+	}
+
+	scopes := map[string]types.Resource{"storage": types.StorageResource, "stream": types.StreamResource}
+	// needed keeps the resources that are added to the needs of iam and function, roles are only needed by function
+	needed := map[types.Resource]bool{}
+
+	for _, role := range config.Iam.Roles {
+		if role.Type == "managedidentity" && !needed[types.RolesResource] {
+			needed[types.RolesResource] = true
+			function.Needs = append(function.Needs, types.RolesResource)
+		} else if resource, ok := scopes[role.Scope]; ok && role.Type == "roleassignment" && !needed[resource] {
+			needed[resource] = true
+			iam.Needs = append(iam.Needs, resource)
+		}
+	}
+
+	return map[string]types.Dependency{
+		"configureIAM":             iam,
+		"createVpc":                {},
+		"createStorage":            {Produces: []types.Resource{types.StorageResource}},
+		"createDWH":                {Produces: []types.Resource{types.DwhResource}},
+		"createStream":             stream,
+		"createFunction":           function,
+		"createIdentityManagement": {},
+		"createApiGateway":         {Needs: []types.Resource{types.FunctionResource}},
+	}
+}
+
+// resolve resolves the references to outputs of created resources in value, such as ${outputs.eventhub.name}
+func (az *Azure) resolve(value string) (pulumi.StringOutput, error) {
+	return interpolate.Output(value, az.references)
+}
+
+// Context returns the pulumi context that resources are registered on.
+func (az *Azure) Context() *pulumi.Context {
+	return az.ctx
+}
+
+// Config returns the config that resources are created from.
+func (az *Azure) Config() types.Config {
+	return az.config
+}
+
+// Outputs returns the values that are exported by created resources such as urls.
+func (az *Azure) Outputs() pulumi.Map {
+	return az.outputs
+}
+
+// Resources returns the created Storage Account, Data Explorer database, Event Hub and Function App.
+func (az *Azure) Resources() map[types.Resource]pulumi.Resource {
+
+	resources := map[types.Resource]pulumi.Resource{}
+
+	if az.storageAccount != nil {
+		resources[types.StorageResource] = az.storageAccount
+	}
+	if az.kustoDatabase != nil {
+		resources[types.DwhResource] = az.kustoDatabase
+	}
+	if az.eventHub != nil {
+		resources[types.StreamResource] = az.eventHub
+	}
+	if az.functionApp != nil {
+		resources[types.FunctionResource] = az.functionApp
+	}
+
+	return resources
+}
+
+// References returns a copy of the values that can be used in config as ${outputs.<name>}.
+func (az *Azure) References() map[string]pulumi.StringOutput {
+	return maps.Clone(az.references)
+}
+
+// Contract returns the values of the outputs contract, the ingest endpoint is API Management if it is created, otherwise the function app.
+func (az *Azure) Contract() contract.Values {
+	return az.contract
+}
+
+// New returns Azure struct
+func New(ctx *pulumi.Context, yamlConf types.Config) *Azure {
+	conf := config.New(ctx, "azure")
+	location := conf.Require("location")
+
+	return &Azure{ctx: ctx, outputs: pulumi.Map{}, references: map[string]pulumi.StringOutput{}, location: location, config: yamlConf,
+		namer: naming.New(yamlConf, ctx.Project(), ctx.Stack()), tags: pulumi.ToStringMap(tags.New(yamlConf, ctx.Stack()))}
+}
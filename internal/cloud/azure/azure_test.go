@@ -0,0 +1,498 @@
+package azure
+
+import (
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/pulumi/pulumi-azure/sdk/v5/go/azure/eventhub"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/stretchr/testify/suite"
+	"sync"
+	"testing"
+)
+
+type testSuite struct {
+	suite.Suite
+	config types.Config
+}
+
+func (ts *testSuite) SetupSuite() {
+
+	config := types.Config{
+		ResourceGroup: "test-rg",
+		Storage: types.Storage{
+			Name:        "teststorage",
+			AccountTier: "Standard",
+			Replication: "LRS",
+			Bucket: types.Bucket{
+				Name: "events",
+			},
+		},
+		Stream: types.Stream{
+			Name:        "test-hub",
+			Destination: "blob",
+			EventHubConf: types.EventHubConf{
+				Namespace: types.EventHubNamespace{
+					Name:     "test-namespace",
+					Sku:      "Standard",
+					Capacity: 1,
+				},
+				PartitionCount:   2,
+				MessageRetention: 1,
+				Capture: types.Capture{
+					Encoding:          "Avro",
+					Interval:          300,
+					SizeLimit:         314572800,
+					ArchiveNameFormat: "{Namespace}/{EventHub}/{PartitionId}/{Year}/{Month}/{Day}/{Hour}/{Minute}/{Second}",
+				},
+			},
+		},
+		Dwh: types.Dwh{
+			Adx: types.Adx{
+				Cluster:          "testcluster",
+				Sku:              "Dev(No SLA)_Standard_E2a_v4",
+				Capacity:         1,
+				Database:         "test-db",
+				Table:            "events",
+				HotCachePeriod:   "P7D",
+				SoftDeletePeriod: "P31D",
+				Schema:           `[{"name": "game_name", "type": "STRING"}, {"name": "score", "type": "INTEGER"}, {"name": "data", "type": "JSON"}]`,
+			},
+		},
+		Iam: types.Iam{
+			Roles: []types.Roles{
+				{
+					Name: "test-identity",
+					Type: "managedidentity",
+				},
+				{
+					Name:   "test-sender",
+					Type:   "roleassignment",
+					Role:   "Azure Event Hubs Data Sender",
+					Member: "test-identity",
+					Scope:  "storage",
+				},
+			},
+		}}
+
+	ts.config = config
+}
+
+type mocks int
+
+func (m mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	return args.Args, nil
+}
+
+func (m mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	return args.Name + "_id", args.Inputs, nil
+}
+
+// recorder keeps the inputs of registered resources by name for the resources that are not kept in Azure struct
+type recorder struct {
+	mocks
+	mu     sync.Mutex
+	inputs map[string]resource.PropertyMap
+}
+
+func newRecorder() *recorder {
+	return &recorder{inputs: map[string]resource.PropertyMap{}}
+}
+
+func (r *recorder) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	outputs := args.Args.Copy()
+
+	// Hash of the zipped source code is given by the archive provider
+	if args.Token == "archive:index/getFile:getFile" {
+		outputs["outputBase64sha256"] = resource.NewStringProperty("source-hash")
+	}
+
+	return outputs, nil
+}
+
+func (r *recorder) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	r.mu.Lock()
+	r.inputs[args.Name] = args.Inputs
+	r.mu.Unlock()
+
+	return r.mocks.NewResource(args)
+}
+
+// withLocation gives azure:location config that is required by New
+func withLocation(info *pulumi.RunInfo) {
+	info.Config = map[string]string{"azure:location": "westeurope"}
+}
+
+func (ts *testSuite) TestCreateStorage() {
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		azure := New(ctx, ts.config)
+		err := azure.CreateStorage()
+
+		ts.NoError(err)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		pulumi.All(azure.storageAccount.Name, azure.storageAccount.ResourceGroupName, azure.container.Name).ApplyT(func(data []interface{}) error {
+			ts.Equal("teststorage", data[0])
+			ts.Equal("test-rg", data[1])
+			ts.Equal("events", data[2])
+			wg.Done()
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)), withLocation)
+	ts.NoError(err)
+}
+
+func (ts *testSuite) TestCreateStorageAndStreamWithNamingPattern() {
+	config := ts.config
+	config.Env = "prod"
+	config.Naming = types.Naming{Pattern: "{project}-{env}-{component}"}
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		azure := New(ctx, config)
+		ts.NoError(azure.CreateStorage())
+		ts.NoError(azure.CreateStream())
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		// Separators are removed from the storage account name, it only accepts lowercase letters and numbers
+		pulumi.All(azure.storageAccount.Name, azure.storageAccount.ResourceGroupName, azure.container.Name, azure.namespace.Name, azure.eventHub.Name).ApplyT(func(data []interface{}) error {
+			ts.Equal("projectprodteststorage", data[0])
+			ts.Equal("project-prod-test-rg", data[1])
+			ts.Equal("project-prod-events", data[2])
+			ts.Equal("project-prod-test-namespace", data[3])
+			ts.Equal("project-prod-test-hub", data[4])
+			wg.Done()
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)), withLocation)
+	ts.NoError(err)
+
+	// Names that break the rules of the service are rejected before anything is created
+	config.Storage.Name = "test-storage-account"
+
+	err = pulumi.RunErr(func(ctx *pulumi.Context) error {
+		ts.EqualError(New(ctx, config).CreateStorage(), `storage account test-storage-account: storage account name "projectprodteststorageaccount" is 29 characters, it must be between 3 and 24`)
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)), withLocation)
+	ts.NoError(err)
+}
+
+func (ts *testSuite) TestCreateStorageAndStreamWithTags() {
+	config := ts.config
+	config.Name = "studio-a"
+	config.Env = "prod"
+	config.Template = types.Template{Name: "data-pipeline"}
+	config.Tags = map[string]string{"CostCenter": "Games"}
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		azure := New(ctx, config)
+		ts.NoError(azure.CreateStorage())
+		ts.NoError(azure.CreateStream())
+		ts.NoError(azure.ConfigureIAM())
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		// Tags in config are added with env, stack, template and pipeline, case of the keys is kept
+		pulumi.All(azure.resourceGroup.Tags, azure.storageAccount.Tags, azure.namespace.Tags, azure.identities["test-identity"].Tags).ApplyT(func(data []interface{}) error {
+			expected := map[string]string{"CostCenter": "Games", "env": "prod", "stack": "stack", "template": "data-pipeline", "pipeline": "studio-a"}
+			for _, tags := range data {
+				ts.Equal(expected, tags)
+			}
+			wg.Done()
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)), withLocation)
+	ts.NoError(err)
+}
+
+func (ts *testSuite) TestCreateStreamWithCapture() {
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		azure := New(ctx, ts.config)
+		ts.NoError(azure.CreateStorage())
+
+		err := azure.CreateStream()
+
+		ts.NoError(err)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		pulumi.All(azure.eventHub.Name, azure.eventHub.NamespaceName, azure.eventHub.CaptureDescription).ApplyT(func(data []interface{}) error {
+			ts.Equal("test-hub", data[0])
+			ts.Equal("test-namespace", data[1])
+
+			capture := data[2].(*eventhub.EventHubCaptureDescription)
+			ts.True(capture.Enabled)
+			ts.Equal("events", capture.Destination.BlobContainerName)
+			ts.Equal("teststorage_id", capture.Destination.StorageAccountId)
+			wg.Done()
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)), withLocation)
+	ts.NoError(err)
+}
+
+func (ts *testSuite) TestConfigureIAM() {
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		azure := New(ctx, ts.config)
+		ts.NoError(azure.CreateStorage())
+
+		err := azure.ConfigureIAM()
+
+		ts.NoError(err)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		pulumi.All(azure.identities["test-identity"].Name, azure.identities["test-identity"].Location).ApplyT(func(data []interface{}) error {
+			ts.Equal("test-identity", data[0])
+			ts.Equal("westeurope", data[1])
+			wg.Done()
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)), withLocation)
+	ts.NoError(err)
+}
+
+func (ts *testSuite) TestConfigureIAMUnknownMember() {
+	config := ts.config
+	config.Iam = types.Iam{
+		Roles: []types.Roles{{Name: "test-sender", Type: "roleassignment", Member: "missing", Scope: "storage"}},
+	}
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		azure := New(ctx, config)
+		err := azure.ConfigureIAM()
+
+		ts.EqualError(err, "role assignment test-sender: managed identity missing is not defined")
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)), withLocation)
+	ts.NoError(err)
+}
+
+func (ts *testSuite) TestCreateDWH() {
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		azure := New(ctx, ts.config)
+		err := azure.CreateDWH()
+
+		ts.NoError(err)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		pulumi.All(azure.kustoCluster.Name, azure.kustoDatabase.ClusterName, azure.kustoScript.ScriptContent).ApplyT(func(data []interface{}) error {
+			ts.Equal("testcluster", data[0])
+			ts.Equal("testcluster", data[1])
+			ts.Equal(".create-merge table events (game_name:string, score:long, data:dynamic)\n\n"+
+				`.create-or-alter table events ingestion json mapping "events_mapping" '[{"column":"game_name","path":"$.game_name","datatype":"string"},{"column":"score","path":"$.score","datatype":"long"},{"column":"data","path":"$.data","datatype":"dynamic"}]'`+"\n", *data[2].(*string))
+			wg.Done()
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)), withLocation)
+	ts.NoError(err)
+}
+
+func (ts *testSuite) TestCreateDWHInvalidSchema() {
+	config := ts.config
+	config.Dwh.Adx.Schema = "{"
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		azure := New(ctx, config)
+		err := azure.CreateDWH()
+
+		ts.ErrorContains(err, "adx table events: schema:")
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)), withLocation)
+	ts.NoError(err)
+}
+
+func (ts *testSuite) TestCreateStreamWithDataConnection() {
+	config := ts.config
+	config.Stream.Destination = "adx"
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		azure := New(ctx, config)
+		ts.NoError(azure.CreateDWH())
+
+		err := azure.CreateStream()
+
+		ts.NoError(err)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		pulumi.All(azure.dataConnection.EventhubId, azure.dataConnection.DatabaseName, azure.dataConnection.TableName, azure.dataConnection.MappingRuleName, azure.eventHub.CaptureDescription).ApplyT(func(data []interface{}) error {
+			ts.Equal("test-hub_id", data[0])
+			ts.Equal("test-db", data[1])
+			ts.Equal("events", *data[2].(*string))
+			ts.Equal("events_mapping", *data[3].(*string))
+			ts.Nil(data[4])
+			wg.Done()
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)), withLocation)
+	ts.NoError(err)
+}
+
+// functionConfig returns the config of a function app behind API Management
+func (ts *testSuite) functionConfig() types.Config {
+	config := ts.config
+	config.Function = types.Function{
+		Name: "test-func",
+		Build: types.Build{
+			Runtime:        "node",
+			RuntimeVersion: "18",
+			Source:         &types.Source{Zip: "app", OutputPath: "app.zip"},
+			Envs:           map[string]string{"TOPIC": "${outputs.eventhub.name}"},
+		},
+	}
+	config.APIGateway = types.APIGateway{
+		Name:           "test-apim",
+		Sku:            "Developer_1",
+		PublisherName:  "test",
+		PublisherEmail: "test@example.com",
+		Routes: []types.Routes{{
+			Name: "events",
+			Integrations: []types.Integrations{{
+				Name:   "send",
+				URI:    "/send",
+				Method: types.Method{Name: "Send", Type: "POST", Response: types.Response{StatusCode: "200"}},
+			}},
+		}},
+	}
+	return config
+}
+
+func (ts *testSuite) TestCreateFunction() {
+	recorder := newRecorder()
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		azure := New(ctx, ts.functionConfig())
+		ts.NoError(azure.CreateStorage())
+		ts.NoError(azure.ConfigureIAM())
+		ts.NoError(azure.CreateStream())
+
+		return azure.CreateFunction()
+	}, pulumi.WithMocks("project", "stack", recorder), withLocation)
+	ts.NoError(err)
+
+	plan := recorder.inputs["test-func-plan"]
+	ts.Equal("Linux", plan["osType"].StringValue())
+	ts.Equal("Y1", plan["skuName"].StringValue())
+
+	app := recorder.inputs["test-func"]
+	ts.Equal("test-func-plan_id", app["servicePlanId"].StringValue())
+	ts.Equal("teststorage", app["storageAccountName"].StringValue())
+	ts.Equal("app.zip", app["zipDeployFile"].StringValue())
+
+	// Package hash is given so the app is updated when the source code changes
+	settings := app["appSettings"].ObjectValue()
+	ts.Equal("node", settings["FUNCTIONS_WORKER_RUNTIME"].StringValue())
+	ts.Equal("1", settings["WEBSITE_RUN_FROM_PACKAGE"].StringValue())
+	ts.Equal("source-hash", settings["PACKAGE_SHA256"].StringValue())
+	ts.Equal("test-hub", settings["EVENTHUB_NAME"].StringValue())
+	ts.Equal("test-namespace.servicebus.windows.net", settings["EVENTHUB_NAMESPACE"].StringValue())
+	ts.Equal("test-hub", settings["TOPIC"].StringValue())
+
+	identity := app["identity"].ObjectValue()
+	ts.Equal("UserAssigned", identity["type"].StringValue())
+	ts.Equal([]resource.PropertyValue{resource.NewStringProperty("test-identity_id")}, identity["identityIds"].ArrayValue())
+}
+
+func (ts *testSuite) TestCreateApiGateway() {
+	recorder := newRecorder()
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		azure := New(ctx, ts.functionConfig())
+		ts.NoError(azure.CreateStorage())
+		ts.NoError(azure.CreateStream())
+		ts.NoError(azure.CreateFunction())
+
+		return azure.CreateApiGateway()
+	}, pulumi.WithMocks("project", "stack", recorder), withLocation)
+	ts.NoError(err)
+
+	service := recorder.inputs["test-apim"]
+	ts.Equal("Developer_1", service["skuName"].StringValue())
+
+	// Every route is an API on the service whose backend is the function app
+	api := recorder.inputs["events"]
+	ts.Equal("test-apim", api["apiManagementName"].StringValue())
+	ts.Equal("events", api["path"].StringValue())
+	ts.False(api["subscriptionRequired"].BoolValue())
+
+	// Every integration is an operation of the API of its route
+	operation := recorder.inputs["events-send"]
+	ts.Equal("events", operation["apiName"].StringValue())
+	ts.Equal("test-apim", operation["apiManagementName"].StringValue())
+	ts.Equal("POST", operation["method"].StringValue())
+	ts.Equal("/send", operation["urlTemplate"].StringValue())
+	ts.Equal(float64(200), operation["responses"].ArrayValue()[0].ObjectValue()["statusCode"].NumberValue())
+}
+
+func (ts *testSuite) TestCreateStreamWithoutContainer() {
+	config := ts.config
+	config.Storage.Bucket.Name = ""
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		azure := New(ctx, config)
+		ts.NoError(azure.CreateStorage())
+
+		err := azure.CreateStream()
+
+		ts.EqualError(err, "event hub test-hub: blob destination needs a container, storage.bucket.name must be given")
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)), withLocation)
+	ts.NoError(err)
+}
+
+func (ts *testSuite) TestDependencies() {
+	config := ts.config
+	config.Iam.Roles = append(config.Iam.Roles, types.Roles{Name: "test-reader", Type: "managedidentity"})
+
+	dependencies := Dependencies(config)
+
+	// Roles are needed once however many identities are given
+	ts.Equal([]types.Resource{types.StorageResource, types.StreamResource, types.RolesResource}, dependencies["createFunction"].Needs)
+	ts.Equal([]types.Resource{types.StorageResource}, dependencies["configureIAM"].Needs)
+}
+
+func TestRunSuite(t *testing.T) {
+	suite.Run(t, &testSuite{})
+}
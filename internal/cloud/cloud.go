@@ -1,50 +1,211 @@
 package cloud
 
 import (
+	"errors"
+	"fmt"
 	"github.com/cemayan/pulumi-template/internal/cloud/aws"
+	"github.com/cemayan/pulumi-template/internal/cloud/azure"
 	"github.com/cemayan/pulumi-template/internal/cloud/gcp"
+	"github.com/cemayan/pulumi-template/internal/contract"
+	"github.com/cemayan/pulumi-template/pipeline"
 	"github.com/cemayan/pulumi-template/types"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
-	"github.com/spf13/viper"
 )
 
-// CloudInstance gives global variable that is selected cloud
-var CloudInstance Cloud
-
-// Cloud represents the methods that needs implement on selected cloud
-// Each method do same job on different cloud.
-// Ex: If selected cloud is AWS, CreateStorage method will be created the S3 bucket according to given values.
-// If selected cloud is GCP, CreateStorage method will be created the Cloud Storage according to given values.
+// Cloud represents the pipeline.Cloud of the selected cloud with the values of the outputs contract
 type Cloud interface {
-	CreateStorage() error
-	CreateDWH() error
-	CreateStream() error
-	CreateApiGateway() error
-	CreateVpc() error
-	ConfigureIAM() error
-	CreateFunction() error
-	CreateIdentityManagement() error
+	pipeline.Cloud
+	Contract() contract.Values
+}
+
+// Builder builds a pipeline on the selected cloud according to given config.
+// Each builder owns its provider instance and instructions, so several pipelines can be built in the same program.
+type Builder struct {
+	cloud        Cloud
+	config       types.Config
+	instructions []pipeline.Instruction
+}
+
+// NewBuilder creates new builder according to given config
+// Instructions are looked up in the registry and sorted according to their dependencies before anything is created,
+// so the config is rejected up front if the cloud or an instruction is unknown or an instruction needs something that is not produced.
+func NewBuilder(ctx *pulumi.Context, config types.Config) (*Builder, error) {
+
+	cloud, err := newCloud(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return newBuilder(cloud, config)
+}
+
+// newBuilder creates new builder with given cloud instance
+func newBuilder(cloud Cloud, config types.Config) (*Builder, error) {
+
+	instructions, err := resolveInstructions(config.Template.Instructions, types.CloudMap[config.Cloud], config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Builder{cloud: cloud, config: config, instructions: instructions}, nil
+}
+
+// Cloud returns the cloud instance that instructions are executed on
+func (b *Builder) Cloud() Cloud {
+	return b.cloud
+}
+
+// Instructions returns the names of the instructions in execution order
+func (b *Builder) Instructions() []string {
+	names := []string{}
+	for _, instruction := range b.instructions {
+		names = append(names, instruction.Name)
+	}
+	return names
+}
+
+// Build executes instructions in dependency order and exports the outputs of created resources.
+// If template.on_error is "continue", every instruction is executed and failures are returned together,
+// otherwise Build stops at the first failed instruction.
+// Instructions that need what a failed instruction produces are skipped and reported, since the resources they use were not created.
+// Outputs of a pipeline that is an entry of pipelines are exported under the pipeline name.
+func (b *Builder) Build() error {
+
+	failFast := b.config.Template.OnError != types.ContinueOnError
+	cloud := types.CloudMap[b.config.Cloud]
+
+	var errs []error
+
+	// failed gives the instruction that failed to produce a resource
+	failed := map[types.Resource]string{}
+
+	for _, instruction := range b.instructions {
+		dependency := instruction.Dependency(cloud, b.config)
+
+		producer := ""
+		for _, need := range dependency.Needs {
+			if name, ok := failed[need]; ok {
+				producer = name
+				break
+			}
+		}
+
+		if producer != "" {
+			errs = append(errs, fmt.Errorf("%v: skipped: %v failed", instruction.Name, producer))
+			for _, resource := range dependency.Produces {
+				failed[resource] = producer
+			}
+			continue
+		}
+
+		if err := instruction.Run(b.cloud); err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", instruction.Name, err))
+			if failFast {
+				break
+			}
+			for _, resource := range dependency.Produces {
+				failed[resource] = instruction.Name
+			}
+		}
+	}
+
+	b.export()
+
+	return errors.Join(errs...)
 }
 
-// Build executes functions according to given instructions array in yaml.
-func Build() error {
-	template := viper.GetStringMap("template")
-	instructions := template["instructions"].([]interface{})
-	for _, f := range GetFunctionMap(instructions) {
-		f()
+// export exports the outputs of created resources with the values of the outputs contract (types.StackOutputs)
+func (b *Builder) export() {
+
+	outputs := pulumi.Map{}
+	for name, output := range b.cloud.Outputs() {
+		outputs[name] = output
+	}
+	for name, output := range b.cloud.Contract().Map() {
+		outputs[name] = output
+	}
+
+	if len(outputs) == 0 {
+		return
 	}
-	return nil
+
+	if b.config.Name != "" {
+		b.cloud.Context().Export(b.config.Name, outputs)
+		return
+	}
+
+	for name, output := range outputs {
+		b.cloud.Context().Export(name, output)
+	}
+}
+
+// Pipelines returns the pipeline configs that are defined in given config.
+// If pipelines is not given, the config itself is the only pipeline.
+// Otherwise each entry must have an unique name, env and naming are inherited from the top level if they are not given in the entry.
+// Tags of the top level are added to the tags of each entry, the entry wins if both have the same key.
+func Pipelines(config types.Config) ([]types.Config, error) {
+
+	if len(config.Pipelines) == 0 {
+		return []types.Config{config}, nil
+	}
+
+	if len(config.Template.Instructions) > 0 {
+		return nil, errors.New("template can not be given together with pipelines, move it into a pipeline")
+	}
+
+	names := map[string]bool{}
+	pipelines := []types.Config{}
+
+	for i, pipeline := range config.Pipelines {
+		if pipeline.Name == "" {
+			return nil, fmt.Errorf("pipelines[%v]: name is required", i)
+		}
+
+		if names[pipeline.Name] {
+			return nil, fmt.Errorf("pipelines[%v]: pipeline %v is defined more than once", i, pipeline.Name)
+		}
+		names[pipeline.Name] = true
+
+		if len(pipeline.Pipelines) > 0 {
+			return nil, fmt.Errorf("pipelines[%v]: pipeline %v can not have pipelines", i, pipeline.Name)
+		}
+
+		if pipeline.Env == "" {
+			pipeline.Env = config.Env
+		}
+
+		if pipeline.Naming.Pattern == "" && len(pipeline.Naming.Patterns) == 0 && pipeline.Naming.Project == "" && !pipeline.Naming.Suffix {
+			pipeline.Naming = config.Naming
+		}
+
+		if len(config.Tags) > 0 {
+			tags := map[string]string{}
+			for key, value := range config.Tags {
+				tags[key] = value
+			}
+			for key, value := range pipeline.Tags {
+				tags[key] = value
+			}
+			pipeline.Tags = tags
+		}
+
+		pipelines = append(pipelines, pipeline)
+	}
+
+	return pipelines, nil
 }
 
-// Initialize creates new instance according to given provider
-func Initialize(cloudProvider types.CloudProvider, ctx *pulumi.Context, config types.Config) {
+// newCloud creates new instance according to cloud that is given in config
+func newCloud(ctx *pulumi.Context, config types.Config) (Cloud, error) {
 
-	switch cloudProvider {
+	switch types.CloudMap[config.Cloud] {
 	case types.Aws:
-		CloudInstance = aws.New(ctx, config)
+		return aws.New(ctx, config), nil
 	case types.Gcp:
-		CloudInstance = gcp.New(ctx, config)
+		return gcp.New(ctx, config), nil
+	case types.Azure:
+		return azure.New(ctx, config), nil
 	default:
-		panic("unhandled default case")
+		return nil, fmt.Errorf("cloud %q is not supported, valid clouds are: aws, gcp, azure", config.Cloud)
 	}
 }
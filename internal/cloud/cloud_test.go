@@ -0,0 +1,176 @@
+package cloud
+
+import (
+	"errors"
+	"github.com/cemayan/pulumi-template/internal/contract"
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+// fakeCloud records executed instructions and fails the ones given in failures
+type fakeCloud struct {
+	config   types.Config
+	executed []string
+	failures map[string]error
+}
+
+func (f *fakeCloud) run(name string) error {
+	f.executed = append(f.executed, name)
+	return f.failures[name]
+}
+
+func (f *fakeCloud) CreateStorage() error            { return f.run("createStorage") }
+func (f *fakeCloud) CreateDWH() error                { return f.run("createDWH") }
+func (f *fakeCloud) CreateStream() error             { return f.run("createStream") }
+func (f *fakeCloud) CreateApiGateway() error         { return f.run("createApiGateway") }
+func (f *fakeCloud) CreateVpc() error                { return f.run("createVpc") }
+func (f *fakeCloud) ConfigureIAM() error             { return f.run("configureIAM") }
+func (f *fakeCloud) CreateFunction() error           { return f.run("createFunction") }
+func (f *fakeCloud) CreateIdentityManagement() error { return f.run("createIdentityManagement") }
+func (f *fakeCloud) Context() *pulumi.Context        { return nil }
+func (f *fakeCloud) Config() types.Config            { return f.config }
+func (f *fakeCloud) Outputs() pulumi.Map             { return pulumi.Map{} }
+func (f *fakeCloud) Contract() contract.Values       { return contract.Values{} }
+func (f *fakeCloud) Resources() map[types.Resource]pulumi.Resource {
+	return map[types.Resource]pulumi.Resource{}
+}
+func (f *fakeCloud) References() map[string]pulumi.StringOutput {
+	return map[string]pulumi.StringOutput{}
+}
+
+type cloudTestSuite struct {
+	suite.Suite
+	config types.Config
+	fake   *fakeCloud
+}
+
+func (ts *cloudTestSuite) SetupTest() {
+	ts.config = types.Config{
+		Cloud:    "aws",
+		Template: types.Template{Instructions: []string{"createFunction", "configureIAM", "createStorage", "createStream"}},
+		Stream:   types.Stream{Destination: "s3"},
+	}
+
+	ts.fake = &fakeCloud{failures: map[string]error{
+		"createStorage":  errors.New("s3 bucket test-bucket: access denied"),
+		"createFunction": errors.New("lambda function test-lambda: invalid runtime"),
+	}}
+}
+
+func (ts *cloudTestSuite) build() error {
+	ts.fake.config = ts.config
+
+	builder, err := newBuilder(ts.fake, ts.config)
+	if err != nil {
+		return err
+	}
+
+	return builder.Build()
+}
+
+func (ts *cloudTestSuite) TestBuildFailsFastByDefault() {
+	err := ts.build()
+
+	ts.EqualError(err, "createStorage: s3 bucket test-bucket: access denied")
+	ts.Equal([]string{"configureIAM", "createStorage"}, ts.fake.executed)
+}
+
+func (ts *cloudTestSuite) TestBuildContinuesAndReportsEveryError() {
+	ts.config.Template.OnError = types.ContinueOnError
+
+	err := ts.build()
+
+	// createStream needs the bucket and createFunction needs the stream, so they are skipped
+	ts.EqualError(err, "createStorage: s3 bucket test-bucket: access denied\ncreateStream: skipped: createStorage failed\ncreateFunction: skipped: createStorage failed")
+	ts.Equal([]string{"configureIAM", "createStorage"}, ts.fake.executed)
+}
+
+func (ts *cloudTestSuite) TestBuildContinuesWithInstructionsThatDoNotNeedFailedOnes() {
+	ts.config.Template.OnError = types.ContinueOnError
+	ts.config.Template.Instructions = []string{"configureIAM", "createStorage", "createDWH", "createStream"}
+	ts.config.Stream.Destination = "redshift"
+	ts.fake.failures = map[string]error{"configureIAM": errors.New("iam role firehose: access denied")}
+
+	err := ts.build()
+
+	// Every instruction needs the roles except createStorage
+	ts.EqualError(err, "configureIAM: iam role firehose: access denied\ncreateDWH: skipped: configureIAM failed\ncreateStream: skipped: configureIAM failed")
+	ts.Equal([]string{"configureIAM", "createStorage"}, ts.fake.executed)
+}
+
+func (ts *cloudTestSuite) TestBuildRejectsUnknownInstructionBeforeExecuting() {
+	ts.config.Template.Instructions = []string{"createStorage", "createMonitoring"}
+
+	err := ts.build()
+
+	ts.ErrorContains(err, "instruction createMonitoring is not defined, valid instructions for aws are: configureIAM, createApiGateway")
+	ts.Empty(ts.fake.executed)
+}
+
+func (ts *cloudTestSuite) TestBuildersDoNotShareState() {
+	other := &fakeCloud{config: types.Config{Cloud: "aws", Template: types.Template{Instructions: []string{"createStorage"}}}}
+
+	first, err := newBuilder(ts.fake, ts.config)
+	ts.NoError(err)
+
+	second, err := newBuilder(other, other.config)
+	ts.NoError(err)
+
+	ts.NoError(second.Build())
+	ts.Error(first.Build())
+
+	ts.Equal([]string{"createStorage"}, other.executed)
+	ts.Equal([]string{"configureIAM", "createStorage"}, ts.fake.executed)
+}
+
+func (ts *cloudTestSuite) TestNewBuilderRejectsUnknownCloud() {
+	_, err := NewBuilder(nil, types.Config{Cloud: "oracle"})
+
+	ts.EqualError(err, `cloud "oracle" is not supported, valid clouds are: aws, gcp, azure`)
+}
+
+func (ts *cloudTestSuite) TestPipelinesInheritEnv() {
+	pipelines, err := Pipelines(types.Config{
+		Env:    "production",
+		Naming: types.Naming{Pattern: "{project}-{env}-{component}"},
+		Tags:   map[string]string{"team": "data", "cost_center": "games"},
+		Pipelines: []types.Config{
+			{Name: "studio-a", Cloud: "aws"},
+			{Name: "studio-b", Cloud: "gcp", Env: "development", Naming: types.Naming{Suffix: true}, Tags: map[string]string{"team": "studio-b"}},
+		},
+	})
+
+	ts.NoError(err)
+	ts.Len(pipelines, 2)
+	ts.Equal("production", pipelines[0].Env)
+	ts.Equal("development", pipelines[1].Env)
+	ts.Equal("{project}-{env}-{component}", pipelines[0].Naming.Pattern)
+	ts.Equal(types.Naming{Suffix: true}, pipelines[1].Naming)
+	ts.Equal(map[string]string{"team": "data", "cost_center": "games"}, pipelines[0].Tags)
+	ts.Equal(map[string]string{"team": "studio-b", "cost_center": "games"}, pipelines[1].Tags)
+	ts.Equal("studio-a-events", pipelines[0].LogicalName("events"))
+}
+
+func (ts *cloudTestSuite) TestPipelinesWithoutList() {
+	pipelines, err := Pipelines(ts.config)
+
+	ts.NoError(err)
+	ts.Equal([]types.Config{ts.config}, pipelines)
+	ts.Equal("events", pipelines[0].LogicalName("events"))
+}
+
+func (ts *cloudTestSuite) TestPipelinesRejectDuplicateNames() {
+	_, err := Pipelines(types.Config{Pipelines: []types.Config{{Name: "studio-a"}, {Name: "studio-a"}}})
+
+	ts.EqualError(err, "pipelines[1]: pipeline studio-a is defined more than once")
+
+	_, err = Pipelines(types.Config{Pipelines: []types.Config{{Cloud: "aws"}}})
+
+	ts.EqualError(err, "pipelines[0]: name is required")
+}
+
+func TestRunCloudSuite(t *testing.T) {
+	suite.Run(t, &cloudTestSuite{})
+}
@@ -1,13 +1,20 @@
 package gcp
 
 import (
+	"errors"
 	"fmt"
+	"github.com/cemayan/pulumi-template/components"
+	"github.com/cemayan/pulumi-template/internal/adopt"
+	"github.com/cemayan/pulumi-template/internal/contract"
+	"github.com/cemayan/pulumi-template/internal/interpolate"
+	"github.com/cemayan/pulumi-template/internal/lifecycle"
+	"github.com/cemayan/pulumi-template/internal/naming"
+	"github.com/cemayan/pulumi-template/internal/tags"
 	"github.com/cemayan/pulumi-template/types"
 	"github.com/pulumi/pulumi-gcp/sdk/v7/go/gcp/apigateway"
 	"github.com/pulumi/pulumi-gcp/sdk/v7/go/gcp/bigquery"
 	"github.com/pulumi/pulumi-gcp/sdk/v7/go/gcp/cloudfunctionsv2"
 	"github.com/pulumi/pulumi-gcp/sdk/v7/go/gcp/cloudrun"
-	"github.com/pulumi/pulumi-gcp/sdk/v7/go/gcp/iap"
 	"github.com/pulumi/pulumi-gcp/sdk/v7/go/gcp/organizations"
 	"github.com/pulumi/pulumi-gcp/sdk/v7/go/gcp/projects"
 	"github.com/pulumi/pulumi-gcp/sdk/v7/go/gcp/pubsub"
@@ -17,12 +24,18 @@ import (
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
 	yaml "gopkg.in/yaml.v3"
+	"maps"
 	"os"
 )
 
 // Gcp represents the GCP related resources and configs
 type Gcp struct {
 	ctx                     *pulumi.Context
+	outputs                 pulumi.Map
+	contract                contract.Values
+	references              map[string]pulumi.StringOutput
+	namer                   *naming.Namer
+	labels                  pulumi.StringMap
 	region                  string
 	project                 string
 	bucket                  *storage.Bucket
@@ -33,50 +46,69 @@ type Gcp struct {
 	functionSourceBucketObj *storage.BucketObject
 	serviceAcc              *serviceaccount.Account
 	config                  types.Config
-	oauth2Client            *iap.Client
 }
 
 func (g *Gcp) CreateIdentityManagement() error {
-	panic("not implemented")
+	return errors.New("identity management is not implemented on gcp")
 }
 
 // createServiceAccount creates a service account for cloud function
 func (g *Gcp) createServiceAccount() (*serviceaccount.Account, error) {
 
-	account, err := serviceaccount.NewAccount(g.ctx, g.config.Iam.ServiceAcc.AccountID, &serviceaccount.AccountArgs{
-		AccountId:                 pulumi.String(g.config.Iam.ServiceAcc.AccountID),
+	accountId, err := g.namer.Name(naming.ServiceAccount, g.config.Iam.ServiceAcc.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("service account %v: %w", g.config.Iam.ServiceAcc.AccountID, err)
+	}
+
+	account, err := serviceaccount.NewAccount(g.ctx, g.config.LogicalName(g.config.Iam.ServiceAcc.AccountID), &serviceaccount.AccountArgs{
+		AccountId:                 pulumi.String(accountId),
 		DisplayName:               pulumi.String(g.config.Iam.ServiceAcc.DisplayName),
 		Project:                   pulumi.String(g.config.Iam.ServiceAcc.Project),
 		CreateIgnoreAlreadyExists: pulumi.Bool(true),
 	})
+	if err != nil {
+		return nil, fmt.Errorf("service account %v: %w", g.config.Iam.ServiceAcc.AccountID, err)
+	}
 
 	g.serviceAcc = account
 
-	return account, err
+	return account, nil
 }
 
 // createBucketForFunction creates bucket for function
 // it is used to get zipped function
 func (g *Gcp) createBucketForFunction() (*storage.Bucket, *storage.BucketObject, error) {
 
-	bucket, err := storage.NewBucket(g.ctx, g.config.Function.Build.Source.Storage.Bucket.Name, &storage.BucketArgs{
-		Name:                     pulumi.String(g.config.Function.Build.Source.Storage.Bucket.Name),
+	bucketName, err := g.namer.Name(naming.StorageBucket, g.config.Function.Build.Source.Storage.Bucket.Name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("function source bucket %v: %w", g.config.Function.Build.Source.Storage.Bucket.Name, err)
+	}
+
+	bucket, err := storage.NewBucket(g.ctx, g.config.LogicalName(g.config.Function.Build.Source.Storage.Bucket.Name), &storage.BucketArgs{
+		Name:                     pulumi.String(bucketName),
 		Location:                 pulumi.String(g.region),
 		UniformBucketLevelAccess: pulumi.Bool(true),
 		ForceDestroy:             pulumi.Bool(g.config.Function.Build.Source.Storage.ForceDestroy),
+		Labels:                   g.labels,
 	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("function source bucket %v: %w", g.config.Function.Build.Source.Storage.Bucket.Name, err)
+	}
 
 	g.functionSourceBucket = bucket
 
-	object, err := storage.NewBucketObject(g.ctx, g.config.Function.Build.Source.Storage.Bucket.Object.Name, &storage.BucketObjectArgs{
+	object, err := storage.NewBucketObject(g.ctx, g.config.LogicalName(g.config.Function.Build.Source.Storage.Bucket.Object.Name), &storage.BucketObjectArgs{
 		Name:   pulumi.String(g.config.Function.Build.Source.Storage.Bucket.Object.Name),
 		Bucket: bucket.Name,
 		Source: pulumi.NewFileAsset(g.config.Function.Build.Source.Storage.Bucket.Object.Path),
 	}, pulumi.DependsOn([]pulumi.Resource{bucket}))
+	if err != nil {
+		return nil, nil, fmt.Errorf("function source object %v: %w", g.config.Function.Build.Source.Storage.Bucket.Object.Name, err)
+	}
 
 	g.functionSourceBucketObj = object
 
-	return bucket, object, err
+	return bucket, object, nil
 }
 
 // CreateFunction creates Cloud function/Cloud Run according to given values
@@ -87,7 +119,19 @@ func (g *Gcp) createBucketForFunction() (*storage.Bucket, *storage.BucketObject,
 func (g *Gcp) CreateFunction() error {
 
 	if g.config.Iam.ServiceAcc != nil {
-		_, _ = g.createServiceAccount()
+		if _, err := g.createServiceAccount(); err != nil {
+			return err
+		}
+	}
+
+	functionName, err := g.namer.Name(naming.CloudFunction, g.config.Function.Name)
+	if err != nil {
+		return fmt.Errorf("cloud function %v: %w", g.config.Function.Name, err)
+	}
+
+	topicName, err := g.topicName()
+	if err != nil {
+		return fmt.Errorf("cloud function %v topic: %w", g.config.Function.Name, err)
 	}
 
 	buildArgs := &cloudfunctionsv2.FunctionBuildConfigArgs{
@@ -95,20 +139,32 @@ func (g *Gcp) CreateFunction() error {
 		EntryPoint: pulumi.String(g.config.Function.Build.EntryPoint),
 	}
 
+	envs := pulumi.StringMap{
+		"PROJECT_ID": pulumi.String(g.project),
+		"TOPIC_ID":   topicName,
+	}
+
+	for k, v := range g.config.Function.Build.Envs {
+		env, err := g.resolve(v)
+		if err != nil {
+			return fmt.Errorf("cloud function %v env %v: %w", g.config.Function.Name, k, err)
+		}
+		envs[k] = env
+	}
+
 	funcArgs := &cloudfunctionsv2.FunctionArgs{
-		Name:        pulumi.String(g.config.Function.Name),
+		Name:        pulumi.String(functionName),
 		Location:    pulumi.String(g.region),
 		Project:     pulumi.String(g.project),
 		BuildConfig: buildArgs,
+		Labels:      g.labels,
 
 		ServiceConfig: &cloudfunctionsv2.FunctionServiceConfigArgs{
-			MaxInstanceCount:    pulumi.Int(g.config.Function.ServiceConf.MaxInstance),
-			AvailableMemory:     pulumi.String(g.config.Function.ServiceConf.AvailableMem),
-			TimeoutSeconds:      pulumi.Int(g.config.Function.ServiceConf.Timeout),
-			ServiceAccountEmail: g.serviceAcc.Email,
-			EnvironmentVariables: pulumi.StringMap{
-				"PROJECT_ID": pulumi.String(g.project),
-				"TOPIC_ID":   pulumi.String(g.config.Stream.PubSubConf.Topic.Name)},
+			MaxInstanceCount:     pulumi.Int(g.config.Function.ServiceConf.MaxInstance),
+			AvailableMemory:      pulumi.String(g.config.Function.ServiceConf.AvailableMem),
+			TimeoutSeconds:       pulumi.Int(g.config.Function.ServiceConf.Timeout),
+			ServiceAccountEmail:  g.serviceAcc.Email,
+			EnvironmentVariables: envs,
 		},
 	}
 
@@ -128,7 +184,10 @@ func (g *Gcp) CreateFunction() error {
 	}
 
 	if g.config.Function.Build.Source != nil {
-		bucket, object, _ := g.createBucketForFunction()
+		bucket, object, err := g.createBucketForFunction()
+		if err != nil {
+			return err
+		}
 
 		buildArgs.Source = &cloudfunctionsv2.FunctionBuildConfigSourceArgs{
 			StorageSource: &cloudfunctionsv2.FunctionBuildConfigSourceStorageSourceArgs{
@@ -138,96 +197,177 @@ func (g *Gcp) CreateFunction() error {
 		}
 	}
 
-	function, err := cloudfunctionsv2.NewFunction(g.ctx, g.config.Function.Name, funcArgs, pulumi.DependsOn([]pulumi.Resource{g.functionSourceBucket, g.functionSourceBucketObj, g.serviceAcc}))
+	function, err := cloudfunctionsv2.NewFunction(g.ctx, g.config.LogicalName(g.config.Function.Name), funcArgs, lifecycle.Options(g.config, lifecycle.Function,
+		pulumi.DependsOn([]pulumi.Resource{g.functionSourceBucket, g.functionSourceBucketObj, g.serviceAcc}))...)
+	if err != nil {
+		return fmt.Errorf("cloud function %v: %w", g.config.Function.Name, err)
+	}
 
 	g.function = function
+	g.references["outputs.function.url"] = function.Url
 
-	g.configureRolesForFunction()
+	if err := g.configureRolesForFunction(); err != nil {
+		return err
+	}
 
-	g.ctx.Export("function_url", function.Url)
+	g.outputs["function_url"] = function.Url
 
-	return err
+	// API Gateway is the ingest endpoint if it is created, the function needs an identity token otherwise
+	if g.contract.IngestUrl == nil {
+		g.contract.IngestUrl = function.Url
+		g.contract.IngestAuthType = pulumi.String(types.AuthIam)
+	}
+
+	return nil
 }
 
 // CreateDWH creates BigQuery Dataset and Table according to given values
 // Initial schema will be created
 func (g *Gcp) CreateDWH() error {
 
-	dataset, err := bigquery.NewDataset(g.ctx, g.config.Dwh.BigQuery.Dataset, &bigquery.DatasetArgs{
-		DatasetId: pulumi.String(g.config.Dwh.BigQuery.Dataset),
+	datasetId, err := g.namer.Name(naming.BigQueryDataset, g.config.Dwh.BigQuery.Dataset)
+	if err != nil {
+		return fmt.Errorf("bigquery dataset %v: %w", g.config.Dwh.BigQuery.Dataset, err)
+	}
+
+	tableId, err := g.namer.Name(naming.BigQueryTable, g.config.Dwh.BigQuery.TableId)
+	if err != nil {
+		return fmt.Errorf("bigquery table %v: %w", g.config.Dwh.BigQuery.TableId, err)
+	}
+
+	dataset, err := bigquery.NewDataset(g.ctx, g.config.LogicalName(g.config.Dwh.BigQuery.Dataset), &bigquery.DatasetArgs{
+		DatasetId: pulumi.String(datasetId),
 		Location:  pulumi.String(g.region),
-	})
+		Labels:    g.labels,
+	}, lifecycle.Options(g.config, lifecycle.Dwh)...)
+	if err != nil {
+		return fmt.Errorf("bigquery dataset %v: %w", g.config.Dwh.BigQuery.Dataset, err)
+	}
 
-	table, err := bigquery.NewTable(g.ctx, g.config.Dwh.BigQuery.TableId, &bigquery.TableArgs{
+	table, err := bigquery.NewTable(g.ctx, g.config.LogicalName(g.config.Dwh.BigQuery.TableId), &bigquery.TableArgs{
 		DeletionProtection: pulumi.Bool(g.config.Dwh.BigQuery.DeletionProtection),
-		TableId:            pulumi.String(g.config.Dwh.BigQuery.TableId),
+		TableId:            pulumi.String(tableId),
 		DatasetId:          dataset.DatasetId,
 		Schema:             pulumi.String(g.config.Dwh.BigQuery.Schema),
-	})
+		Labels:             g.labels,
+	}, lifecycle.Options(g.config, lifecycle.Dwh)...)
+	if err != nil {
+		return fmt.Errorf("bigquery table %v: %w", g.config.Dwh.BigQuery.TableId, err)
+	}
 
 	g.table = table
+	g.references["outputs.bigquery.table"] = table.TableId
+	g.contract.DwhTable = pulumi.Sprintf("%v.%v.%v", table.Project, table.DatasetId, table.TableId)
 
-	return err
+	return nil
 }
 
 // CreateStorage created Cloud Storage according to given values
 // ForceDestroy may set the false if files are important.
+// An existing bucket is read if storage.existing_id is given, or adopted if storage.import_id is given.
 func (g *Gcp) CreateStorage() error {
 
-	bucket, err := storage.NewBucket(g.ctx, g.config.Storage.Name, &storage.BucketArgs{
-		Name:                     pulumi.String(g.config.Storage.Name),
-		Location:                 pulumi.String(g.region),
-		ForceDestroy:             pulumi.Bool(g.config.Storage.ForceDestroy),
-		UniformBucketLevelAccess: pulumi.Bool(true),
-	})
+	bucketName, err := g.namer.Name(naming.StorageBucket, g.config.Storage.Name)
+	if err != nil {
+		return fmt.Errorf("storage bucket %v: %w", g.config.Storage.Name, err)
+	}
+
+	var bucket *storage.Bucket
+
+	if g.config.Storage.ExistingId != "" {
+		bucket, err = storage.GetBucket(g.ctx, g.config.LogicalName(g.config.Storage.Name), pulumi.ID(g.config.Storage.ExistingId), nil)
+	} else {
+		bucket, err = storage.NewBucket(g.ctx, g.config.LogicalName(g.config.Storage.Name), &storage.BucketArgs{
+			Name:                     pulumi.String(bucketName),
+			Location:                 pulumi.String(g.region),
+			ForceDestroy:             pulumi.Bool(g.config.Storage.ForceDestroy),
+			UniformBucketLevelAccess: pulumi.Bool(true),
+			Labels:                   g.labels,
+		}, adopt.Options(g.config.Storage.ImportId, lifecycle.Options(g.config, lifecycle.Storage)...)...)
+	}
+	if err != nil {
+		return fmt.Errorf("storage bucket %v: %w", g.config.Storage.Name, err)
+	}
 
 	g.bucket = bucket
+	g.references["outputs.bucket.name"] = bucket.Name
+	g.contract.StorageUri = pulumi.Sprintf("gs://%v", bucket.Name)
 
-	return err
+	return nil
+}
+
+// topicName returns the name of the topic that the function publishes to, name of the read or adopted topic is used if the stream is created.
+func (g *Gcp) topicName() (pulumi.StringInput, error) {
+	if g.topic != nil {
+		return g.topic.Name, nil
+	}
+
+	name, err := g.namer.Name(naming.PubSubTopic, g.config.Stream.PubSubConf.Topic.Name)
+	if err != nil {
+		return nil, err
+	}
+	return pulumi.String(name), nil
 }
 
 // CreateStream create Pubsub topic and subscription according to given values
 // You can set the destination such as "cloudstorage,bigquery"
 func (g *Gcp) CreateStream() error {
 
-	var err error
-
-	topic, err := pubsub.NewTopic(g.ctx, g.config.Stream.PubSubConf.Topic.Name, &pubsub.TopicArgs{
-		Name: pulumi.String(g.config.Stream.PubSubConf.Topic.Name),
-	})
-
-	g.topic = topic
+	topicName, err := g.namer.Name(naming.PubSubTopic, g.config.Stream.PubSubConf.Topic.Name)
+	if err != nil {
+		return fmt.Errorf("pubsub topic %v: %w", g.config.Stream.PubSubConf.Topic.Name, err)
+	}
 
-	subsArgs := &pubsub.SubscriptionArgs{
-		Name:               pulumi.String(g.config.Stream.PubSubConf.Subscription.Name),
-		Topic:              topic.ID(),
-		AckDeadlineSeconds: pulumi.Int(20),
+	subscriptionName, err := g.namer.Name(naming.PubSubSubscription, g.config.Stream.PubSubConf.Subscription.Name)
+	if err != nil {
+		return fmt.Errorf("pubsub subscription %v: %w", g.config.Stream.PubSubConf.Subscription.Name, err)
 	}
 
-	resources := []pulumi.Resource{}
+	args := &components.PubSubPipelineArgs{
+		TopicName:        topicName,
+		SubscriptionName: subscriptionName,
+		Destination:      g.config.Stream.Destination,
+		Labels:           g.labels,
+		ExistingTopicId:  g.config.Stream.PubSubConf.Topic.ExistingId,
+		ImportTopicId:    g.config.Stream.PubSubConf.Topic.ImportId,
+	}
 
-	if g.config.Stream.Destination == "cloudstorage" {
-		subsArgs.CloudStorageConfig = &pubsub.SubscriptionCloudStorageConfigArgs{
-			Bucket:      g.bucket.ID(),
-			MaxDuration: pulumi.String(g.config.Stream.PubSubConf.Subscription.CloudStorageConf.Duration),
+	if g.config.Stream.Destination == components.PubSubCloudStorage {
+		args.CloudStorage = &components.PubSubCloudStorageArgs{
+			Bucket:      g.bucket.Name,
+			MaxDuration: g.config.Stream.PubSubConf.Subscription.CloudStorageConf.Duration,
 		}
-	} else if g.config.Stream.Destination == "bigquery" {
-		subsArgs.BigqueryConfig = &pubsub.SubscriptionBigqueryConfigArgs{
+	} else if g.config.Stream.Destination == components.PubSubBigQuery {
+		args.BigQuery = &components.PubSubBigQueryArgs{
 			Table: pulumi.All(g.table.Project, g.table.DatasetId, g.table.TableId).ApplyT(func(_args []interface{}) (string, error) {
 				project := _args[0].(string)
 				datasetId := _args[1].(string)
 				tableId := _args[2].(string)
 				return fmt.Sprintf("%v.%v.%v", project, datasetId, tableId), nil
 			}).(pulumi.StringOutput),
-			UseTableSchema: pulumi.Bool(true),
 		}
+		args.DependsOn = []pulumi.Resource{g.table}
+	}
+
+	// Topic and subscription were registered at the stack root with the names in the config before
+	name := g.config.LogicalName(g.config.Stream.PubSubConf.Topic.Name)
+	oldName := func(child string) string {
+		if child == name+"-subscription" {
+			return g.config.LogicalName(g.config.Stream.PubSubConf.Subscription.Name)
+		}
+		return child
+	}
 
-		resources = append(resources, g.table)
+	pipeline, err := components.NewPubSubPipeline(g.ctx, name, args, components.MovedFromRoot(oldName), lifecycle.Children(g.config, lifecycle.Stream))
+	if err != nil {
+		return fmt.Errorf("pubsub topic %v: %w", g.config.Stream.PubSubConf.Topic.Name, err)
 	}
 
-	_, err = pubsub.NewSubscription(g.ctx, g.config.Stream.PubSubConf.Subscription.Name, subsArgs, pulumi.DependsOn(resources))
+	g.topic = pipeline.Topic
+	g.references["outputs.topic.name"] = pipeline.TopicName
+	g.contract.StreamId = pipeline.TopicId
 
-	return err
+	return nil
 }
 
 // generateSpecFile generates a yaml file according to given values
@@ -235,6 +375,11 @@ func (g *Gcp) CreateStream() error {
 // With this yaml you are able to use Google Authentication while using the function URL
 func (g *Gcp) generateSpecFile() error {
 
+	functionName, err := g.namer.Name(naming.CloudFunction, g.config.Function.Name)
+	if err != nil {
+		return fmt.Errorf("open api spec: %w", err)
+	}
+
 	spec := types.OpenApiSpec{}
 	spec.Swagger = "2.0"
 	spec.Info.Title = g.config.APIGateway.Name
@@ -252,7 +397,7 @@ func (g *Gcp) generateSpecFile() error {
 
 	spec.Paths.Event.Post.OperationID = fmt.Sprintf("%v-op", g.config.APIGateway.Name)
 
-	spec.Paths.Event.Post.XGoogleBackend.Address = fmt.Sprintf("https://%v-%v.cloudfunctions.net/%v", g.config.Function.Region, g.config.Iam.ServiceAcc.Project, g.config.Function.Name)
+	spec.Paths.Event.Post.XGoogleBackend.Address = fmt.Sprintf("https://%v-%v.cloudfunctions.net/%v", g.config.Function.Region, g.config.Iam.ServiceAcc.Project, functionName)
 
 	securities := []types.Security{}
 
@@ -261,32 +406,52 @@ func (g *Gcp) generateSpecFile() error {
 	spec.Paths.Event.Post.Security = securities
 	spec.Paths.Event.Post.Responses.Num200.Description = "OK"
 
-	yamlFile, _ := yaml.Marshal(&spec)
+	yamlFile, err := yaml.Marshal(&spec)
+	if err != nil {
+		return fmt.Errorf("open api spec: %w", err)
+	}
 
-	err := os.MkdirAll("api/gcp", 0700)
-	err = os.WriteFile("api/gcp/api.yaml", yamlFile, 0644)
-	return err
+	if err := os.MkdirAll("api/gcp", 0700); err != nil {
+		return fmt.Errorf("open api spec: %w", err)
+	}
+
+	if err := os.WriteFile("api/gcp/api.yaml", yamlFile, 0644); err != nil {
+		return fmt.Errorf("open api spec: %w", err)
+	}
+	return nil
 }
 
 // CreateApiGateway creates Api and Gateway according to given values
 // Before create a Gateway you need to generate spec file
 func (g *Gcp) CreateApiGateway() error {
 
-	err := g.generateSpecFile()
+	if err := g.generateSpecFile(); err != nil {
+		return err
+	}
+
+	apiId, err := g.namer.Name(naming.ApiGateway, g.config.APIGateway.Name)
+	if err != nil {
+		return fmt.Errorf("api %v: %w", g.config.APIGateway.Name, err)
+	}
 
-	apiGw, err := apigateway.NewApi(g.ctx, g.config.APIGateway.Name, &apigateway.ApiArgs{
-		ApiId: pulumi.String(g.config.APIGateway.Name),
-	}, pulumi.DependsOn([]pulumi.Resource{g.function}))
+	apiGw, err := apigateway.NewApi(g.ctx, g.config.LogicalName(g.config.APIGateway.Name), &apigateway.ApiArgs{
+		ApiId:  pulumi.String(apiId),
+		Labels: g.labels,
+	}, lifecycle.Options(g.config, lifecycle.ApiGateway, pulumi.DependsOn([]pulumi.Resource{g.function}))...)
 	if err != nil {
-		return err
+		return fmt.Errorf("api %v: %w", g.config.APIGateway.Name, err)
 	}
 	invokeFilebase64, err := std.Filebase64(g.ctx, &std.Filebase64Args{
 		Input: g.config.APIGateway.OpenApiSpec,
 	}, nil)
+	if err != nil {
+		return fmt.Errorf("open api spec %v: %w", g.config.APIGateway.OpenApiSpec, err)
+	}
 
-	apiGwApiConfig, err := apigateway.NewApiConfig(g.ctx, fmt.Sprintf("%v-config", g.config.APIGateway.Name), &apigateway.ApiConfigArgs{
+	apiGwApiConfig, err := apigateway.NewApiConfig(g.ctx, g.config.LogicalName(fmt.Sprintf("%v-config", g.config.APIGateway.Name)), &apigateway.ApiConfigArgs{
 		Api:         apiGw.ApiId,
-		ApiConfigId: pulumi.String(fmt.Sprintf("%v-config", g.config.APIGateway.Name)),
+		ApiConfigId: pulumi.String(fmt.Sprintf("%v-config", apiId)),
+		Labels:      g.labels,
 		OpenapiDocuments: apigateway.ApiConfigOpenapiDocumentArray{
 			&apigateway.ApiConfigOpenapiDocumentArgs{
 				Document: &apigateway.ApiConfigOpenapiDocumentDocumentArgs{
@@ -295,51 +460,69 @@ func (g *Gcp) CreateApiGateway() error {
 				},
 			},
 		},
-	}, pulumi.DependsOn([]pulumi.Resource{apiGw}))
+	}, lifecycle.Options(g.config, lifecycle.ApiGateway, pulumi.DependsOn([]pulumi.Resource{apiGw}))...)
+	if err != nil {
+		return fmt.Errorf("api config %v-config: %w", g.config.APIGateway.Name, err)
+	}
 
-	_, err = apigateway.NewGateway(g.ctx, fmt.Sprintf("%v-gw", g.config.APIGateway.Name), &apigateway.GatewayArgs{
+	gateway, err := apigateway.NewGateway(g.ctx, g.config.LogicalName(fmt.Sprintf("%v-gw", g.config.APIGateway.Name)), &apigateway.GatewayArgs{
 		ApiConfig: apiGwApiConfig.ID(),
-		GatewayId: pulumi.String(fmt.Sprintf("%v-gw", g.config.APIGateway.Name)),
+		GatewayId: pulumi.String(fmt.Sprintf("%v-gw", apiId)),
 		Region:    pulumi.String(g.config.APIGateway.Region),
-	}, pulumi.DependsOn([]pulumi.Resource{apiGwApiConfig}))
+		Labels:    g.labels,
+	}, lifecycle.Options(g.config, lifecycle.ApiGateway, pulumi.DependsOn([]pulumi.Resource{apiGwApiConfig}))...)
+	if err != nil {
+		return fmt.Errorf("gateway %v-gw: %w", g.config.APIGateway.Name, err)
+	}
+
+	// Paths of the spec are served on the default hostname, requests need a Google ID token for the oauth client
+	url := pulumi.Sprintf("https://%v", gateway.DefaultHostname)
+	g.outputs["apiGatewayUrl"] = url
 
-	return err
+	g.contract.IngestUrl = pulumi.Sprintf("%v/event", url)
+	g.contract.IngestAuthType = pulumi.String(types.AuthJwt)
+	g.contract.AuthIssuer = pulumi.String("https://accounts.google.com")
+	if g.config.Idp.ClientId != "" {
+		g.contract.AuthClientId = pulumi.String(g.config.Idp.ClientId)
+	}
+
+	return nil
 }
 
+// CreateVpc returns an error since VPC is not supported on GCP
 func (g *Gcp) CreateVpc() error {
-	//TODO implement me
-	panic("implement me")
+	return errors.New("vpc is not implemented on gcp")
 }
 
 // configureRolesForFunction creates/binds a member according to given values.
 func (g *Gcp) configureRolesForFunction() error {
 
-	var err error
-
 	for _, role := range g.config.Iam.Roles {
+		var err error
+
 		if role.Type == "cloudfuncv2member" {
-			_, err = cloudfunctionsv2.NewFunctionIamMember(g.ctx, role.Name, &cloudfunctionsv2.FunctionIamMemberArgs{
+			_, err = cloudfunctionsv2.NewFunctionIamMember(g.ctx, g.config.LogicalName(role.Name), &cloudfunctionsv2.FunctionIamMemberArgs{
 				Project:       pulumi.String(g.project),
 				Location:      pulumi.String(g.region),
-				CloudFunction: pulumi.String(g.config.Function.Name),
+				CloudFunction: g.function.Name,
 				Role:          pulumi.String(role.Role),
 				Member: g.serviceAcc.Email.ApplyT(func(email string) (string, error) {
 					return fmt.Sprintf("serviceAccount:%v", email), nil
 				}).(pulumi.StringOutput),
 			}, pulumi.DependsOn([]pulumi.Resource{g.function}))
 		} else if role.Type == "pubsubmember" {
-			_, err = pubsub.NewTopicIAMMember(g.ctx, role.Name, &pubsub.TopicIAMMemberArgs{
+			_, err = pubsub.NewTopicIAMMember(g.ctx, g.config.LogicalName(role.Name), &pubsub.TopicIAMMemberArgs{
 				Project: pulumi.String(g.project),
-				Topic:   pulumi.String(g.config.Stream.PubSubConf.Topic.Name),
+				Topic:   g.topic.Name,
 				Role:    pulumi.String(role.Role),
 				Member: g.serviceAcc.Email.ApplyT(func(email string) (string, error) {
 					return fmt.Sprintf("serviceAccount:%v", email), nil
 				}).(pulumi.StringOutput),
 			}, pulumi.DependsOn([]pulumi.Resource{g.topic}))
 		} else if role.Type == "cloudrunbinding" {
-			_, err = cloudrun.NewIamBinding(g.ctx, role.Name, &cloudrun.IamBindingArgs{
+			_, err = cloudrun.NewIamBinding(g.ctx, g.config.LogicalName(role.Name), &cloudrun.IamBindingArgs{
 				Project:  pulumi.String(g.project),
-				Service:  pulumi.String(g.config.Function.Name),
+				Service:  g.function.Name,
 				Location: pulumi.String(g.region),
 				Role:     pulumi.String(role.Role),
 				Members: pulumi.StringArray{
@@ -349,36 +532,157 @@ func (g *Gcp) configureRolesForFunction() error {
 				},
 			}, pulumi.DependsOn([]pulumi.Resource{g.function}))
 		}
+
+		if err != nil {
+			return fmt.Errorf("role %v: %w", role.Name, err)
+		}
 	}
 
-	return err
+	return nil
 }
 
 // ConfigureIAM configures the IAM member according to given values.
 // You can create the multiple role.
 func (g *Gcp) ConfigureIAM() error {
-	project, _ := organizations.LookupProject(g.ctx, &organizations.LookupProjectArgs{ProjectId: pulumi.StringRef(g.config.Iam.ServiceAcc.Project)}, nil)
+	project, err := organizations.LookupProject(g.ctx, &organizations.LookupProjectArgs{ProjectId: pulumi.StringRef(g.config.Iam.ServiceAcc.Project)}, nil)
+	if err != nil {
+		return fmt.Errorf("project %v: %w", g.config.Iam.ServiceAcc.Project, err)
+	}
 
-	var err error
+	g.references["project.number"] = pulumi.String(project.Number).ToStringOutput()
 
 	for _, role := range g.config.Iam.Roles {
 
+		// Member can refer to the project number, ex: serviceAccount:service-${project.number}@gcp-sa-pubsub.iam.gserviceaccount.com
+		member, err := g.resolve(role.Member)
+		if err != nil {
+			return fmt.Errorf("role %v member: %w", role.Name, err)
+		}
+
 		if role.Type == "bucketmember" {
-			_, _ = storage.NewBucketIAMMember(g.ctx, role.Name, &storage.BucketIAMMemberArgs{
+			_, err = storage.NewBucketIAMMember(g.ctx, g.config.LogicalName(role.Name), &storage.BucketIAMMemberArgs{
 				Bucket: g.bucket.Name,
 				Role:   pulumi.String(role.Role),
-				Member: pulumi.String(fmt.Sprintf(role.Member, project.Number)),
+				Member: member,
 			}, pulumi.DependsOn([]pulumi.Resource{g.bucket}))
 		} else if role.Type == "projectmember" {
-			_, _ = projects.NewIAMMember(g.ctx, role.Name, &projects.IAMMemberArgs{
+			_, err = projects.NewIAMMember(g.ctx, g.config.LogicalName(role.Name), &projects.IAMMemberArgs{
 				Project: pulumi.String(*project.ProjectId),
 				Role:    pulumi.String(role.Role),
-				Member:  pulumi.String(fmt.Sprintf(role.Member, project.Number)),
+				Member:  member,
 			})
 		}
+
+		if err != nil {
+			return fmt.Errorf("role %v: %w", role.Name, err)
+		}
 	}
 
-	return err
+	return nil
+}
+
+// Dependencies returns what each instruction needs and produces on GCP according to given values.
+// Ex: createStream needs Cloud Storage if destination is "cloudstorage" and BigQuery if destination is "bigquery".
+func Dependencies(config types.Config) map[string]types.Dependency {
+
+	stream := types.Dependency{
+		Produces: []types.Resource{types.StreamResource},
+	}
+
+	if config.Stream.Destination == "cloudstorage" {
+		stream.Needs = append(stream.Needs, types.StorageResource)
+	} else if config.Stream.Destination == "bigquery" {
+		stream.Needs = append(stream.Needs, types.DwhResource)
+	}
+
+	function := types.Dependency{
+		Produces: []types.Resource{types.FunctionResource},
+	}
+
+	iam := types.Dependency{
+		Produces: []types.Resource{types.RolesResource},
+	}
+
+	// needed keeps the resources that are added to the needs of function and iam, stream is only needed by function and storage by iam
+	needed := map[types.Resource]bool{}
+
+	// Function publishes to the topic of the stream, so the topic is created or read before the function
+	if config.Stream.PubSubConf.Topic.Name != "" {
+		needed[types.StreamResource] = true
+		function.Needs = append(function.Needs, types.StreamResource)
+	}
+
+	for _, role := range config.Iam.Roles {
+		if role.Type == "pubsubmember" && !needed[types.StreamResource] {
+			needed[types.StreamResource] = true
+			function.Needs = append(function.Needs, types.StreamResource)
+		} else if role.Type == "bucketmember" && !needed[types.StorageResource] {
+			needed[types.StorageResource] = true
+			iam.Needs = append(iam.Needs, types.StorageResource)
+		}
+	}
+
+	return map[string]types.Dependency{
+		"configureIAM":             iam,
+		"createVpc":                {},
+		"createStorage":            {Produces: []types.Resource{types.StorageResource}},
+		"createDWH":                {Produces: []types.Resource{types.DwhResource}},
+		"createStream":             stream,
+		"createFunction":           function,
+		"createIdentityManagement": {},
+		"createApiGateway":         {Needs: []types.Resource{types.FunctionResource}},
+	}
+}
+
+// resolve resolves the references to the project and outputs of created resources in value, such as ${project.number}
+func (g *Gcp) resolve(value string) (pulumi.StringOutput, error) {
+	return interpolate.Output(value, g.references)
+}
+
+// Context returns the pulumi context that resources are registered on.
+func (g *Gcp) Context() *pulumi.Context {
+	return g.ctx
+}
+
+// Config returns the config that resources are created from.
+func (g *Gcp) Config() types.Config {
+	return g.config
+}
+
+// Outputs returns the values that are exported by created resources such as urls.
+func (g *Gcp) Outputs() pulumi.Map {
+	return g.outputs
+}
+
+// Resources returns the created Cloud Storage bucket, BigQuery table, Pub/Sub topic and Cloud Function.
+func (g *Gcp) Resources() map[types.Resource]pulumi.Resource {
+
+	resources := map[types.Resource]pulumi.Resource{}
+
+	if g.bucket != nil {
+		resources[types.StorageResource] = g.bucket
+	}
+	if g.table != nil {
+		resources[types.DwhResource] = g.table
+	}
+	if g.topic != nil {
+		resources[types.StreamResource] = g.topic
+	}
+	if g.function != nil {
+		resources[types.FunctionResource] = g.function
+	}
+
+	return resources
+}
+
+// References returns a copy of the values that can be used in config as ${outputs.<name>}.
+func (g *Gcp) References() map[string]pulumi.StringOutput {
+	return maps.Clone(g.references)
+}
+
+// Contract returns the values of the outputs contract, the ingest endpoint is API Gateway if it is created, otherwise the function url.
+func (g *Gcp) Contract() contract.Values {
+	return g.contract
 }
 
 // New returns Gcp struct
@@ -387,5 +691,6 @@ func New(ctx *pulumi.Context, yamlConf types.Config) *Gcp {
 	project := conf.Require("project")
 	region := conf.Require("region")
 
-	return &Gcp{ctx: ctx, project: project, region: region, config: yamlConf}
+	return &Gcp{ctx: ctx, outputs: pulumi.Map{}, references: map[string]pulumi.StringOutput{}, namer: naming.New(yamlConf, ctx.Project(), ctx.Stack()),
+		labels: pulumi.ToStringMap(tags.Labels(tags.New(yamlConf, ctx.Stack()))), project: project, region: region, config: yamlConf}
 }
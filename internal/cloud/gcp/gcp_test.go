@@ -0,0 +1,120 @@
+package gcp
+
+import (
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/stretchr/testify/suite"
+	"path"
+	"sync"
+	"testing"
+)
+
+type testSuite struct {
+	suite.Suite
+	config types.Config
+}
+
+func (ts *testSuite) SetupSuite() {
+
+	config := types.Config{
+		Name:     "studio-a",
+		Env:      "prod",
+		Template: types.Template{Name: "data-pipeline"},
+		Tags:     map[string]string{"Cost Center": "Games: Mobile"},
+		Storage: types.Storage{
+			Name: "test-bucket",
+		},
+		Stream: types.Stream{
+			Destination: "cloudstorage",
+			PubSubConf: types.PubSubConf{
+				Topic:        types.Topic{Name: "test-topic"},
+				Subscription: types.Subscription{Name: "test-subscription", CloudStorageConf: types.CloudStorageConf{Duration: "300s"}},
+			},
+		},
+	}
+
+	ts.config = config
+}
+
+type mocks int
+
+func (m mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	return args.Args, nil
+}
+
+func (m mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	// A resource that is read has its name at the end of its id such as projects/<project>/topics/<name>
+	if args.ID != "" {
+		return args.ID, resource.PropertyMap{"name": resource.NewStringProperty(path.Base(args.ID))}, nil
+	}
+	return args.Name + "_id", args.Inputs, nil
+}
+
+// withProject gives gcp:project and gcp:region config that are required by New
+func withProject(info *pulumi.RunInfo) {
+	info.Config = map[string]string{"gcp:project": "ptemplate", "gcp:region": "europe-west1"}
+}
+
+func (ts *testSuite) TestCreateStorageAndStreamWithLabels() {
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		gcp := New(ctx, ts.config)
+		ts.NoError(gcp.CreateStorage())
+		ts.NoError(gcp.CreateStream())
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		// Tags are converted to labels, keys and values are lowercased and invalid characters are replaced
+		pulumi.All(gcp.bucket.Labels, gcp.topic.Labels).ApplyT(func(data []interface{}) error {
+			expected := map[string]string{"cost_center": "games__mobile", "env": "prod", "stack": "stack", "template": "data-pipeline", "pipeline": "studio-a"}
+			ts.Equal(expected, data[0])
+			ts.Equal(expected, data[1])
+			wg.Done()
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)), withProject)
+	ts.NoError(err)
+}
+
+func (ts *testSuite) TestCreateFunctionPublishesToExistingTopic() {
+	config := ts.config
+	config.Stream.PubSubConf.Topic.ExistingId = "projects/ptemplate/topics/legacy-events"
+	config.Iam.ServiceAcc = &types.ServiceAcc{AccountID: "ptemplate-svc-acc", Project: "ptemplate"}
+	config.Function = types.Function{Name: "ingest", Build: types.Build{Runtime: "go121", EntryPoint: "Ingest", Source: &types.Source{
+		Storage: types.Storage{Bucket: types.Bucket{Name: "functions", Object: types.BucketObject{Name: "ingest.zip", Path: "ingest.zip"}}},
+	}}}
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		gcp := New(ctx, config)
+		ts.NoError(gcp.CreateStorage())
+		ts.NoError(gcp.CreateStream())
+		ts.NoError(gcp.CreateFunction())
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		// TOPIC_ID is the name of the topic that is read, not the name in the config
+		gcp.function.ServiceConfig.EnvironmentVariables().ApplyT(func(envs map[string]string) error {
+			ts.Equal("legacy-events", envs["TOPIC_ID"])
+			wg.Done()
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)), withProject)
+	ts.NoError(err)
+
+	// Function is created after the topic
+	ts.Contains(Dependencies(config)["createFunction"].Needs, types.StreamResource)
+}
+
+func TestRunGcpSuite(t *testing.T) {
+	suite.Run(t, &testSuite{})
+}
@@ -0,0 +1,97 @@
+package cloud
+
+import (
+	"errors"
+	"github.com/cemayan/pulumi-template/internal/cloud/aws"
+	"github.com/cemayan/pulumi-template/internal/cloud/azure"
+	"github.com/cemayan/pulumi-template/internal/cloud/gcp"
+	"github.com/cemayan/pulumi-template/pipeline"
+	"github.com/cemayan/pulumi-template/types"
+)
+
+// resolveInstructions returns registered instructions for given names in the order they are executed.
+// Every unknown name and every missing prerequisite is reported before anything is executed.
+func resolveInstructions(names []string, cloud types.CloudProvider, config types.Config) ([]pipeline.Instruction, error) {
+
+	var errs []error
+
+	selected := map[string]pipeline.Instruction{}
+
+	for _, name := range names {
+		instruction, err := pipeline.Lookup(name, cloud)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		selected[name] = instruction
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	dependencies := map[string]types.Dependency{}
+	for _, instruction := range pipeline.Instructions() {
+		if instruction.Supports(cloud) {
+			dependencies[instruction.Name] = instruction.Dependency(cloud, config)
+		}
+	}
+
+	sorted, err := SortInstructions(names, dependencies)
+	if err != nil {
+		return nil, err
+	}
+
+	instructions := make([]pipeline.Instruction, 0, len(sorted))
+	for _, name := range sorted {
+		instructions = append(instructions, selected[name])
+	}
+
+	return instructions, nil
+}
+
+// builtinPrerequisites returns prerequisites of the built-in instructions from the selected cloud.
+func builtinPrerequisites(name string) func(types.CloudProvider, types.Config) types.Dependency {
+	return func(cloud types.CloudProvider, config types.Config) types.Dependency {
+		switch cloud {
+		case types.Aws:
+			return aws.Dependencies(config)[name]
+		case types.Gcp:
+			return gcp.Dependencies(config)[name]
+		case types.Azure:
+			return azure.Dependencies(config)[name]
+		}
+		return types.Dependency{}
+	}
+}
+
+// init registers the built-in instructions in the pipeline registry.
+func init() {
+	all := []types.CloudProvider{types.Aws, types.Gcp, types.Azure}
+
+	builtins := []struct {
+		name        string
+		description string
+		clouds      []types.CloudProvider
+		run         func(c pipeline.Cloud) error
+	}{
+		{"configureIAM", "Configures IAM roles and members", all, pipeline.Cloud.ConfigureIAM},
+		{"createVpc", "Creates a VPC", []types.CloudProvider{types.Aws}, pipeline.Cloud.CreateVpc},
+		{"createStorage", "Creates an object storage (S3, Cloud Storage, Storage Account)", all, pipeline.Cloud.CreateStorage},
+		{"createDWH", "Creates a data warehouse (Redshift, BigQuery, Azure Data Explorer)", all, pipeline.Cloud.CreateDWH},
+		{"createStream", "Creates a stream (Kinesis Firehose, Pub/Sub, Event Hubs)", all, pipeline.Cloud.CreateStream},
+		{"createFunction", "Creates a function (Lambda, Cloud Functions, Function App)", all, pipeline.Cloud.CreateFunction},
+		{"createIdentityManagement", "Creates an identity platform (Cognito)", []types.CloudProvider{types.Aws}, pipeline.Cloud.CreateIdentityManagement},
+		{"createApiGateway", "Creates an API gateway (API Gateway, API Management) in front of the stream or function", all, pipeline.Cloud.CreateApiGateway},
+	}
+
+	for _, b := range builtins {
+		pipeline.Register(pipeline.Instruction{
+			Name:          b.name,
+			Description:   b.description,
+			Clouds:        b.clouds,
+			Prerequisites: builtinPrerequisites(b.name),
+			Run:           b.run,
+		})
+	}
+}
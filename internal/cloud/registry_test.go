@@ -0,0 +1,66 @@
+package cloud
+
+import (
+	"github.com/cemayan/pulumi-template/pipeline"
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type registryTestSuite struct {
+	suite.Suite
+}
+
+// SetupSuite registers a custom instruction like another module does, it is registered once since the registry can not be cleared
+func (ts *registryTestSuite) SetupSuite() {
+	if _, err := pipeline.Lookup("createMonitoring", types.Aws); err == nil {
+		return
+	}
+
+	pipeline.Register(pipeline.Instruction{
+		Name:        "createMonitoring",
+		Description: "Creates alarms for the stream",
+		Clouds:      []types.CloudProvider{types.Aws},
+		Prerequisites: func(types.CloudProvider, types.Config) types.Dependency {
+			return types.Dependency{Needs: []types.Resource{types.StreamResource}}
+		},
+		Run: func(c pipeline.Cloud) error { return nil },
+	})
+}
+
+func (ts *registryTestSuite) TestBuiltinsAreOnlySupportedWhereImplemented() {
+	_, err := pipeline.Lookup("createIdentityManagement", types.Gcp)
+	ts.EqualError(err, "instruction createIdentityManagement is not supported on gcp")
+
+	_, err = pipeline.Lookup("createVpc", types.Azure)
+	ts.EqualError(err, "instruction createVpc is not supported on azure")
+
+	_, err = pipeline.Lookup("createVpc", types.Aws)
+	ts.NoError(err)
+}
+
+func (ts *registryTestSuite) TestResolveCustomInstruction() {
+	config := types.Config{Stream: types.Stream{Destination: "s3"}}
+
+	instructions, err := resolveInstructions([]string{"createMonitoring", "createStream", "createStorage", "configureIAM"}, types.Aws, config)
+	ts.NoError(err)
+
+	names := []string{}
+	for _, instruction := range instructions {
+		names = append(names, instruction.Name)
+	}
+	ts.Equal([]string{"createStorage", "configureIAM", "createStream", "createMonitoring"}, names)
+
+	_, err = resolveInstructions([]string{"createMonitoring"}, types.Aws, config)
+	ts.EqualError(err, "instruction createMonitoring needs stream but no instruction in template produces it, add createStream to template.instructions")
+}
+
+func (ts *registryTestSuite) TestRegisterBuiltinTwicePanics() {
+	ts.Panics(func() {
+		pipeline.Register(pipeline.Instruction{Name: "createStorage", Run: func(c pipeline.Cloud) error { return nil }})
+	})
+}
+
+func TestRunRegistrySuite(t *testing.T) {
+	suite.Run(t, &registryTestSuite{})
+}
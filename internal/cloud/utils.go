@@ -1,32 +1,106 @@
 package cloud
 
-// GetFunctionMap return func array that will be executed functions
-// instructions are coming from yaml
-func GetFunctionMap(instructions []interface{}) []func() error {
-	functionArr := []func() error{}
-	for _, v := range instructions {
-		i := v.(string)
-		switch i {
-		case "configureIAM":
-			functionArr = append(functionArr, CloudInstance.ConfigureIAM)
-		case "createVpc":
-			functionArr = append(functionArr, CloudInstance.CreateVpc)
-		case "createApiGateway":
-			functionArr = append(functionArr, CloudInstance.CreateApiGateway)
-		case "createStorage":
-			functionArr = append(functionArr, CloudInstance.CreateStorage)
-		case "createDWH":
-			functionArr = append(functionArr, CloudInstance.CreateDWH)
-		case "createStream":
-			functionArr = append(functionArr, CloudInstance.CreateStream)
-		case "createFunction":
-			functionArr = append(functionArr, CloudInstance.CreateFunction)
-		case "createIdentityManagement":
-			functionArr = append(functionArr, CloudInstance.CreateIdentityManagement)
-		default:
-			panic("instruction is not defined")
+import (
+	"errors"
+	"fmt"
+	"github.com/cemayan/pulumi-template/types"
+	"strings"
+)
+
+// SortInstructions orders instructions so that each one runs after the instructions producing what it needs.
+// The written order is kept as long as dependencies allow it.
+// An error is returned for every need that no given instruction produces, and for circular dependencies.
+func SortInstructions(instructions []string, dependencies map[string]types.Dependency) ([]string, error) {
+
+	producers := map[types.Resource][]int{}
+
+	for i, instruction := range instructions {
+		for _, resource := range dependencies[instruction].Produces {
+			producers[resource] = append(producers[resource], i)
+		}
+	}
+
+	var errs []error
+
+	for _, instruction := range instructions {
+		for _, need := range dependencies[instruction].Needs {
+			if len(producers[need]) > 0 {
+				continue
+			}
+
+			err := fmt.Errorf("instruction %v needs %v but no instruction in template produces it", instruction, need)
+			if producer := producerOf(need, dependencies); producer != "" {
+				err = fmt.Errorf("%w, add %v to template.instructions", err, producer)
+			}
+			errs = append(errs, err)
 		}
 	}
 
-	return functionArr
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	sorted := make([]string, 0, len(instructions))
+	done := make([]bool, len(instructions))
+
+	ready := func(instruction string) bool {
+		for _, need := range dependencies[instruction].Needs {
+			for _, i := range producers[need] {
+				if !done[i] {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	for len(sorted) < len(instructions) {
+		next := -1
+		for i, instruction := range instructions {
+			if !done[i] && ready(instruction) {
+				next = i
+				break
+			}
+		}
+
+		if next == -1 {
+			remaining := []string{}
+			for i, instruction := range instructions {
+				if !done[i] {
+					remaining = append(remaining, instruction)
+				}
+			}
+			return nil, fmt.Errorf("instructions have circular dependencies: %v", strings.Join(remaining, ", "))
+		}
+
+		done[next] = true
+		sorted = append(sorted, instructions[next])
+	}
+
+	return sorted, nil
+}
+
+// producerOf returns the name of an instruction that produces given resource, or empty string if there is none.
+func producerOf(resource types.Resource, dependencies map[string]types.Dependency) string {
+	names := []string{}
+	for name, dependency := range dependencies {
+		for _, produced := range dependency.Produces {
+			if produced == resource {
+				names = append(names, name)
+			}
+		}
+	}
+
+	if len(names) == 0 {
+		return ""
+	}
+
+	// map iteration order is random, so the smallest name is picked to keep the message stable
+	first := names[0]
+	for _, name := range names[1:] {
+		if name < first {
+			first = name
+		}
+	}
+	return first
 }
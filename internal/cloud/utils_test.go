@@ -0,0 +1,85 @@
+package cloud
+
+import (
+	"github.com/cemayan/pulumi-template/internal/cloud/aws"
+	"github.com/cemayan/pulumi-template/internal/cloud/gcp"
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type utilsTestSuite struct {
+	suite.Suite
+	awsConfig types.Config
+}
+
+func (ts *utilsTestSuite) SetupSuite() {
+	ts.awsConfig = types.Config{
+		Stream: types.Stream{Destination: "s3"},
+	}
+}
+
+func (ts *utilsTestSuite) TestSortInstructionsKeepsWrittenOrder() {
+	instructions := []string{"configureIAM", "createStorage", "createStream", "createFunction"}
+
+	sorted, err := SortInstructions(instructions, aws.Dependencies(ts.awsConfig))
+
+	ts.NoError(err)
+	ts.Equal(instructions, sorted)
+}
+
+func (ts *utilsTestSuite) TestSortInstructionsReordersByDependencies() {
+	instructions := []string{"createFunction", "createStream", "createStorage", "configureIAM"}
+
+	sorted, err := SortInstructions(instructions, aws.Dependencies(ts.awsConfig))
+
+	ts.NoError(err)
+	ts.Equal([]string{"createStorage", "configureIAM", "createStream", "createFunction"}, sorted)
+}
+
+func (ts *utilsTestSuite) TestSortInstructionsRejectsMissingInstruction() {
+	instructions := []string{"configureIAM", "createStorage", "createFunction"}
+
+	_, err := SortInstructions(instructions, aws.Dependencies(ts.awsConfig))
+
+	ts.EqualError(err, "instruction createFunction needs stream but no instruction in template produces it, add createStream to template.instructions")
+}
+
+func (ts *utilsTestSuite) TestSortInstructionsUsesConfigDependentNeeds() {
+	config := types.Config{
+		Stream: types.Stream{Destination: "bigquery"},
+	}
+
+	_, err := SortInstructions([]string{"createStorage", "createStream"}, gcp.Dependencies(config))
+
+	ts.ErrorContains(err, "instruction createStream needs dwh")
+}
+
+func (ts *utilsTestSuite) TestSortInstructionsSkipsReferencedResources() {
+	config := types.Config{
+		Stream:     types.Stream{Destination: "s3", RoleArn: "stackref://acme/platform/prod#firehoseRoleArn", S3Conf: types.S3Conf{BucketArn: "arn:aws:s3:::events"}},
+		APIGateway: types.APIGateway{RoleArn: "stackref://acme/platform/prod#apiRoleArn", Routes: []types.Routes{{Integrations: []types.Integrations{{Method: types.Method{Auth: "COGNITO_USER_POOLS"}}}}}},
+		Authorizer: types.Authorizer{UserPool: types.UserPool{Arn: "stackref://acme/platform/prod#userPoolArn"}},
+	}
+
+	// Roles, bucket and user pool are given by their ARNs, so they do not have to be created
+	sorted, err := SortInstructions([]string{"createStream", "createApiGateway"}, aws.Dependencies(config))
+
+	ts.NoError(err)
+	ts.Equal([]string{"createStream", "createApiGateway"}, sorted)
+}
+
+func (ts *utilsTestSuite) TestSortInstructionsDetectsCycles() {
+	dependencies := map[string]types.Dependency{
+		"a": {Needs: []types.Resource{types.StreamResource}, Produces: []types.Resource{types.StorageResource}},
+		"b": {Needs: []types.Resource{types.StorageResource}, Produces: []types.Resource{types.StreamResource}},
+	}
+
+	_, err := SortInstructions([]string{"a", "b"}, dependencies)
+
+	ts.EqualError(err, "instructions have circular dependencies: a, b")
+}
+
+func TestRunUtilsSuite(t *testing.T) {
+	suite.Run(t, &utilsTestSuite{})
+}
@@ -0,0 +1,88 @@
+package contract
+
+import (
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"strings"
+)
+
+// Values represents the values of types.StackOutputs that a cloud creates, values of resources that are not created are nil
+type Values struct {
+	IngestUrl      pulumi.StringInput
+	IngestAuthType pulumi.StringInput
+	AuthIssuer     pulumi.StringInput
+	AuthClientId   pulumi.StringInput
+	StorageUri     pulumi.StringInput
+	DwhTable       pulumi.StringInput
+	StreamId       pulumi.StringInput
+}
+
+// Map returns the values as nested maps with the keys of types.StackOutputs, ex: ingest: {url: ..., auth: {type: ...}}.
+// Nil values are left out, so are the maps that have no values.
+func (v Values) Map() pulumi.Map {
+
+	outputs := pulumi.Map{}
+
+	ingest := pulumi.Map{}
+	set(ingest, "url", v.IngestUrl)
+	if v.IngestAuthType != nil {
+		ingest["auth"] = pulumi.Map{"type": v.IngestAuthType}
+	}
+
+	auth := pulumi.Map{}
+	set(auth, "issuer", v.AuthIssuer)
+	set(auth, "client_id", v.AuthClientId)
+
+	storage, dwh, stream := pulumi.Map{}, pulumi.Map{}, pulumi.Map{}
+	set(storage, "uri", v.StorageUri)
+	set(dwh, "table", v.DwhTable)
+	set(stream, "id", v.StreamId)
+
+	for key, values := range map[string]pulumi.Map{"ingest": ingest, "auth": auth, "storage": storage, "dwh": dwh, "stream": stream} {
+		if len(values) > 0 {
+			outputs[key] = values
+		}
+	}
+
+	return outputs
+}
+
+// set sets key to value if value is given
+func set(values pulumi.Map, key string, value pulumi.StringInput) {
+	if value != nil {
+		values[key] = value
+	}
+}
+
+// AuthType returns the ingest.auth.type of given authorization of a method or function, ex: COGNITO_USER_POOLS is jwt and AWS_IAM is iam.
+// Auth levels of Azure functions are given too, anonymous is none and function and admin are key. Other values are returned in lowercase.
+func AuthType(authorization string) string {
+
+	switch strings.ToUpper(authorization) {
+	case "", "NONE", "ANONYMOUS":
+		return types.AuthNone
+	case "FUNCTION", "ADMIN":
+		return types.AuthKey
+	case "AWS_IAM":
+		return types.AuthIam
+	case "COGNITO_USER_POOLS":
+		return types.AuthJwt
+	}
+
+	return strings.ToLower(authorization)
+}
+
+// IngestRoute returns the route and the method of the first route that is not OPTIONS, events are sent to it.
+// ok is false if there is no such route.
+func IngestRoute(routes []types.Routes) (route types.Routes, method types.Method, ok bool) {
+
+	for _, route := range routes {
+		for _, integration := range route.Integrations {
+			if !strings.EqualFold(integration.Method.Type, "OPTIONS") {
+				return route, integration.Method, true
+			}
+		}
+	}
+
+	return types.Routes{}, types.Method{}, false
+}
@@ -0,0 +1,186 @@
+package contract_test
+
+import (
+	"fmt"
+	"github.com/cemayan/pulumi-template/internal/cloud"
+	"github.com/cemayan/pulumi-template/internal/contract"
+	"github.com/cemayan/pulumi-template/internal/interpolate"
+	"github.com/cemayan/pulumi-template/internal/loader"
+	"github.com/cemayan/pulumi-template/internal/plan"
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type contractTestSuite struct {
+	suite.Suite
+}
+
+func (ts *contractTestSuite) TestMap() {
+	values := contract.Values{
+		IngestUrl:      pulumi.String("https://api/dev/streams"),
+		IngestAuthType: pulumi.String(types.AuthJwt),
+		AuthClientId:   pulumi.String("client"),
+		StreamId:       pulumi.String("arn:aws:firehose:eu-central-1:1:deliverystream/events"),
+	}
+
+	// Values that are not created are left out
+	ts.Equal(pulumi.Map{
+		"ingest": pulumi.Map{"url": pulumi.String("https://api/dev/streams"), "auth": pulumi.Map{"type": pulumi.String("jwt")}},
+		"auth":   pulumi.Map{"client_id": pulumi.String("client")},
+		"stream": pulumi.Map{"id": pulumi.String("arn:aws:firehose:eu-central-1:1:deliverystream/events")},
+	}, values.Map())
+
+	ts.Empty(contract.Values{}.Map())
+}
+
+func (ts *contractTestSuite) TestAuthType() {
+	for authorization, expected := range map[string]string{
+		"":                   types.AuthNone,
+		"NONE":               types.AuthNone,
+		"AWS_IAM":            types.AuthIam,
+		"COGNITO_USER_POOLS": types.AuthJwt,
+		"anonymous":          types.AuthNone,
+		"function":           types.AuthKey,
+		"CUSTOM":             "custom",
+	} {
+		ts.Equal(expected, contract.AuthType(authorization), authorization)
+	}
+}
+
+func (ts *contractTestSuite) TestIngestRoute() {
+	routes := []types.Routes{
+		{Name: "cors", Integrations: []types.Integrations{{Method: types.Method{Type: "OPTIONS"}}}},
+		{Name: "streams", Integrations: []types.Integrations{{Method: types.Method{Type: "OPTIONS"}}, {Method: types.Method{Type: "POST", Auth: "COGNITO_USER_POOLS"}}}},
+	}
+
+	route, method, ok := contract.IngestRoute(routes)
+	ts.True(ok)
+	ts.Equal("streams", route.Name)
+	ts.Equal("COGNITO_USER_POOLS", method.Auth)
+
+	_, _, ok = contract.IngestRoute(routes[:1])
+	ts.False(ok)
+}
+
+// requiredKeys returns the keys of the contract that the instructions of config must give
+func requiredKeys(config types.Config) []string {
+
+	keys := []string{}
+	instructions := config.Template.Instructions
+
+	if slices.Contains(instructions, "createFunction") || slices.Contains(instructions, "createApiGateway") {
+		keys = append(keys, "ingest.url", "ingest.auth.type")
+	}
+	if slices.Contains(instructions, "createStorage") {
+		keys = append(keys, "storage.uri")
+	}
+	if slices.Contains(instructions, "createDWH") {
+		keys = append(keys, "dwh.table")
+	}
+	if slices.Contains(instructions, "createStream") {
+		keys = append(keys, "stream.id")
+	}
+
+	return keys
+}
+
+// has returns true if the contract has a value at given path such as ingest.auth.type
+func has(values pulumi.Map, path string) bool {
+
+	var value pulumi.Input = values
+	for _, key := range strings.Split(path, ".") {
+		m, ok := value.(pulumi.Map)
+		if !ok {
+			return false
+		}
+		if value, ok = m[key]; !ok {
+			return false
+		}
+	}
+
+	return value != nil
+}
+
+func (ts *contractTestSuite) TestShippedConfigsGiveRequiredKeys() {
+	paths, err := filepath.Glob("../../configs/*/*/*/config.yaml")
+	ts.Require().NoError(err)
+	more, err := filepath.Glob("../../configs/*/*/*/*/config.yaml")
+	ts.Require().NoError(err)
+	paths = append(paths, more...)
+
+	// ingests keeps the cloud and ingest endpoint combinations that are checked
+	ingests := map[string]bool{}
+
+	wd, err := os.Getwd()
+	ts.Require().NoError(err)
+
+	for _, path := range paths {
+		path, err := filepath.Abs(path)
+		ts.Require().NoError(err)
+
+		config, _, err := loader.Load(path, "")
+		ts.Require().NoError(err, path)
+
+		// Program writes files such as the OpenAPI spec of GCP in the working directory
+		ts.Require().NoError(os.Chdir(ts.T().TempDir()))
+
+		_, err = plan.Run(func(ctx *pulumi.Context) error {
+
+			config, err := interpolate.Config(config, map[string]string{"env": config.Env, "stack": ctx.Stack()})
+			if err != nil {
+				return err
+			}
+
+			pipelines, err := cloud.Pipelines(config)
+			if err != nil {
+				return err
+			}
+
+			for _, pipeline := range pipelines {
+				builder, err := cloud.NewBuilder(ctx, pipeline)
+				if err != nil {
+					return err
+				}
+				if err := builder.Build(); err != nil {
+					return err
+				}
+
+				values := builder.Cloud().Contract().Map()
+				for _, key := range requiredKeys(pipeline) {
+					ts.True(has(values, key), "%v %v: %v is not given", path, pipeline.Name, key)
+				}
+
+				ingest := "function"
+				if slices.Contains(pipeline.Template.Instructions, "createApiGateway") {
+					ingest = "apigateway"
+				}
+				ingests[fmt.Sprintf("%v/%v", pipeline.Cloud, ingest)] = true
+			}
+
+			return nil
+		}, "pulumi-template", "contract", map[string]string{
+			"gcp:project":    "ptemplate-plan",
+			"gcp:region":     "europe-west3",
+			"azure:location": "westeurope",
+		})
+		ts.NoError(err, path)
+
+		ts.Require().NoError(os.Chdir(wd))
+	}
+
+	for _, cloud := range []string{"aws", "gcp", "azure"} {
+		for _, ingest := range []string{"function", "apigateway"} {
+			ts.True(ingests[cloud+"/"+ingest], "no shipped config has %v on %v", ingest, cloud)
+		}
+	}
+}
+
+func TestRunContractSuite(t *testing.T) {
+	suite.Run(t, &contractTestSuite{})
+}
@@ -0,0 +1,272 @@
+package cost
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// aws returns the items of the components of an AWS pipeline
+func (e estimator) aws() []Item {
+
+	items := []Item{}
+	prices := e.prices.Aws
+
+	if e.has("createStorage") {
+		resource := fmt.Sprintf("S3 bucket %v", e.config.Storage.Name)
+		if e.config.Storage.ExistingId != "" {
+			items = append(items, existing("storage", resource))
+		} else {
+			items = append(items, Item{
+				Component: "storage",
+				Resource:  resource,
+				Usage:     fmt.Sprintf("%v GB-month", number(e.assumptions.StorageGB)),
+				Monthly:   e.assumptions.StorageGB * prices.S3GBMonth,
+			})
+		}
+	}
+
+	if e.has("createDWH") {
+		redshift := e.config.Dwh.Redshift
+		resource := fmt.Sprintf("Redshift cluster %v", redshift.Identifier)
+
+		nodes := redshift.NumberOfNodes
+		if nodes == 0 {
+			nodes = 1
+		}
+
+		hourly, ok := prices.RedshiftNodeHour[redshift.NodeType]
+		switch {
+		case redshift.ExistingId != "":
+			items = append(items, existing("dwh", resource))
+		case !ok:
+			items = append(items, e.unpriced("dwh", resource, fmt.Sprintf("node type %q", redshift.NodeType)))
+		default:
+			items = append(items, Item{
+				Component: "dwh",
+				Resource:  resource,
+				Usage:     fmt.Sprintf("%v x %v nodes x %v h", nodes, redshift.NodeType, number(e.prices.HoursPerMonth)),
+				Monthly:   e.monthly(nodes, hourly),
+			})
+		}
+	}
+
+	if e.has("createStream") {
+		items = append(items, Item{
+			Component: "stream",
+			Resource:  fmt.Sprintf("Firehose delivery stream %v", e.config.Stream.Name),
+			Usage:     fmt.Sprintf("%v GB ingested", number(e.assumptions.IngestGB)),
+			Monthly:   e.assumptions.IngestGB * prices.FirehoseGB,
+		})
+	}
+
+	if e.has("createFunction") {
+		items = append(items, Item{
+			Component: "function",
+			Resource:  fmt.Sprintf("Lambda function %v", e.config.Function.Name),
+			Usage:     e.functionUsage(e.assumptions.FunctionMemoryMB),
+			Monthly: e.assumptions.FunctionInvocations/1e6*prices.LambdaMillionRequests +
+				e.compute(e.assumptions.FunctionMemoryMB)*prices.LambdaGBSecond,
+		})
+	}
+
+	if e.has("createApiGateway") {
+		items = append(items, Item{
+			Component: "api_gateway",
+			Resource:  fmt.Sprintf("REST API %v", e.config.APIGateway.Name),
+			Usage:     fmt.Sprintf("%v requests", number(e.assumptions.ApiRequests)),
+			Monthly:   e.assumptions.ApiRequests / 1e6 * prices.ApiGatewayMillionRequests,
+		})
+	}
+
+	return items
+}
+
+// gcp returns the items of the components of a GCP pipeline
+func (e estimator) gcp() []Item {
+
+	items := []Item{}
+	prices := e.prices.Gcp
+
+	if e.has("createStorage") {
+		resource := fmt.Sprintf("Cloud Storage bucket %v", e.config.Storage.Name)
+		if e.config.Storage.ExistingId != "" {
+			items = append(items, existing("storage", resource))
+		} else {
+			items = append(items, Item{
+				Component: "storage",
+				Resource:  resource,
+				Usage:     fmt.Sprintf("%v GB-month", number(e.assumptions.StorageGB)),
+				Monthly:   e.assumptions.StorageGB * prices.StorageGBMonth,
+			})
+		}
+	}
+
+	if e.has("createDWH") {
+		items = append(items, Item{
+			Component: "dwh",
+			Resource:  fmt.Sprintf("BigQuery dataset %v", e.config.Dwh.BigQuery.Dataset),
+			Usage:     fmt.Sprintf("%v GB-month", number(e.assumptions.DwhStorageGB)),
+			Monthly:   e.assumptions.DwhStorageGB * prices.BigQueryStorageGBMonth,
+		})
+	}
+
+	// Throughput is billed to the topic even if it exists
+	if e.has("createStream") {
+		items = append(items, Item{
+			Component: "stream",
+			Resource:  fmt.Sprintf("Pub/Sub topic %v", e.config.Stream.PubSubConf.Topic.Name),
+			Usage:     fmt.Sprintf("%v GB throughput", number(e.assumptions.IngestGB)),
+			Monthly:   e.assumptions.IngestGB / 1024 * prices.PubSubTiB,
+		})
+	}
+
+	if e.has("createFunction") {
+		memory := e.assumptions.FunctionMemoryMB
+		if available := e.config.Function.ServiceConf.AvailableMem; available != "" {
+			if parsed, ok := megabytes(available); ok {
+				memory = parsed
+			}
+		}
+
+		usage := e.functionUsage(memory)
+		if instances := e.config.Function.ServiceConf.MaxInstance; instances > 1 {
+			usage += fmt.Sprintf(" on up to %v instances", instances)
+		}
+
+		items = append(items, Item{
+			Component: "function",
+			Resource:  fmt.Sprintf("Cloud Function %v", e.config.Function.Name),
+			Usage:     usage,
+			Monthly: e.assumptions.FunctionInvocations/1e6*prices.FunctionsMillionInvocations +
+				e.compute(memory)*prices.FunctionsGBSecond,
+		})
+	}
+
+	if e.has("createApiGateway") {
+		items = append(items, Item{
+			Component: "api_gateway",
+			Resource:  fmt.Sprintf("API Gateway %v", e.config.APIGateway.Name),
+			Usage:     fmt.Sprintf("%v calls", number(e.assumptions.ApiRequests)),
+			Monthly:   e.assumptions.ApiRequests / 1e6 * prices.ApiGatewayMillionCalls,
+		})
+	}
+
+	return items
+}
+
+// azure returns the items of the components of an Azure pipeline
+func (e estimator) azure() []Item {
+
+	items := []Item{}
+	prices := e.prices.Azure
+
+	if e.has("createStorage") {
+		resource := fmt.Sprintf("Storage account %v", e.config.Storage.Name)
+		if e.config.Storage.ExistingId != "" {
+			items = append(items, existing("storage", resource))
+		} else {
+			items = append(items, Item{
+				Component: "storage",
+				Resource:  resource,
+				Usage:     fmt.Sprintf("%v GB-month", number(e.assumptions.StorageGB)),
+				Monthly:   e.assumptions.StorageGB * prices.StorageGBMonth,
+			})
+		}
+	}
+
+	if e.has("createDWH") {
+		adx := e.config.Dwh.Adx
+		resource := fmt.Sprintf("Data Explorer cluster %v", adx.Cluster)
+
+		if hourly, ok := prices.AdxInstanceHour[adx.Sku]; ok {
+			items = append(items, Item{
+				Component: "dwh",
+				Resource:  resource,
+				Usage:     fmt.Sprintf("%v x %v x %v h", max(adx.Capacity, 1), adx.Sku, number(e.prices.HoursPerMonth)),
+				Monthly:   e.monthly(max(adx.Capacity, 1), hourly),
+			})
+		} else {
+			items = append(items, e.unpriced("dwh", resource, fmt.Sprintf("sku %q", adx.Sku)))
+		}
+	}
+
+	if e.has("createStream") {
+		namespace := e.config.Stream.EventHubConf.Namespace
+		resource := fmt.Sprintf("Event Hubs namespace %v", namespace.Name)
+
+		if hourly, ok := prices.EventHubUnitHour[namespace.Sku]; ok {
+			items = append(items, Item{
+				Component: "stream",
+				Resource:  resource,
+				Usage:     fmt.Sprintf("%v x %v throughput units x %v h", max(namespace.Capacity, 1), namespace.Sku, number(e.prices.HoursPerMonth)),
+				Monthly:   e.monthly(max(namespace.Capacity, 1), hourly),
+			})
+		} else {
+			items = append(items, e.unpriced("stream", resource, fmt.Sprintf("sku %q", namespace.Sku)))
+		}
+	}
+
+	if e.has("createFunction") {
+		items = append(items, Item{
+			Component: "function",
+			Resource:  fmt.Sprintf("Function App %v", e.config.Function.Name),
+			Usage:     e.functionUsage(e.assumptions.FunctionMemoryMB),
+			Monthly: e.assumptions.FunctionInvocations/1e6*prices.FunctionsMillionExecutions +
+				e.compute(e.assumptions.FunctionMemoryMB)*prices.FunctionsGBSecond,
+		})
+	}
+
+	if e.has("createApiGateway") {
+		resource := fmt.Sprintf("API Management %v", e.config.APIGateway.Name)
+
+		// Sku is given as name and units, ex: Developer_1
+		sku, units, _ := strings.Cut(e.config.APIGateway.Sku, "_")
+		count, err := strconv.Atoi(units)
+		if err != nil || count == 0 {
+			count = 1
+		}
+
+		monthly, ok := prices.ApimUnitMonth[sku]
+		switch {
+		case sku == "Consumption":
+			items = append(items, Item{
+				Component: "api_gateway",
+				Resource:  resource,
+				Usage:     fmt.Sprintf("%v calls", number(e.assumptions.ApiRequests)),
+				Monthly:   e.assumptions.ApiRequests / 1e6 * prices.ApimConsumptionMillionCalls,
+			})
+		case ok:
+			items = append(items, Item{
+				Component: "api_gateway",
+				Resource:  resource,
+				Usage:     fmt.Sprintf("%v x %v units", count, sku),
+				Monthly:   float64(count) * monthly,
+			})
+		default:
+			items = append(items, e.unpriced("api_gateway", resource, fmt.Sprintf("sku %q", e.config.APIGateway.Sku)))
+		}
+	}
+
+	return items
+}
+
+// megabytes returns the memory of a Cloud Function such as 256M or 1Gi in MB
+func megabytes(memory string) (float64, bool) {
+
+	units := []struct {
+		suffix string
+		mb     float64
+	}{
+		{"Gi", 1024}, {"G", 1000}, {"Mi", 1}, {"M", 1},
+	}
+
+	for _, unit := range units {
+		if value, ok := strings.CutSuffix(memory, unit.suffix); ok {
+			n, err := strconv.ParseFloat(value, 64)
+			return n * unit.mb, err == nil
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,293 @@
+// Package cost estimates the monthly cost of the resources in a config before they are deployed.
+// Components are priced with the local price table in prices.yaml and the traffic assumptions in the estimates block of config. Ex:
+//
+//	estimates:
+//	  ingest_gb: 50
+//	  api_requests: 5000000
+//
+// Estimates are rough, they use list prices without free tiers and leave out data transfer, storage requests and the resources that cost little.
+package cost
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"github.com/cemayan/pulumi-template/internal/cloud"
+	"github.com/cemayan/pulumi-template/types"
+	yaml "gopkg.in/yaml.v3"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+//go:embed prices.yaml
+var builtinPrices []byte
+
+// Prices represents the price table, prices are in Currency
+type Prices struct {
+	Version       string      `yaml:"version" json:"version"`
+	Currency      string      `yaml:"currency" json:"currency"`
+	HoursPerMonth float64     `yaml:"hours_per_month" json:"-"`
+	Aws           AwsPrices   `yaml:"aws" json:"-"`
+	Gcp           GcpPrices   `yaml:"gcp" json:"-"`
+	Azure         AzurePrices `yaml:"azure" json:"-"`
+}
+
+type AwsPrices struct {
+	S3GBMonth                 float64            `yaml:"s3_gb_month"`
+	RedshiftNodeHour          map[string]float64 `yaml:"redshift_node_hour"`
+	FirehoseGB                float64            `yaml:"firehose_gb"`
+	LambdaMillionRequests     float64            `yaml:"lambda_million_requests"`
+	LambdaGBSecond            float64            `yaml:"lambda_gb_second"`
+	ApiGatewayMillionRequests float64            `yaml:"api_gateway_million_requests"`
+}
+
+type GcpPrices struct {
+	StorageGBMonth              float64 `yaml:"storage_gb_month"`
+	BigQueryStorageGBMonth      float64 `yaml:"bigquery_storage_gb_month"`
+	PubSubTiB                   float64 `yaml:"pubsub_tib"`
+	FunctionsMillionInvocations float64 `yaml:"functions_million_invocations"`
+	FunctionsGBSecond           float64 `yaml:"functions_gb_second"`
+	ApiGatewayMillionCalls      float64 `yaml:"api_gateway_million_calls"`
+}
+
+type AzurePrices struct {
+	StorageGBMonth              float64            `yaml:"storage_gb_month"`
+	AdxInstanceHour             map[string]float64 `yaml:"adx_instance_hour"`
+	EventHubUnitHour            map[string]float64 `yaml:"eventhub_unit_hour"`
+	FunctionsMillionExecutions  float64            `yaml:"functions_million_executions"`
+	FunctionsGBSecond           float64            `yaml:"functions_gb_second"`
+	ApimUnitMonth               map[string]float64 `yaml:"apim_unit_month"`
+	ApimConsumptionMillionCalls float64            `yaml:"apim_consumption_million_calls"`
+}
+
+// Defaults gives the traffic assumptions that are used if they are not given in config
+var Defaults = types.Estimates{
+	IngestGB:            10,
+	StorageGB:           10,
+	DwhStorageGB:        10,
+	ApiRequests:         1000000,
+	FunctionInvocations: 1000000,
+	FunctionDurationMs:  100,
+	FunctionMemoryMB:    128,
+}
+
+// LoadPrices returns the price table in given path, the built-in table is returned if path is empty
+func LoadPrices(path string) (Prices, error) {
+
+	content := builtinPrices
+
+	if path != "" {
+		var err error
+		if content, err = os.ReadFile(path); err != nil {
+			return Prices{}, fmt.Errorf("price table: %w", err)
+		}
+	}
+
+	prices := Prices{}
+	if err := yaml.Unmarshal(content, &prices); err != nil {
+		return Prices{}, fmt.Errorf("price table %v: %w", path, err)
+	}
+
+	if prices.Version == "" || prices.HoursPerMonth == 0 {
+		return Prices{}, fmt.Errorf("price table %v: version and hours_per_month are required", path)
+	}
+
+	return prices, nil
+}
+
+// Item represents the monthly cost of a resource
+type Item struct {
+	Pipeline  string `json:"pipeline,omitempty"`
+	Component string `json:"component"`
+	Resource  string `json:"resource"`
+	// Usage explains how the cost is calculated, ex: 2 nodes x 730 h
+	Usage   string  `json:"usage"`
+	Monthly float64 `json:"monthly"`
+	// Note explains why the resource is not priced
+	Note string `json:"note,omitempty"`
+}
+
+// Estimate represents the monthly cost of the resources in a config
+type Estimate struct {
+	Prices Prices `json:"prices"`
+	// Assumptions gives the traffic assumptions of every pipeline by pipeline name
+	Assumptions map[string]types.Estimates `json:"assumptions"`
+	Items       []Item                     `json:"items"`
+	Total       float64                    `json:"total"`
+}
+
+// New returns the estimate of every pipeline in config with given prices.
+// Components are priced if their instructions are in the template, existing resources that are only read are not priced.
+func New(config types.Config, prices Prices) (Estimate, error) {
+
+	pipelines, err := cloud.Pipelines(config)
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	estimate := Estimate{Prices: prices, Assumptions: map[string]types.Estimates{}, Items: []Item{}}
+
+	for _, pipeline := range pipelines {
+		assumptions := merge(merge(Defaults, config.Estimates), pipeline.Estimates)
+		estimate.Assumptions[pipeline.Name] = assumptions
+
+		e := estimator{config: pipeline, prices: prices, assumptions: assumptions}
+
+		var items []Item
+		switch types.CloudMap[pipeline.Cloud] {
+		case types.Aws:
+			items = e.aws()
+		case types.Gcp:
+			items = e.gcp()
+		case types.Azure:
+			items = e.azure()
+		default:
+			return Estimate{}, fmt.Errorf("cloud %q is not supported, valid clouds are: aws, gcp, azure", pipeline.Cloud)
+		}
+
+		for _, item := range items {
+			item.Pipeline = pipeline.Name
+			item.Monthly = math.Round(item.Monthly*100) / 100
+			estimate.Items = append(estimate.Items, item)
+			estimate.Total += item.Monthly
+		}
+	}
+
+	estimate.Total = math.Round(estimate.Total*100) / 100
+
+	return estimate, nil
+}
+
+// merge returns the values of given assumptions on base, values that are not given are taken from base
+func merge(base types.Estimates, given types.Estimates) types.Estimates {
+
+	merged := base
+	mergedValue := reflect.ValueOf(&merged).Elem()
+	givenValue := reflect.ValueOf(given)
+
+	for i := 0; i < givenValue.NumField(); i++ {
+		if !givenValue.Field(i).IsZero() {
+			mergedValue.Field(i).Set(givenValue.Field(i))
+		}
+	}
+
+	return merged
+}
+
+// estimator prices the components of a pipeline
+type estimator struct {
+	config      types.Config
+	prices      Prices
+	assumptions types.Estimates
+}
+
+// has returns true if instruction is in the template of the pipeline
+func (e estimator) has(instruction string) bool {
+	return slices.Contains(e.config.Template.Instructions, instruction)
+}
+
+// monthly returns the cost of given count of units that are priced by hour for a month
+func (e estimator) monthly(count int, hourly float64) float64 {
+	return float64(count) * e.prices.HoursPerMonth * hourly
+}
+
+// existing returns the item of a resource that is read instead of created
+func existing(component string, resource string) Item {
+	return Item{Component: component, Resource: resource, Note: "existing resource, it is not priced"}
+}
+
+// unpriced returns the item of a resource whose sku is not in the price table
+func (e estimator) unpriced(component string, resource string, sku string) Item {
+	return Item{Component: component, Resource: resource, Note: fmt.Sprintf("%v is not in price table %v", sku, e.prices.Version)}
+}
+
+// compute returns the GB-seconds of the functions in a month for given memory
+func (e estimator) compute(memoryMB float64) float64 {
+	return e.assumptions.FunctionInvocations * e.assumptions.FunctionDurationMs / 1000 * memoryMB / 1024
+}
+
+// functionUsage returns the usage of the functions for given memory
+func (e estimator) functionUsage(memoryMB float64) string {
+	return fmt.Sprintf("%v invocations x %v ms x %v MB", number(e.assumptions.FunctionInvocations), number(e.assumptions.FunctionDurationMs), number(memoryMB))
+}
+
+// Write writes the items as a table with the total, assumptions are written above it
+func (e Estimate) Write(w io.Writer) error {
+
+	lines := []string{fmt.Sprintf("Monthly estimate in %v with price table %v, list prices without free tiers", e.Prices.Currency, e.Prices.Version)}
+
+	names := []string{}
+	for name := range e.Assumptions {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		a := e.Assumptions[name]
+		prefix := "Assumptions"
+		if name != "" {
+			prefix = fmt.Sprintf("Assumptions of %v", name)
+		}
+		lines = append(lines, fmt.Sprintf("%v: %v GB ingested, %v GB in storage, %v GB in dwh, %v API requests, %v function invocations of %v ms",
+			prefix, number(a.IngestGB), number(a.StorageGB), number(a.DwhStorageGB), number(a.ApiRequests), number(a.FunctionInvocations), number(a.FunctionDurationMs)))
+	}
+
+	if _, err := fmt.Fprintln(w, strings.Join(lines, "\n")+"\n"); err != nil {
+		return err
+	}
+
+	pipelines := len(names) > 1 || (len(names) == 1 && names[0] != "")
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	header := "COMPONENT\tRESOURCE\tUSAGE\tMONTHLY"
+	if pipelines {
+		header = "PIPELINE\t" + header
+	}
+	fmt.Fprintln(tw, header)
+
+	for _, item := range e.Items {
+		monthly := fmt.Sprintf("%.2f", item.Monthly)
+		usage := item.Usage
+		if item.Note != "" {
+			monthly, usage = "-", item.Note
+		}
+
+		row := fmt.Sprintf("%v\t%v\t%v\t%v", item.Component, item.Resource, usage, monthly)
+		if pipelines {
+			row = item.Pipeline + "\t" + row
+		}
+		fmt.Fprintln(tw, row)
+	}
+
+	total := fmt.Sprintf("TOTAL\t\t\t%.2f", e.Total)
+	if pipelines {
+		total = "TOTAL\t\t\t\t" + fmt.Sprintf("%.2f", e.Total)
+	}
+	fmt.Fprintln(tw, total)
+
+	return tw.Flush()
+}
+
+// WriteJSON writes the estimate as JSON
+func (e Estimate) WriteJSON(w io.Writer) error {
+
+	content, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cost estimate: %w", err)
+	}
+
+	_, err = fmt.Fprintln(w, string(content))
+	return err
+}
+
+// number formats n without exponent and trailing zeros, ex: 1000000 and 0.5
+func number(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
@@ -0,0 +1,147 @@
+package cost
+
+import (
+	"bytes"
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type costTestSuite struct {
+	suite.Suite
+	prices Prices
+}
+
+func (ts *costTestSuite) SetupTest() {
+	prices, err := LoadPrices("")
+	ts.Require().NoError(err)
+	ts.prices = prices
+}
+
+func (ts *costTestSuite) TestLoadPrices() {
+	ts.NotEmpty(ts.prices.Version)
+	ts.Equal(0.25, ts.prices.Aws.RedshiftNodeHour["dc2.large"])
+
+	path := filepath.Join(ts.T().TempDir(), "prices.yaml")
+	ts.Require().NoError(os.WriteFile(path, []byte("version: \"2025-01\"\ncurrency: EUR\nhours_per_month: 720\naws:\n  firehose_gb: 0.03\n"), 0644))
+
+	prices, err := LoadPrices(path)
+	ts.Require().NoError(err)
+	ts.Equal("2025-01", prices.Version)
+	ts.Equal(0.03, prices.Aws.FirehoseGB)
+
+	ts.Require().NoError(os.WriteFile(path, []byte("currency: EUR\n"), 0644))
+	_, err = LoadPrices(path)
+	ts.EqualError(err, "price table "+path+": version and hours_per_month are required")
+}
+
+func (ts *costTestSuite) TestAws() {
+	config := types.Config{
+		Cloud:      "aws",
+		Template:   types.Template{Instructions: []string{"createStorage", "createDWH", "createStream", "createApiGateway", "createFunction"}},
+		Storage:    types.Storage{Name: "events", ExistingId: "legacy-events"},
+		Dwh:        types.Dwh{Redshift: types.Redshift{Identifier: "warehouse", NodeType: "ra3.xlplus", NumberOfNodes: 2}},
+		Stream:     types.Stream{Name: "events"},
+		APIGateway: types.APIGateway{Name: "ingest"},
+		Function:   types.Function{Name: "proxy"},
+		Estimates:  types.Estimates{IngestGB: 500, ApiRequests: 20000000, FunctionMemoryMB: 512},
+	}
+
+	estimate, err := New(config, ts.prices)
+	ts.Require().NoError(err)
+
+	ts.Equal([]Item{
+		{Component: "storage", Resource: "S3 bucket events", Note: "existing resource, it is not priced"},
+		{Component: "dwh", Resource: "Redshift cluster warehouse", Usage: "2 x ra3.xlplus nodes x 730 h", Monthly: 1585.56},
+		{Component: "stream", Resource: "Firehose delivery stream events", Usage: "500 GB ingested", Monthly: 14.5},
+		{Component: "function", Resource: "Lambda function proxy", Usage: "1000000 invocations x 100 ms x 512 MB", Monthly: 1.03},
+		{Component: "api_gateway", Resource: "REST API ingest", Usage: "20000000 requests", Monthly: 70},
+	}, estimate.Items)
+	ts.Equal(1671.09, estimate.Total)
+
+	config.Dwh.Redshift.NodeType = "dc1.large"
+	estimate, err = New(config, ts.prices)
+	ts.Require().NoError(err)
+	ts.Equal(Item{Component: "dwh", Resource: "Redshift cluster warehouse", Note: `node type "dc1.large" is not in price table ` + ts.prices.Version}, estimate.Items[1])
+}
+
+func (ts *costTestSuite) TestGcp() {
+	config := types.Config{
+		Cloud:    "gcp",
+		Template: types.Template{Instructions: []string{"createDWH", "createStream", "createFunction"}},
+		Dwh:      types.Dwh{BigQuery: types.BigQuery{Dataset: "events"}},
+		Stream:   types.Stream{PubSubConf: types.PubSubConf{Topic: types.Topic{Name: "events"}}},
+		Function: types.Function{Name: "proxy", ServiceConf: types.ServiceConf{MaxInstance: 3, AvailableMem: "1Gi"}},
+		Estimates: types.Estimates{
+			IngestGB:            2048,
+			DwhStorageGB:        1000,
+			FunctionInvocations: 2000000,
+		},
+	}
+
+	estimate, err := New(config, ts.prices)
+	ts.Require().NoError(err)
+
+	ts.Equal([]Item{
+		{Component: "dwh", Resource: "BigQuery dataset events", Usage: "1000 GB-month", Monthly: 20},
+		{Component: "stream", Resource: "Pub/Sub topic events", Usage: "2048 GB throughput", Monthly: 80},
+		{Component: "function", Resource: "Cloud Function proxy", Usage: "2000000 invocations x 100 ms x 1024 MB on up to 3 instances", Monthly: 4.5},
+	}, estimate.Items)
+}
+
+func (ts *costTestSuite) TestAzure() {
+	config := types.Config{
+		Cloud:      "azure",
+		Template:   types.Template{Instructions: []string{"createDWH", "createStream", "createApiGateway"}},
+		Dwh:        types.Dwh{Adx: types.Adx{Cluster: "events", Sku: "Standard_L8s_v3", Capacity: 2}},
+		Stream:     types.Stream{EventHubConf: types.EventHubConf{Namespace: types.EventHubNamespace{Name: "events", Sku: "Standard", Capacity: 2}}},
+		APIGateway: types.APIGateway{Name: "ingest", Sku: "Developer_1"},
+	}
+
+	estimate, err := New(config, ts.prices)
+	ts.Require().NoError(err)
+
+	ts.Equal([]Item{
+		{Component: "dwh", Resource: "Data Explorer cluster events", Note: `sku "Standard_L8s_v3" is not in price table ` + ts.prices.Version},
+		{Component: "stream", Resource: "Event Hubs namespace events", Usage: "2 x Standard throughput units x 730 h", Monthly: 43.8},
+		{Component: "api_gateway", Resource: "API Management ingest", Usage: "1 x Developer units", Monthly: 48.04},
+	}, estimate.Items)
+}
+
+func (ts *costTestSuite) TestPipelines() {
+	config := types.Config{
+		Estimates: types.Estimates{IngestGB: 100},
+		Pipelines: []types.Config{
+			{Name: "studio-a", Cloud: "aws", Template: types.Template{Instructions: []string{"createStream"}}, Stream: types.Stream{Name: "events"}},
+			{Name: "studio-b", Cloud: "aws", Template: types.Template{Instructions: []string{"createStream"}}, Stream: types.Stream{Name: "events"},
+				Estimates: types.Estimates{IngestGB: 1000, ApiRequests: 5}},
+		},
+	}
+
+	estimate, err := New(config, ts.prices)
+	ts.Require().NoError(err)
+
+	// Assumptions of the top level are used if a pipeline does not give them
+	ts.Equal(100.0, estimate.Assumptions["studio-a"].IngestGB)
+	ts.Equal(Defaults.ApiRequests, estimate.Assumptions["studio-a"].ApiRequests)
+	ts.Equal(1000.0, estimate.Assumptions["studio-b"].IngestGB)
+	ts.Equal(5.0, estimate.Assumptions["studio-b"].ApiRequests)
+
+	ts.Equal([]Item{
+		{Pipeline: "studio-a", Component: "stream", Resource: "Firehose delivery stream events", Usage: "100 GB ingested", Monthly: 2.9},
+		{Pipeline: "studio-b", Component: "stream", Resource: "Firehose delivery stream events", Usage: "1000 GB ingested", Monthly: 29},
+	}, estimate.Items)
+	ts.Equal(31.9, estimate.Total)
+
+	var out bytes.Buffer
+	ts.Require().NoError(estimate.Write(&out))
+	ts.Contains(out.String(), "Assumptions of studio-b: 1000 GB ingested")
+	ts.Contains(out.String(), "studio-b  stream     Firehose delivery stream events  1000 GB ingested  29.00")
+	ts.Contains(out.String(), "TOTAL")
+}
+
+func TestRunCostSuite(t *testing.T) {
+	suite.Run(t, &costTestSuite{})
+}
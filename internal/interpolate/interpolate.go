@@ -0,0 +1,294 @@
+package interpolate
+
+import (
+	"fmt"
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// reference matches ${name} in config strings, $${name} is written as ${name} without being resolved
+var reference = regexp.MustCompile(`\$?\$\{([A-Za-z0-9_.\[\]-]+)\}`)
+
+// deferred gives the prefixes of the references that are resolved by the cloud while resources are created
+var deferred = []string{"outputs.", "project."}
+
+// isDeferred returns true if the reference is resolved by the cloud
+func isDeferred(name string) bool {
+	for _, prefix := range deferred {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Config resolves the references in every string of config.
+// A reference can be one of given vars such as ${env} and ${stack}, or the yaml path of another string in config such as ${stream.name}.
+// References to outputs (${outputs.firehose.name}) and the project (${project.number}) are kept, they are resolved by the cloud with Output.
+// If config has pipelines, references in each entry are resolved against the entry.
+func Config(config types.Config, vars map[string]string) (types.Config, error) {
+
+	if len(config.Pipelines) == 0 {
+		return resolveConfig(config, vars, "")
+	}
+
+	pipelines := make([]types.Config, len(config.Pipelines))
+
+	for i, pipeline := range config.Pipelines {
+		pipelineVars := map[string]string{}
+		for k, v := range vars {
+			pipelineVars[k] = v
+		}
+
+		if pipeline.Env != "" {
+			pipelineVars["env"] = pipeline.Env
+		}
+
+		resolved, err := resolveConfig(pipeline, pipelineVars, fmt.Sprintf("pipelines[%v].", i))
+		if err != nil {
+			return config, err
+		}
+
+		pipelines[i] = resolved
+	}
+
+	config.Pipelines = pipelines
+
+	return config, nil
+}
+
+// resolveConfig resolves the references in config, prefix is added to the paths in errors
+func resolveConfig(config types.Config, vars map[string]string, prefix string) (types.Config, error) {
+
+	values := map[string]string{}
+	collect(reflect.ValueOf(config), "", values)
+
+	resolved := map[string]string{}
+
+	var resolve func(path string, chain []string) (string, error)
+	resolve = func(path string, chain []string) (string, error) {
+
+		if value, ok := resolved[path]; ok {
+			return value, nil
+		}
+
+		for _, p := range chain {
+			if p == path {
+				return "", fmt.Errorf("%v%v: circular reference: %v -> %v", prefix, chain[0], strings.Join(chain, " -> "), path)
+			}
+		}
+		chain = append(chain, path)
+
+		var err error
+		value := reference.ReplaceAllStringFunc(values[path], func(match string) string {
+			name := reference.FindStringSubmatch(match)[1]
+
+			// Deferred references are kept as they are, even if escaped, since they are resolved by Output
+			if err != nil || isDeferred(name) {
+				return match
+			}
+
+			if strings.HasPrefix(match, "$$") {
+				return match[1:]
+			}
+
+			if v, ok := vars[name]; ok {
+				return v
+			}
+
+			if value, ok := values[name]; ok && value == "" {
+				err = fmt.Errorf("%v%v: reference %v is empty", prefix, chain[0], match)
+				return match
+			} else if ok {
+				var v string
+				v, err = resolve(name, chain)
+				return v
+			}
+
+			err = fmt.Errorf("%v%v: unknown reference %v", prefix, chain[0], match)
+			return match
+		})
+		if err != nil {
+			return "", err
+		}
+
+		resolved[path] = value
+
+		return value, nil
+	}
+
+	paths := make([]string, 0, len(values))
+	for path := range values {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if _, err := resolve(path, nil); err != nil {
+			return config, err
+		}
+	}
+
+	result := reflect.New(reflect.TypeOf(config)).Elem()
+	result.Set(reflect.ValueOf(config))
+	replace(result, "", resolved)
+
+	return result.Interface().(types.Config), nil
+}
+
+// collect adds every string in value to values with its yaml path
+func collect(value reflect.Value, path string, values map[string]string) {
+
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !value.IsNil() {
+			collect(value.Elem(), path, values)
+		}
+	case reflect.String:
+		values[path] = value.String()
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			collect(value.Index(i), fmt.Sprintf("%v[%v]", path, i), values)
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			collect(value.MapIndex(key), join(path, fmt.Sprint(key.Interface())), values)
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			tag := strings.Split(value.Type().Field(i).Tag.Get("mapstructure"), ",")[0]
+			if tag == "" || tag == "-" || tag == "pipelines" {
+				continue
+			}
+			collect(value.Field(i), join(path, tag), values)
+		}
+	}
+}
+
+// replace sets the resolved strings in value, slices, maps and pointers are copied so the given config is not modified
+func replace(value reflect.Value, path string, resolved map[string]string) {
+
+	switch value.Kind() {
+	case reflect.Pointer:
+		if value.IsNil() {
+			return
+		}
+		copied := reflect.New(value.Elem().Type())
+		copied.Elem().Set(value.Elem())
+		replace(copied.Elem(), path, resolved)
+		value.Set(copied)
+	case reflect.String:
+		if v, ok := resolved[path]; ok {
+			value.SetString(v)
+		}
+	case reflect.Slice:
+		if value.IsNil() {
+			return
+		}
+		copied := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		reflect.Copy(copied, value)
+		for i := 0; i < copied.Len(); i++ {
+			replace(copied.Index(i), fmt.Sprintf("%v[%v]", path, i), resolved)
+		}
+		value.Set(copied)
+	case reflect.Map:
+		if value.IsNil() || value.Type().Elem().Kind() != reflect.String {
+			return
+		}
+		copied := reflect.MakeMapWithSize(value.Type(), value.Len())
+		for _, key := range value.MapKeys() {
+			v := value.MapIndex(key).String()
+			if r, ok := resolved[join(path, fmt.Sprint(key.Interface()))]; ok {
+				v = r
+			}
+			copied.SetMapIndex(key, reflect.ValueOf(v).Convert(value.Type().Elem()))
+		}
+		value.Set(copied)
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			tag := strings.Split(value.Type().Field(i).Tag.Get("mapstructure"), ",")[0]
+			if tag == "" || tag == "-" || tag == "pipelines" {
+				continue
+			}
+			replace(value.Field(i), join(path, tag), resolved)
+		}
+	}
+}
+
+// join joins the yaml path and the key
+func join(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// Output resolves the references that are kept by Config, such as ${outputs.firehose.name}, with given outputs.
+// $${outputs.firehose.name} is written as ${outputs.firehose.name}. The value is returned as it is if it has no reference.
+func Output(value string, outputs map[string]pulumi.StringOutput) (pulumi.StringOutput, error) {
+
+	inputs := []interface{}{}
+	indexes := map[string]int{}
+
+	for _, match := range reference.FindAllStringSubmatch(value, -1) {
+		name := match[1]
+		if strings.HasPrefix(match[0], "$$") || !isDeferred(name) {
+			continue
+		}
+
+		if _, ok := indexes[name]; ok {
+			continue
+		}
+
+		output, ok := outputs[name]
+		if !ok {
+			return pulumi.StringOutput{}, fmt.Errorf("unknown reference ${%v}, available references are: %v", name, available(outputs))
+		}
+
+		indexes[name] = len(inputs)
+		inputs = append(inputs, output)
+	}
+
+	if len(inputs) == 0 {
+		return pulumi.String(substitute(value, nil, nil)).ToStringOutput(), nil
+	}
+
+	return pulumi.All(inputs...).ApplyT(func(values []interface{}) (string, error) {
+		return substitute(value, indexes, values), nil
+	}).(pulumi.StringOutput), nil
+}
+
+// substitute replaces the deferred references in value with given values and unescapes the escaped ones
+func substitute(value string, indexes map[string]int, values []interface{}) string {
+	return reference.ReplaceAllStringFunc(value, func(match string) string {
+		name := reference.FindStringSubmatch(match)[1]
+
+		if !isDeferred(name) {
+			return match
+		}
+
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+
+		return values[indexes[name]].(string)
+	})
+}
+
+// available returns the names of given outputs in order
+func available(outputs map[string]pulumi.StringOutput) string {
+	if len(outputs) == 0 {
+		return "none"
+	}
+
+	names := []string{}
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
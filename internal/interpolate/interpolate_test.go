@@ -0,0 +1,130 @@
+package interpolate
+
+import (
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/stretchr/testify/suite"
+	"sync"
+	"testing"
+)
+
+type interpolateTestSuite struct {
+	suite.Suite
+}
+
+type mocks int
+
+func (m mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	return args.Args, nil
+}
+
+func (m mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	return args.Name + "_id", args.Inputs, nil
+}
+
+func (ts *interpolateTestSuite) TestConfigResolvesPathsAndVars() {
+	config := types.Config{
+		Env:     "prod",
+		Storage: types.Storage{Name: "ptemplate-${env}-${stack}"},
+		Stream:  types.Stream{Name: "${storage.name}-stream"},
+		APIGateway: types.APIGateway{Routes: []types.Routes{{Integrations: []types.Integrations{{
+			ReqTemplate: []types.ReqTemplate{{Key: "application/json", Val: `{"DeliveryStreamName": "${stream.name}"}`}},
+		}}}}},
+		Function: types.Function{Build: types.Build{Envs: map[string]string{"STREAM": "${stream.name}"}}},
+	}
+
+	resolved, err := Config(config, map[string]string{"env": "prod", "stack": "game"})
+
+	ts.NoError(err)
+	ts.Equal("ptemplate-prod-game", resolved.Storage.Name)
+	ts.Equal("ptemplate-prod-game-stream", resolved.Stream.Name)
+	ts.Equal(`{"DeliveryStreamName": "ptemplate-prod-game-stream"}`, resolved.APIGateway.Routes[0].Integrations[0].ReqTemplate[0].Val)
+	ts.Equal("ptemplate-prod-game-stream", resolved.Function.Build.Envs["STREAM"])
+
+	// given config is not modified
+	ts.Equal("${stream.name}", config.Function.Build.Envs["STREAM"])
+	ts.Equal(`{"DeliveryStreamName": "${stream.name}"}`, config.APIGateway.Routes[0].Integrations[0].ReqTemplate[0].Val)
+}
+
+func (ts *interpolateTestSuite) TestConfigKeepsDeferredAndEscapedReferences() {
+	config := types.Config{
+		Iam: types.Iam{Roles: []types.Roles{{
+			Name:         "pubsub",
+			Member:       "serviceAccount:service-${project.number}@gcp-sa-pubsub.iam.gserviceaccount.com",
+			InlinePolicy: `{"Resource": "${outputs.firehose.arn}"}`,
+		}}},
+		Stream: types.Stream{Name: "$${stream.name}"},
+	}
+
+	resolved, err := Config(config, nil)
+
+	ts.NoError(err)
+	ts.Equal("serviceAccount:service-${project.number}@gcp-sa-pubsub.iam.gserviceaccount.com", resolved.Iam.Roles[0].Member)
+	ts.Equal(`{"Resource": "${outputs.firehose.arn}"}`, resolved.Iam.Roles[0].InlinePolicy)
+	ts.Equal("${stream.name}", resolved.Stream.Name)
+}
+
+func (ts *interpolateTestSuite) TestConfigRejectsUnknownAndCircularReferences() {
+	_, err := Config(types.Config{Stream: types.Stream{Name: "${storage.bucket}"}}, nil)
+	ts.EqualError(err, "stream.name: unknown reference ${storage.bucket}")
+
+	_, err = Config(types.Config{Stream: types.Stream{Name: "${storage.name}"}, Storage: types.Storage{Name: "${stream.name}"}}, nil)
+	ts.EqualError(err, "storage.name: circular reference: storage.name -> stream.name -> storage.name")
+}
+
+func (ts *interpolateTestSuite) TestConfigResolvesEachPipeline() {
+	config := types.Config{
+		Env: "development",
+		Pipelines: []types.Config{
+			{Name: "studio-a", Storage: types.Storage{Name: "${name}-${env}"}},
+			{Name: "studio-b", Env: "prod", Storage: types.Storage{Name: "${name}-${env}"}},
+			{Name: "studio-c", Stream: types.Stream{Name: "${storage.name}"}},
+		},
+	}
+
+	_, err := Config(config, map[string]string{"env": "development"})
+	ts.EqualError(err, "pipelines[2].stream.name: reference ${storage.name} is empty")
+
+	config.Pipelines = config.Pipelines[:2]
+
+	resolved, err := Config(config, map[string]string{"env": "development"})
+
+	ts.NoError(err)
+	ts.Equal("studio-a-development", resolved.Pipelines[0].Storage.Name)
+	ts.Equal("studio-b-prod", resolved.Pipelines[1].Storage.Name)
+	ts.Equal("${name}-${env}", config.Pipelines[0].Storage.Name)
+}
+
+func (ts *interpolateTestSuite) TestOutput() {
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		outputs := map[string]pulumi.StringOutput{
+			"outputs.firehose.name": pulumi.String("events").ToStringOutput(),
+			"project.number":        pulumi.String("1234").ToStringOutput(),
+		}
+
+		value, err := Output(`{"DeliveryStreamName": "${outputs.firehose.name}", "Member": "service-${project.number}", "Escaped": "$${outputs.firehose.name}"}`, outputs)
+		ts.NoError(err)
+
+		_, err = Output("${outputs.topic.name}", outputs)
+		ts.EqualError(err, "unknown reference ${outputs.topic.name}, available references are: outputs.firehose.name, project.number")
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		value.ApplyT(func(v string) error {
+			ts.Equal(`{"DeliveryStreamName": "events", "Member": "service-1234", "Escaped": "${outputs.firehose.name}"}`, v)
+			wg.Done()
+			return nil
+		})
+
+		wg.Wait()
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)))
+	ts.NoError(err)
+}
+
+func TestRunInterpolateSuite(t *testing.T) {
+	suite.Run(t, &interpolateTestSuite{})
+}
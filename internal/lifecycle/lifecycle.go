@@ -0,0 +1,136 @@
+// Package lifecycle maps the options block of the components in config to pulumi resource options.
+// Ex:
+//
+//	dwh:
+//	  options:
+//	    protect: true
+//	    ignore_changes: [masterPassword]
+//	    timeouts:
+//	      create: 75m
+package lifecycle
+
+import (
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"slices"
+)
+
+// Components that have an options block, it is applied to the main resources of the component.
+const (
+	Storage    = "storage"
+	Dwh        = "dwh"
+	Stream     = "stream"
+	Function   = "function"
+	ApiGateway = "api_gateway"
+	Authorizer = "authorizer"
+)
+
+// Components gives the components that have an options block
+var Components = []string{Storage, Dwh, Stream, Function, ApiGateway, Authorizer}
+
+// protect is the value of Protect in the defaults
+var protect = true
+
+// Defaults gives the options of the components for an env, they are used for the options that are not given in config.
+// Storage and DWH are protected in prod, so destroying the stack does not drop the data. Give protect: false to turn it off.
+var Defaults = map[string]map[string]types.Options{
+	"prod": {
+		Storage: {Protect: &protect},
+		Dwh:     {Protect: &protect},
+	},
+}
+
+// Of returns the options of given component in config, defaults of the env are used for the options that are not given
+func Of(config types.Config, component string) types.Options {
+
+	var given types.Options
+
+	switch component {
+	case Storage:
+		given = config.Storage.Options
+	case Dwh:
+		given = config.Dwh.Options
+	case Stream:
+		given = config.Stream.Options
+	case Function:
+		given = config.Function.Options
+	case ApiGateway:
+		given = config.APIGateway.Options
+	case Authorizer:
+		given = config.Authorizer.Options
+	}
+
+	return merge(Defaults[config.Env][component], given)
+}
+
+// merge returns given options on defaults
+func merge(defaults types.Options, given types.Options) types.Options {
+
+	options := defaults
+
+	if given.Protect != nil {
+		options.Protect = given.Protect
+	}
+
+	options.RetainOnDelete = options.RetainOnDelete || given.RetainOnDelete
+	options.DeleteBeforeReplace = options.DeleteBeforeReplace || given.DeleteBeforeReplace
+
+	if len(given.IgnoreChanges) > 0 {
+		options.IgnoreChanges = given.IgnoreChanges
+	}
+
+	if given.Timeouts != (types.Timeouts{}) {
+		options.Timeouts = given.Timeouts
+	}
+
+	return options
+}
+
+// Options returns the resource options of the resources of given component, opts are added to them
+func Options(config types.Config, component string, opts ...pulumi.ResourceOption) []pulumi.ResourceOption {
+
+	options := Of(config, component)
+
+	if options.Protect != nil {
+		opts = append(opts, pulumi.Protect(*options.Protect))
+	}
+
+	if options.RetainOnDelete {
+		opts = append(opts, pulumi.RetainOnDelete(true))
+	}
+
+	if options.DeleteBeforeReplace {
+		opts = append(opts, pulumi.DeleteBeforeReplace(true))
+	}
+
+	if len(options.IgnoreChanges) > 0 {
+		opts = append(opts, pulumi.IgnoreChanges(options.IgnoreChanges))
+	}
+
+	if options.Timeouts != (types.Timeouts{}) {
+		opts = append(opts, pulumi.Timeouts(&pulumi.CustomTimeouts{
+			Create: options.Timeouts.Create,
+			Update: options.Timeouts.Update,
+			Delete: options.Timeouts.Delete,
+		}))
+	}
+
+	return opts
+}
+
+// Children returns the option of a component resource that gives the resource options of given component to it and its children.
+// Pulumi does not pass options such as protect from a component to its children, so they are added to every resource in it.
+func Children(config types.Config, component string) pulumi.ResourceOption {
+
+	options := Options(config, component)
+
+	return pulumi.Transformations([]pulumi.ResourceTransformation{
+		func(args *pulumi.ResourceTransformationArgs) *pulumi.ResourceTransformationResult {
+			if len(options) == 0 {
+				return nil
+			}
+
+			return &pulumi.ResourceTransformationResult{Props: args.Props, Opts: append(slices.Clone(args.Opts), options...)}
+		},
+	})
+}
@@ -0,0 +1,108 @@
+package lifecycle
+
+import (
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/s3"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"github.com/stretchr/testify/suite"
+	"sync"
+	"testing"
+)
+
+type lifecycleTestSuite struct {
+	suite.Suite
+}
+
+// mocks records the register requests of the resources by name
+type mocks struct {
+	mu       sync.Mutex
+	requests map[string]*pulumirpc.RegisterResourceRequest
+}
+
+func (m *mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	return args.Args, nil
+}
+
+func (m *mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[args.Name] = args.RegisterRPC
+	return args.Name + "_id", args.Inputs, nil
+}
+
+// run runs program with mocks and returns the register requests
+func (ts *lifecycleTestSuite) run(program pulumi.RunFunc) map[string]*pulumirpc.RegisterResourceRequest {
+	m := &mocks{requests: map[string]*pulumirpc.RegisterResourceRequest{}}
+	ts.Require().NoError(pulumi.RunErr(program, pulumi.WithMocks("project", "stack", m)))
+	return m.requests
+}
+
+func (ts *lifecycleTestSuite) TestOf() {
+	unprotect := false
+
+	// Storage and DWH are protected in prod
+	ts.True(*Of(types.Config{Env: "prod"}, Storage).Protect)
+	ts.True(*Of(types.Config{Env: "prod"}, Dwh).Protect)
+	ts.Nil(Of(types.Config{Env: "prod"}, Stream).Protect)
+	ts.Nil(Of(types.Config{Env: "dev"}, Storage).Protect)
+
+	// Given options win over the defaults
+	config := types.Config{Env: "prod", Storage: types.Storage{Options: types.Options{Protect: &unprotect, IgnoreChanges: []string{"tags"}}}}
+	ts.Equal(types.Options{Protect: &unprotect, IgnoreChanges: []string{"tags"}}, Of(config, Storage))
+}
+
+func (ts *lifecycleTestSuite) TestOptions() {
+	config := types.Config{Env: "prod", Dwh: types.Dwh{Options: types.Options{
+		RetainOnDelete:      true,
+		IgnoreChanges:       []string{"masterPassword"},
+		DeleteBeforeReplace: true,
+		Timeouts:            types.Timeouts{Create: "75m"},
+	}}}
+
+	requests := ts.run(func(ctx *pulumi.Context) error {
+		_, err := s3.NewBucket(ctx, "dwh", nil, Options(config, Dwh)...)
+		if err != nil {
+			return err
+		}
+
+		_, err = s3.NewBucket(ctx, "stream", nil, Options(config, Stream)...)
+		return err
+	})
+
+	dwh := requests["dwh"]
+	ts.True(dwh.GetProtect())
+	ts.True(dwh.GetRetainOnDelete())
+	ts.True(dwh.GetDeleteBeforeReplace())
+	ts.Equal([]string{"masterPassword"}, dwh.GetIgnoreChanges())
+	ts.Equal("75m", dwh.GetCustomTimeouts().GetCreate())
+
+	stream := requests["stream"]
+	ts.False(stream.GetProtect())
+	ts.Empty(stream.GetIgnoreChanges())
+}
+
+func (ts *lifecycleTestSuite) TestChildren() {
+	config := types.Config{Env: "prod"}
+
+	requests := ts.run(func(ctx *pulumi.Context) error {
+		component := &pulumi.ResourceState{}
+		err := ctx.RegisterComponentResource("ptemplate:test:Storage", "storage", component, Children(config, Storage))
+		if err != nil {
+			return err
+		}
+
+		_, err = s3.NewBucket(ctx, "bucket", nil, pulumi.Parent(component))
+		return err
+	})
+
+	// Options of the component are given to its children
+	ts.True(requests["storage"].GetProtect())
+	ts.True(requests["bucket"].GetProtect())
+}
+
+func TestRunLifecycleSuite(t *testing.T) {
+	suite.Run(t, &lifecycleTestSuite{})
+}
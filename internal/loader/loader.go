@@ -0,0 +1,236 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/mitchellh/mapstructure"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// extendsKey gives the key of the base file in a config
+const extendsKey = "extends"
+
+// Load reads the config in given path and decodes it to types.Config.
+// If the config extends a base file, base is read first and the config is merged on it, bases can extend other files too.
+// For each file in the chain, the overlay of given env is merged on it if it exists. Ex: config.prod.yaml for config.yaml and "prod"
+// If env is empty, env in the config is used. Keys that do not match any field of types.Config are returned as unknown keys.
+func Load(path string, env string) (types.Config, []string, error) {
+
+	config := types.Config{}
+
+	// Without the stack env, env given in the config chain selects the overlays
+	if env == "" {
+		settings, err := resolve(path, "", nil)
+		if err != nil {
+			return config, nil, err
+		}
+
+		env, _ = settings["env"].(string)
+	}
+
+	settings, err := resolve(path, env, nil)
+	if err != nil {
+		return config, nil, err
+	}
+
+	if env != "" {
+		settings["env"] = env
+	}
+
+	// Settings are decoded as they are, keys of maps such as tags and function.build.envs keep their case
+	var metadata mapstructure.Metadata
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           &config,
+		Metadata:         &metadata,
+		WeaklyTypedInput: true,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+	})
+	if err != nil {
+		return config, nil, fmt.Errorf("config %v: %w", path, err)
+	}
+
+	if err := decoder.Decode(settings); err != nil {
+		return config, nil, fmt.Errorf("config %v: %w", path, err)
+	}
+
+	return config, metadata.Unused, nil
+}
+
+// resolve reads the config in given path with its bases and env overlays and returns the merged settings.
+// chain gives the files that are being resolved, it is used to find circular extends.
+func resolve(path string, env string, chain []string) (map[string]interface{}, error) {
+
+	for _, p := range chain {
+		if p == path {
+			return nil, fmt.Errorf("config %v: circular extends: %v -> %v", path, strings.Join(chain, " -> "), path)
+		}
+	}
+	chain = append(chain, path)
+
+	settings, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if overlay, err := readOverlay(path, env); err != nil {
+		return nil, err
+	} else if overlay != nil {
+		settings = Merge(settings, overlay)
+	}
+
+	extends, ok := settings[extendsKey]
+	if !ok {
+		return settings, nil
+	}
+	delete(settings, extendsKey)
+
+	basePath, ok := extends.(string)
+	if !ok || basePath == "" {
+		return nil, fmt.Errorf("config %v: extends must be a path", path)
+	}
+
+	// Base path is relative to the file that extends it
+	if !filepath.IsAbs(basePath) {
+		basePath = filepath.Join(filepath.Dir(path), basePath)
+	}
+
+	base, err := resolve(basePath, env, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	return Merge(base, settings), nil
+}
+
+// overlayPath returns the path of the env overlay of given config. Ex: configs/config.prod.yaml for configs/config.yaml
+func overlayPath(path string, env string) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%v.%v%v", strings.TrimSuffix(path, ext), env, ext)
+}
+
+// readOverlay reads the env overlay of given config, nil is returned if env is empty or overlay does not exist
+func readOverlay(path string, env string) (map[string]interface{}, error) {
+
+	if env == "" {
+		return nil, nil
+	}
+
+	overlay, err := read(overlayPath(path, env))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := overlay[extendsKey]; ok {
+		return nil, fmt.Errorf("config %v: extends can not be given in an overlay", overlayPath(path, env))
+	}
+
+	return overlay, nil
+}
+
+// read reads the yaml file in given path
+func read(path string) (map[string]interface{}, error) {
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config %v: %w", path, err)
+	}
+
+	settings := map[string]interface{}{}
+	if err := yaml.Unmarshal(content, &settings); err != nil {
+		return nil, fmt.Errorf("config %v: %w", path, err)
+	}
+
+	return settings, nil
+}
+
+// Merge returns base merged with overlay, neither of them is modified.
+// Maps are merged key by key and values in overlay replace the ones in base.
+// Lists whose items all have a name, such as iam.roles and api_gateway.routes, are merged by name:
+// an item in overlay is merged on the base item with the same name, other items are appended in order.
+// Other lists in overlay replace the ones in base.
+func Merge(base map[string]interface{}, overlay map[string]interface{}) map[string]interface{} {
+
+	merged := map[string]interface{}{}
+	for key, value := range base {
+		merged[key] = value
+	}
+
+	for key, value := range overlay {
+		merged[key] = mergeValue(merged[key], value)
+	}
+
+	return merged
+}
+
+// mergeValue merges overlay value on base value according to their types
+func mergeValue(base interface{}, overlay interface{}) interface{} {
+
+	switch overlayValue := overlay.(type) {
+	case map[string]interface{}:
+		if baseValue, ok := base.(map[string]interface{}); ok {
+			return Merge(baseValue, overlayValue)
+		}
+	case []interface{}:
+		if baseValue, ok := base.([]interface{}); ok && isKeyed(baseValue) && isKeyed(overlayValue) {
+			return mergeList(baseValue, overlayValue)
+		}
+	}
+
+	return overlay
+}
+
+// isKeyed returns true if every item of the list is a map that has a name
+func isKeyed(list []interface{}) bool {
+	for _, item := range list {
+		if _, ok := nameOf(item); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// nameOf returns name of the list item
+func nameOf(item interface{}) (string, bool) {
+	object, ok := item.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	name, ok := object["name"].(string)
+	return name, ok
+}
+
+// mergeList merges the items of lists by their names
+func mergeList(base []interface{}, overlay []interface{}) []interface{} {
+
+	merged := make([]interface{}, len(base))
+	copy(merged, base)
+
+	indexes := map[string]int{}
+	for i, item := range merged {
+		name, _ := nameOf(item)
+		indexes[name] = i
+	}
+
+	for _, item := range overlay {
+		name, _ := nameOf(item)
+		if i, ok := indexes[name]; ok {
+			merged[i] = Merge(merged[i].(map[string]interface{}), item.(map[string]interface{}))
+			continue
+		}
+
+		indexes[name] = len(merged)
+		merged = append(merged, item)
+	}
+
+	return merged
+}
@@ -0,0 +1,86 @@
+package loader
+
+import (
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type loaderTestSuite struct {
+	suite.Suite
+}
+
+func (ts *loaderTestSuite) TestLoadWithoutOverlay() {
+	config, unknownKeys, err := Load("testdata/game/config.yaml", "")
+
+	ts.NoError(err)
+	ts.Empty(unknownKeys)
+	ts.Equal("development", config.Env)
+	ts.Equal("aws", config.Cloud)
+	ts.Equal("ptemplate-game-storage", config.Storage.Name)
+	ts.True(config.Storage.ForceDestroy)
+
+	// roles are merged by name, new roles are appended
+	ts.Equal([]types.Roles{
+		{Name: "firehose_role", AssumePolicy: "{}", InlinePolicy: `{"Statement": []}`},
+		{Name: "lambda_role", AssumePolicy: "{}", ForceDetachPolicies: true},
+		{Name: "api_role", AssumePolicy: "{}"},
+	}, config.Iam.Roles)
+}
+
+func (ts *loaderTestSuite) TestLoadWithEnvOverlays() {
+	config, unknownKeys, err := Load("testdata/game/config.yaml", "prod")
+
+	ts.NoError(err)
+	ts.Empty(unknownKeys)
+	ts.Equal("prod", config.Env)
+
+	// overlay of the base is applied
+	ts.False(config.Storage.ForceDestroy)
+	ts.Equal("ptemplate-game-storage", config.Storage.Name)
+
+	// lists without names are replaced
+	ts.Equal([]string{"configureIAM", "createStorage", "createStream"}, config.Template.Instructions)
+
+	// routes and their integrations are merged by name
+	ts.Len(config.APIGateway.Routes, 2)
+	ts.Equal("arn:aws:apigateway:eu-central-1:firehose:action/PutRecordBatch", config.APIGateway.Routes[0].Integrations[0].URI)
+	ts.Equal("health", config.APIGateway.Routes[1].Name)
+}
+
+func (ts *loaderTestSuite) TestLoadReportsUnknownKeys() {
+	_, unknownKeys, err := Load("testdata/game/unknown.yaml", "")
+
+	ts.NoError(err)
+	ts.Equal([]string{"storage.nam"}, unknownKeys)
+}
+
+func (ts *loaderTestSuite) TestLoadKeepsCaseOfMapKeys() {
+	config, unknownKeys, err := Load("testdata/game/keys.yaml", "")
+
+	ts.NoError(err)
+	ts.Empty(unknownKeys)
+	ts.Equal(map[string]string{"CostCenter": "Data"}, config.Tags)
+	ts.Equal(map[string]string{"TOPIC_ID": "events"}, config.Function.Build.Envs)
+	ts.Equal(map[string]string{"force-destroy": "mandatory"}, config.Policies)
+}
+
+func (ts *loaderTestSuite) TestLoadRejectsCircularExtends() {
+	_, _, err := Load("testdata/game/circular.yaml", "")
+
+	ts.EqualError(err, "config testdata/game/circular.yaml: circular extends: testdata/game/circular.yaml -> testdata/game/circular.yaml")
+}
+
+func (ts *loaderTestSuite) TestMergeDoesNotModifyInputs() {
+	base := map[string]interface{}{"roles": []interface{}{map[string]interface{}{"name": "a", "role": "x"}}}
+	overlay := map[string]interface{}{"roles": []interface{}{map[string]interface{}{"name": "a", "role": "y"}}}
+
+	merged := Merge(base, overlay)
+
+	ts.Equal("y", merged["roles"].([]interface{})[0].(map[string]interface{})["role"])
+	ts.Equal("x", base["roles"].([]interface{})[0].(map[string]interface{})["role"])
+}
+
+func TestRunLoaderSuite(t *testing.T) {
+	suite.Run(t, &loaderTestSuite{})
+}
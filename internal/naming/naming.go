@@ -0,0 +1,263 @@
+package naming
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/cemayan/pulumi-template/types"
+	"regexp"
+	"strings"
+)
+
+// DefaultPattern keeps the names in config as they are
+const DefaultPattern = "{component}"
+
+// suffixLength gives the length of the suffix that is added if naming.suffix is true
+const suffixLength = 6
+
+// variable matches the variables in patterns such as {env}
+var variable = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// Service represents a kind of cloud resource that has its own naming rules
+type Service string
+
+const (
+	S3Bucket              Service = "s3_bucket"
+	FirehoseStream        Service = "firehose_stream"
+	RedshiftCluster       Service = "redshift_cluster"
+	IamRole               Service = "iam_role"
+	LambdaFunction        Service = "lambda_function"
+	RestApi               Service = "rest_api"
+	CognitoUserPool       Service = "cognito_user_pool"
+	CognitoUserPoolClient Service = "cognito_user_pool_client"
+	CognitoDomain         Service = "cognito_domain"
+	StorageBucket         Service = "storage_bucket"
+	BigQueryDataset       Service = "bigquery_dataset"
+	BigQueryTable         Service = "bigquery_table"
+	PubSubTopic           Service = "pubsub_topic"
+	PubSubSubscription    Service = "pubsub_subscription"
+	CloudFunction         Service = "cloud_function"
+	ServiceAccount        Service = "service_account"
+	ApiGateway            Service = "api_gateway"
+	ResourceGroup         Service = "resource_group"
+	StorageAccount        Service = "storage_account"
+	BlobContainer         Service = "blob_container"
+	EventHubNamespace     Service = "eventhub_namespace"
+	EventHub              Service = "eventhub"
+	ServicePlan           Service = "service_plan"
+	FunctionApp           Service = "function_app"
+	AdxCluster            Service = "adx_cluster"
+	AdxDatabase           Service = "adx_database"
+	ApiManagement         Service = "api_management"
+	ManagedIdentity       Service = "managed_identity"
+)
+
+// Rule represents the limits of the names of a service
+type Rule struct {
+	// Description is used in errors. Ex: s3 bucket
+	Description string
+	MinLength   int
+	MaxLength   int
+	// Lowercase means uppercase letters are not accepted, names are lowercased.
+	Lowercase bool
+	// Separator replaces "-" in names if the service does not accept it, such as "_" on BigQuery.
+	Separator string
+	// Compact means separators are removed since the service only accepts letters and numbers, such as Azure storage accounts.
+	Compact bool
+	// Charset is the expression that valid names match, Explanation explains it in errors.
+	Charset     *regexp.Regexp
+	Explanation string
+	// Reserved gives the words that names can not contain, ReservedPrefixes gives the words that names can not start with.
+	Reserved         []string
+	ReservedPrefixes []string
+}
+
+// Rules gives the naming rules of every service
+var Rules = map[Service]Rule{
+	S3Bucket: {Description: "s3 bucket", MinLength: 3, MaxLength: 63, Lowercase: true,
+		Charset: regexp.MustCompile(`^[a-z0-9][a-z0-9.-]*[a-z0-9]$`), Explanation: "lowercase letters, numbers, dots and hyphens, starting and ending with a letter or number"},
+	FirehoseStream: {Description: "firehose delivery stream", MinLength: 1, MaxLength: 64,
+		Charset: regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`), Explanation: "letters, numbers, underscores, dots and hyphens"},
+	RedshiftCluster: {Description: "redshift cluster", MinLength: 1, MaxLength: 63, Lowercase: true,
+		Charset: regexp.MustCompile(`^[a-z]([a-z0-9]|-[a-z0-9])*$`), Explanation: "lowercase letters, numbers and single hyphens, starting with a letter and not ending with a hyphen"},
+	IamRole: {Description: "iam role", MinLength: 1, MaxLength: 64,
+		Charset: regexp.MustCompile(`^[\w+=,.@-]+$`), Explanation: "letters, numbers and +=,.@_-"},
+	LambdaFunction: {Description: "lambda function", MinLength: 1, MaxLength: 64,
+		Charset: regexp.MustCompile(`^[a-zA-Z0-9_-]+$`), Explanation: "letters, numbers, underscores and hyphens"},
+	RestApi: {Description: "rest api", MinLength: 1, MaxLength: 1024,
+		Charset: regexp.MustCompile(`^\S(.*\S)?$`), Explanation: "characters without leading or trailing spaces"},
+	CognitoUserPool: {Description: "user pool", MinLength: 1, MaxLength: 128,
+		Charset: regexp.MustCompile(`^[\w\s+=,.@-]+$`), Explanation: "letters, numbers, spaces and +=,.@_-"},
+	CognitoUserPoolClient: {Description: "user pool client", MinLength: 1, MaxLength: 128,
+		Charset: regexp.MustCompile(`^[\w\s+=,.@-]+$`), Explanation: "letters, numbers, spaces and +=,.@_-"},
+	CognitoDomain: {Description: "user pool domain", MinLength: 1, MaxLength: 63, Lowercase: true,
+		Charset: regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`), Explanation: "lowercase letters, numbers and hyphens, starting and ending with a letter or number",
+		Reserved: []string{"aws", "amazon", "cognito"}},
+	StorageBucket: {Description: "storage bucket", MinLength: 3, MaxLength: 63, Lowercase: true,
+		Charset: regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*[a-z0-9]$`), Explanation: "lowercase letters, numbers, dots, underscores and hyphens, starting and ending with a letter or number",
+		Reserved: []string{"google"}, ReservedPrefixes: []string{"goog"}},
+	BigQueryDataset: {Description: "bigquery dataset", MinLength: 1, MaxLength: 1024, Separator: "_",
+		Charset: regexp.MustCompile(`^[a-zA-Z0-9_]+$`), Explanation: "letters, numbers and underscores"},
+	BigQueryTable: {Description: "bigquery table", MinLength: 1, MaxLength: 1024, Separator: "_",
+		Charset: regexp.MustCompile(`^[a-zA-Z0-9_]+$`), Explanation: "letters, numbers and underscores"},
+	PubSubTopic: {Description: "pubsub topic", MinLength: 3, MaxLength: 255,
+		Charset: regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9._~+%-]*$`), Explanation: "letters, numbers and ._~+%-, starting with a letter",
+		ReservedPrefixes: []string{"goog"}},
+	PubSubSubscription: {Description: "pubsub subscription", MinLength: 3, MaxLength: 255,
+		Charset: regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9._~+%-]*$`), Explanation: "letters, numbers and ._~+%-, starting with a letter",
+		ReservedPrefixes: []string{"goog"}},
+	CloudFunction: {Description: "cloud function", MinLength: 1, MaxLength: 63, Lowercase: true,
+		Charset: regexp.MustCompile(`^[a-z]([a-z0-9-]*[a-z0-9])?$`), Explanation: "lowercase letters, numbers and hyphens, starting with a letter and ending with a letter or number"},
+	ServiceAccount: {Description: "service account", MinLength: 6, MaxLength: 30, Lowercase: true,
+		Charset: regexp.MustCompile(`^[a-z]([a-z0-9-]*[a-z0-9])$`), Explanation: "lowercase letters, numbers and hyphens, starting with a letter and ending with a letter or number"},
+	ApiGateway: {Description: "api gateway", MinLength: 1, MaxLength: 50, Lowercase: true,
+		Charset: regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`), Explanation: "lowercase letters, numbers and hyphens, starting and ending with a letter or number"},
+	ResourceGroup: {Description: "resource group", MinLength: 1, MaxLength: 90,
+		Charset: regexp.MustCompile(`^[\w().-]*[\w()-]$`), Explanation: "letters, numbers, underscores, parentheses, dots and hyphens, not ending with a dot"},
+	StorageAccount: {Description: "storage account", MinLength: 3, MaxLength: 24, Lowercase: true, Compact: true,
+		Charset: regexp.MustCompile(`^[a-z0-9]+$`), Explanation: "lowercase letters and numbers"},
+	BlobContainer: {Description: "blob container", MinLength: 3, MaxLength: 63, Lowercase: true,
+		Charset: regexp.MustCompile(`^[a-z0-9]([a-z0-9]|-[a-z0-9])*$`), Explanation: "lowercase letters, numbers and single hyphens, starting and ending with a letter or number"},
+	EventHubNamespace: {Description: "event hub namespace", MinLength: 6, MaxLength: 50,
+		Charset: regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9-]*[a-zA-Z0-9]$`), Explanation: "letters, numbers and hyphens, starting with a letter and ending with a letter or number"},
+	EventHub: {Description: "event hub", MinLength: 1, MaxLength: 256,
+		Charset: regexp.MustCompile(`^[a-zA-Z0-9]([\w.-]*[a-zA-Z0-9])?$`), Explanation: "letters, numbers, underscores, dots and hyphens, starting and ending with a letter or number"},
+	ServicePlan: {Description: "service plan", MinLength: 1, MaxLength: 60,
+		Charset: regexp.MustCompile(`^[a-zA-Z0-9-]+$`), Explanation: "letters, numbers and hyphens"},
+	FunctionApp: {Description: "function app", MinLength: 2, MaxLength: 60,
+		Charset: regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]$`), Explanation: "letters, numbers and hyphens, starting and ending with a letter or number"},
+	AdxCluster: {Description: "adx cluster", MinLength: 4, MaxLength: 22, Lowercase: true, Compact: true,
+		Charset: regexp.MustCompile(`^[a-z][a-z0-9]*$`), Explanation: "lowercase letters and numbers, starting with a letter"},
+	AdxDatabase: {Description: "adx database", MinLength: 1, MaxLength: 260,
+		Charset: regexp.MustCompile(`^[\w .-]+$`), Explanation: "letters, numbers, spaces, underscores, dots and hyphens"},
+	ApiManagement: {Description: "api management", MinLength: 1, MaxLength: 50,
+		Charset: regexp.MustCompile(`^[a-zA-Z]([a-zA-Z0-9-]*[a-zA-Z0-9])?$`), Explanation: "letters, numbers and hyphens, starting with a letter and ending with a letter or number"},
+	ManagedIdentity: {Description: "managed identity", MinLength: 3, MaxLength: 128,
+		Charset: regexp.MustCompile(`^[a-zA-Z0-9][\w-]*$`), Explanation: "letters, numbers, underscores and hyphens, starting with a letter or number"},
+}
+
+// Namer derives the physical names of resources from the names in config
+type Namer struct {
+	naming types.Naming
+	vars   map[string]string
+}
+
+// New returns a Namer for given pipeline, project and stack are the pulumi project and stack.
+// naming.project replaces the pulumi project if it is given.
+func New(config types.Config, project string, stack string) *Namer {
+
+	if config.Naming.Project != "" {
+		project = config.Naming.Project
+	}
+
+	return &Namer{
+		naming: config.Naming,
+		vars: map[string]string{
+			"project":  project,
+			"env":      config.Env,
+			"stack":    stack,
+			"pipeline": config.Name,
+		},
+	}
+}
+
+// Name returns the physical name of component for given service.
+// Pattern of the service in naming.patterns is used if it exists, then naming.pattern and DefaultPattern.
+// Variables that are empty are left out with their separators, if naming.suffix is true a short hash of the project, stack, pipeline
+// and component is added, so the same config gives different names on different stacks but the same name on every run.
+// Names are lowercased if the service does not accept uppercase, an error is returned if the name breaks the rules of the service.
+func (n *Namer) Name(service Service, component string) (string, error) {
+
+	rule, ok := Rules[service]
+	if !ok {
+		return "", fmt.Errorf("naming rule of %v is not found", service)
+	}
+
+	pattern := n.naming.Pattern
+	if p, ok := n.naming.Patterns[string(service)]; ok {
+		pattern = p
+	}
+	if pattern == "" {
+		pattern = DefaultPattern
+	}
+
+	if !strings.Contains(pattern, "{component}") {
+		return "", fmt.Errorf("naming pattern %q of %v must have {component}", pattern, rule.Description)
+	}
+
+	// Empty variables are left out with their separators. Ex: {pipeline}-{component} is events without a pipeline
+	template := pattern
+	for key, value := range n.vars {
+		if value == "" {
+			template = regexp.MustCompile(`\{`+key+`\}[-_.]?`).ReplaceAllString(template, "")
+		}
+	}
+	template = strings.TrimRight(template, "-_.")
+
+	var err error
+	name := variable.ReplaceAllStringFunc(template, func(match string) string {
+		key := strings.Trim(match, "{}")
+		if key == "component" {
+			return component
+		}
+
+		value, ok := n.vars[key]
+		if !ok {
+			err = fmt.Errorf("naming pattern %q has unknown variable %v, valid variables are {project}, {env}, {stack}, {pipeline}, {component}", pattern, match)
+		}
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if n.naming.Suffix {
+		name = fmt.Sprintf("%v-%v", name, n.suffix(service, component))
+	}
+
+	if rule.Lowercase {
+		name = strings.ToLower(name)
+	}
+
+	if rule.Separator != "" {
+		name = strings.ReplaceAll(name, "-", rule.Separator)
+	}
+
+	if rule.Compact {
+		name = strings.NewReplacer("-", "", "_", "", ".", "").Replace(name)
+	}
+
+	return name, check(rule, name)
+}
+
+// suffix returns the hash that is added to the names of component
+func (n *Namer) suffix(service Service, component string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{n.vars["project"], n.vars["stack"], n.vars["pipeline"], string(service), component}, "/")))
+	return hex.EncodeToString(sum[:])[:suffixLength]
+}
+
+// check returns an error if name breaks the rule
+func check(rule Rule, name string) error {
+
+	if len(name) < rule.MinLength || len(name) > rule.MaxLength {
+		return fmt.Errorf("%v name %q is %v characters, it must be between %v and %v", rule.Description, name, len(name), rule.MinLength, rule.MaxLength)
+	}
+
+	if !rule.Charset.MatchString(name) {
+		return fmt.Errorf("%v name %q is not valid, it can have %v", rule.Description, name, rule.Explanation)
+	}
+
+	for _, reserved := range rule.Reserved {
+		if strings.Contains(strings.ToLower(name), reserved) {
+			return fmt.Errorf("%v name %q can not contain %q", rule.Description, name, reserved)
+		}
+	}
+
+	for _, reserved := range rule.ReservedPrefixes {
+		if strings.HasPrefix(strings.ToLower(name), reserved) {
+			return fmt.Errorf("%v name %q can not start with %q", rule.Description, name, reserved)
+		}
+	}
+
+	return nil
+}
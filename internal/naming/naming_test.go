@@ -0,0 +1,113 @@
+package naming
+
+import (
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"testing"
+)
+
+type namingTestSuite struct {
+	suite.Suite
+}
+
+func (ts *namingTestSuite) TestDefaultPatternKeepsNames() {
+	namer := New(types.Config{}, "pulumi-template", "dev")
+
+	name, err := namer.Name(FirehoseStream, "ptemplate-datapipeline-stream")
+
+	ts.NoError(err)
+	ts.Equal("ptemplate-datapipeline-stream", name)
+}
+
+func (ts *namingTestSuite) TestPattern() {
+	namer := New(types.Config{Env: "prod", Naming: types.Naming{Pattern: "{project}-{env}-{component}"}}, "ptemplate", "game-prod")
+
+	name, err := namer.Name(S3Bucket, "Events")
+	ts.NoError(err)
+	ts.Equal("ptemplate-prod-events", name)
+
+	// BigQuery does not accept hyphens
+	name, err = namer.Name(BigQueryDataset, "game-events")
+	ts.NoError(err)
+	ts.Equal("ptemplate_prod_game_events", name)
+}
+
+func (ts *namingTestSuite) TestEmptyVariablesAreLeftOut() {
+	namer := New(types.Config{Naming: types.Naming{Pattern: "{project}-{pipeline}-{component}", Project: "ptemplate"}}, "pulumi-template", "dev")
+
+	name, err := namer.Name(PubSubTopic, "events")
+	ts.NoError(err)
+	ts.Equal("ptemplate-events", name)
+
+	namer = New(types.Config{Name: "studio-a", Naming: types.Naming{Pattern: "{project}-{pipeline}-{component}", Project: "ptemplate"}}, "pulumi-template", "dev")
+
+	name, err = namer.Name(PubSubTopic, "events")
+	ts.NoError(err)
+	ts.Equal("ptemplate-studio-a-events", name)
+}
+
+func (ts *namingTestSuite) TestServicePatternOverridesPattern() {
+	namer := New(types.Config{Env: "development", Naming: types.Naming{
+		Pattern:  "{project}-{env}-{component}",
+		Patterns: map[string]string{"service_account": "{component}"},
+	}}, "pulumi-template", "dev")
+
+	name, err := namer.Name(ServiceAccount, "ptemplate-function")
+	ts.NoError(err)
+	ts.Equal("ptemplate-function", name)
+
+	_, err = namer.Name(ServiceAccount, "ptemplate-function-account-for-events")
+	ts.EqualError(err, `service account name "ptemplate-function-account-for-events" is 37 characters, it must be between 6 and 30`)
+}
+
+func (ts *namingTestSuite) TestSuffixIsStablePerStack() {
+	config := types.Config{Naming: types.Naming{Suffix: true}}
+
+	first, err := New(config, "ptemplate", "dev").Name(S3Bucket, "events")
+	ts.NoError(err)
+
+	second, err := New(config, "ptemplate", "dev").Name(S3Bucket, "events")
+	ts.NoError(err)
+
+	other, err := New(config, "ptemplate", "prod").Name(S3Bucket, "events")
+	ts.NoError(err)
+
+	ts.Equal(first, second)
+	ts.NotEqual(first, other)
+	ts.True(strings.HasPrefix(first, "events-"))
+	ts.Len(first, len("events-")+suffixLength)
+}
+
+func (ts *namingTestSuite) TestCompactNames() {
+	namer := New(types.Config{Env: "prod", Naming: types.Naming{Pattern: "{project}-{env}-{component}", Suffix: true}}, "ptemplate", "dev")
+
+	// Storage accounts only accept lowercase letters and numbers, separators are removed
+	name, err := namer.Name(StorageAccount, "Evts")
+	ts.NoError(err)
+	ts.Regexp(`^ptemplateprodevts[0-9a-f]{6}$`, name)
+
+	_, err = namer.Name(StorageAccount, "studio-events-archive")
+	ts.ErrorContains(err, "storage account name")
+	ts.ErrorContains(err, "it must be between 3 and 24")
+}
+
+func (ts *namingTestSuite) TestInvalidNames() {
+	namer := New(types.Config{Naming: types.Naming{Pattern: "{project}-{component}"}}, "ptemplate", "dev")
+
+	_, err := namer.Name(CognitoDomain, "cognito-login")
+	ts.EqualError(err, `user pool domain name "ptemplate-cognito-login" can not contain "cognito"`)
+
+	_, err = namer.Name(CloudFunction, "events_producer")
+	ts.EqualError(err, `cloud function name "ptemplate-events_producer" is not valid, it can have lowercase letters, numbers and hyphens, starting with a letter and ending with a letter or number`)
+
+	_, err = New(types.Config{Naming: types.Naming{Pattern: "{team}-{component}"}}, "ptemplate", "dev").Name(S3Bucket, "events")
+	ts.EqualError(err, `naming pattern "{team}-{component}" has unknown variable {team}, valid variables are {project}, {env}, {stack}, {pipeline}, {component}`)
+
+	_, err = New(types.Config{Naming: types.Naming{Pattern: "{project}-{env}"}}, "ptemplate", "dev").Name(S3Bucket, "events")
+	ts.EqualError(err, `naming pattern "{project}-{env}" of s3 bucket must have {component}`)
+}
+
+func TestRunNamingSuite(t *testing.T) {
+	suite.Run(t, &namingTestSuite{})
+}
@@ -0,0 +1,167 @@
+package plan
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Graph formats
+const (
+	Mermaid = "mermaid"
+	Dot     = "dot"
+)
+
+// Formats gives the formats that WriteGraph writes
+var Formats = []string{Mermaid, Dot}
+
+// graph represents the resources as nodes, resources that have children such as components group them and edges point to the dependencies of resources
+type graph struct {
+	ids      map[string]string
+	children map[string][]Resource
+	edges    [][2]string
+}
+
+// newGraph returns the graph of the plan, dependencies that are not in the plan such as the stack are left out
+func (p Plan) newGraph() graph {
+
+	g := graph{ids: map[string]string{}, children: map[string][]Resource{}}
+
+	for i, r := range p.Resources {
+		g.ids[r.URN] = fmt.Sprintf("r%v", i)
+	}
+
+	for _, r := range p.Resources {
+		parent := r.Parent
+		if _, ok := g.ids[parent]; !ok {
+			parent = ""
+		}
+		g.children[parent] = append(g.children[parent], r)
+
+		for _, urn := range r.Dependencies {
+			if _, ok := g.ids[urn]; ok && urn != r.URN {
+				g.edges = append(g.edges, [2]string{r.URN, urn})
+			}
+		}
+	}
+
+	return g
+}
+
+// WriteGraph writes the resources and their dependencies as a Mermaid flowchart or a Graphviz DOT graph.
+// Arrows point from a resource to the resources that it depends on, component resources are drawn as groups of their children.
+func (p Plan) WriteGraph(w io.Writer, format string) error {
+
+	var lines []string
+
+	switch format {
+	case Mermaid:
+		lines = p.newGraph().mermaid()
+	case Dot:
+		lines = p.newGraph().dot(p.Project + "-" + p.Stack)
+	default:
+		return fmt.Errorf("graph format must be one of %v, got %q", strings.Join(Formats, ", "), format)
+	}
+
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+// mermaid returns the lines of the graph as a Mermaid flowchart
+func (g graph) mermaid() []string {
+
+	lines := []string{"flowchart LR"}
+	lines = append(lines, g.mermaidNodes("", "  ")...)
+
+	for _, edge := range g.edges {
+		lines = append(lines, fmt.Sprintf("  %v --> %v", g.ids[edge[0]], g.ids[edge[1]]))
+	}
+
+	return lines
+}
+
+// mermaidNodes returns the nodes of the children of parent, resources that have children are subgraphs
+func (g graph) mermaidNodes(parent string, indent string) []string {
+
+	lines := []string{}
+
+	for _, r := range g.children[parent] {
+		label := strings.ReplaceAll(fmt.Sprintf("%v<br/>%v", r.Name, r.Type), `"`, "#quot;")
+
+		if len(g.children[r.URN]) == 0 {
+			lines = append(lines, fmt.Sprintf(`%v%v["%v"]`, indent, g.ids[r.URN], label))
+			continue
+		}
+
+		lines = append(lines, fmt.Sprintf(`%vsubgraph %v["%v"]`, indent, g.ids[r.URN], label))
+		lines = append(lines, g.mermaidNodes(r.URN, indent+"  ")...)
+		lines = append(lines, indent+"end")
+	}
+
+	return lines
+}
+
+// dot returns the lines of the graph as a Graphviz DOT graph, components are clusters.
+// Edges to a component point to its cluster, so compound is set.
+func (g graph) dot(name string) []string {
+
+	lines := []string{
+		fmt.Sprintf("digraph %q {", name),
+		"  rankdir=LR;",
+		"  compound=true;",
+		"  node [shape=box];",
+	}
+	lines = append(lines, g.dotNodes("", "  ")...)
+
+	for _, edge := range g.edges {
+		from, to := edge[0], edge[1]
+
+		attributes := []string{}
+		if len(g.children[from]) > 0 {
+			attributes = append(attributes, fmt.Sprintf("ltail=cluster_%v", g.ids[from]))
+		}
+		if len(g.children[to]) > 0 {
+			attributes = append(attributes, fmt.Sprintf("lhead=cluster_%v", g.ids[to]))
+		}
+
+		line := fmt.Sprintf("  %v -> %v", g.node(from), g.node(to))
+		if len(attributes) > 0 {
+			line += fmt.Sprintf(" [%v]", strings.Join(attributes, ", "))
+		}
+		lines = append(lines, line+";")
+	}
+
+	return append(lines, "}")
+}
+
+// dotNodes returns the nodes of the children of parent, resources that have children are clusters
+func (g graph) dotNodes(parent string, indent string) []string {
+
+	lines := []string{}
+
+	for _, r := range g.children[parent] {
+		label := fmt.Sprintf("%q", r.Name+"\n"+r.Type)
+
+		if len(g.children[r.URN]) == 0 {
+			lines = append(lines, fmt.Sprintf("%v%v [label=%v];", indent, g.ids[r.URN], label))
+			continue
+		}
+
+		lines = append(lines, fmt.Sprintf("%vsubgraph cluster_%v {", indent, g.ids[r.URN]))
+		lines = append(lines, fmt.Sprintf("%v  label=%v;", indent, label))
+		lines = append(lines, g.dotNodes(r.URN, indent+"  ")...)
+		lines = append(lines, indent+"}")
+	}
+
+	return lines
+}
+
+// node returns the node that edges of urn are drawn from or to, clusters can not have edges so the node of their first child is used
+func (g graph) node(urn string) string {
+
+	for len(g.children[urn]) > 0 {
+		urn = g.children[urn][0].URN
+	}
+
+	return g.ids[urn]
+}
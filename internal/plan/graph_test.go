@@ -0,0 +1,89 @@
+package plan_test
+
+import (
+	"bytes"
+	"github.com/cemayan/pulumi-template/internal/plan"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/s3"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type graphTestSuite struct {
+	suite.Suite
+	plan plan.Plan
+}
+
+// SetupSuite plans a component that has a bucket and a bucket outside of it that depends on the component.
+// DependsOn of a component is recorded as the resources in it, so the edge points to the bucket in the component.
+func (ts *graphTestSuite) SetupSuite() {
+
+	p, err := plan.Run(func(ctx *pulumi.Context) error {
+
+		component := &struct{ pulumi.ResourceState }{}
+		if err := ctx.RegisterComponentResource("ptemplate:test:Storage", "storage", component); err != nil {
+			return err
+		}
+
+		if _, err := s3.NewBucket(ctx, "raw", &s3.BucketArgs{}, pulumi.Parent(component)); err != nil {
+			return err
+		}
+
+		_, err := s3.NewBucket(ctx, "logs", &s3.BucketArgs{}, pulumi.DependsOn([]pulumi.Resource{component}))
+		return err
+	}, "project", "dev", nil)
+	ts.Require().NoError(err)
+
+	ts.plan = p
+}
+
+func (ts *graphTestSuite) TestMermaid() {
+	var out bytes.Buffer
+	ts.Require().NoError(ts.plan.WriteGraph(&out, plan.Mermaid))
+
+	ts.Equal(`flowchart LR
+  r0["logs<br/>aws:s3/bucket:Bucket"]
+  subgraph r2["storage<br/>ptemplate:test:Storage"]
+    r1["raw<br/>aws:s3/bucket:Bucket"]
+  end
+  r0 --> r1
+`, out.String())
+}
+
+func (ts *graphTestSuite) TestDot() {
+	var out bytes.Buffer
+	ts.Require().NoError(ts.plan.WriteGraph(&out, plan.Dot))
+
+	ts.Equal(`digraph "project-dev" {
+  rankdir=LR;
+  compound=true;
+  node [shape=box];
+  r0 [label="logs\naws:s3/bucket:Bucket"];
+  subgraph cluster_r2 {
+    label="storage\nptemplate:test:Storage";
+    r1 [label="raw\naws:s3/bucket:Bucket"];
+  }
+  r0 -> r1;
+}
+`, out.String())
+}
+
+func (ts *graphTestSuite) TestUnknownFormat() {
+	ts.EqualError(ts.plan.WriteGraph(&bytes.Buffer{}, "svg"), `graph format must be one of mermaid, dot, got "svg"`)
+}
+
+func (ts *graphTestSuite) TestMermaidOfAzureConfig() {
+	p, err := runConfig(ts.T(), "../../configs/datapipeline/eventhub/storage/function/config.yaml")
+	ts.Require().NoError(err)
+
+	var out bytes.Buffer
+	ts.Require().NoError(p.WriteGraph(&out, plan.Mermaid))
+
+	// Function app uses the keys of the storage account, which the provider generates
+	ts.Contains(out.String(), "azure:storage/account:Account")
+	ts.Contains(out.String(), "azure:appservice/linuxFunctionApp:LinuxFunctionApp")
+}
+
+func TestRunGraphSuite(t *testing.T) {
+	suite.Run(t, &graphTestSuite{})
+}
@@ -0,0 +1,298 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxValueLength gives the length that input values are cut at in the tree, JSON has the full values
+const maxValueLength = 120
+
+// Resource represents a resource that the program registers
+type Resource struct {
+	URN    string `json:"urn"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Parent string `json:"parent,omitempty"`
+	// Custom is false for component resources that only group other resources.
+	Custom bool `json:"custom"`
+	// Read is true for existing resources that are read instead of created.
+	Read bool `json:"read,omitempty"`
+	// Protect is true for resources that can not be deleted, such as storage in prod.
+	Protect bool                   `json:"protect,omitempty"`
+	Inputs  map[string]interface{} `json:"inputs"`
+	// Dependencies gives the URNs of the resources that this resource depends on, with DependsOn or by using their outputs.
+	Dependencies []string `json:"dependencies,omitempty"`
+}
+
+// Plan represents the resources that a program registers
+type Plan struct {
+	Project   string     `json:"project"`
+	Stack     string     `json:"stack"`
+	Resources []Resource `json:"resources"`
+}
+
+// stackReferenceType is the type of pulumi.StackReference
+const stackReferenceType = "pulumi:pulumi:StackReference"
+
+// generated gives the outputs that providers generate by resource type, their values are given as placeholders.
+// They are unknown during a preview, and the mocks can not send secrets that are made of unknown values such as generated passwords.
+var generated = map[string]map[string]string{
+	"random:index/randomPassword:RandomPassword": {"result": "[generated]"},
+	"azure:storage/account:Account": {
+		"primaryAccessKey":              "[generated]",
+		"secondaryAccessKey":            "[generated]",
+		"primaryConnectionString":       "[generated]",
+		"secondaryConnectionString":     "[generated]",
+		"primaryBlobConnectionString":   "[generated]",
+		"secondaryBlobConnectionString": "[generated]",
+	},
+	"azure:eventhub/eventHubNamespace:EventHubNamespace": {
+		"defaultPrimaryKey":                "[generated]",
+		"defaultSecondaryKey":              "[generated]",
+		"defaultPrimaryConnectionString":   "[generated]",
+		"defaultSecondaryConnectionString": "[generated]",
+	},
+	"aws:cognito/userPoolClient:UserPoolClient": {"clientSecret": "[generated]"},
+}
+
+// recorder is the mock resource monitor that records the registered resources
+type recorder struct {
+	project   string
+	stack     string
+	mu        sync.Mutex
+	resources []Resource
+}
+
+// Call returns the arguments of the invoke as its result, lookups such as archive.LookupFile are not executed.
+func (r *recorder) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	return args.Args, nil
+}
+
+// NewResource records the resource and returns its inputs as its outputs
+func (r *recorder) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+
+	var parent string
+	var dependencies []string
+
+	if args.RegisterRPC != nil {
+		parent = args.RegisterRPC.GetParent()
+		dependencies = args.RegisterRPC.GetDependencies()
+	} else if args.ReadRPC != nil {
+		parent = args.ReadRPC.GetParent()
+		dependencies = args.ReadRPC.GetDependencies()
+	}
+
+	dependencies = slices.Clone(dependencies)
+	sort.Strings(dependencies)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.resources = append(r.resources, Resource{
+		URN:          r.urn(parent, args.TypeToken, args.Name),
+		Type:         args.TypeToken,
+		Name:         args.Name,
+		Parent:       parent,
+		Custom:       args.Custom || args.ReadRPC != nil,
+		Read:         args.ReadRPC != nil,
+		Protect:      args.RegisterRPC.GetProtect(),
+		Inputs:       args.Inputs.MapRepl(nil, value),
+		Dependencies: slices.Compact(dependencies),
+	})
+
+	id := args.ID
+	if id == "" {
+		id = args.Name + "_id"
+	}
+
+	outputs := args.Inputs.Copy()
+
+	for key, placeholder := range generated[args.TypeToken] {
+		outputs[resource.PropertyKey(key)] = resource.NewStringProperty(placeholder)
+	}
+
+	// Outputs of referenced stacks are not known without a backend, they are unknown as the program runs as a preview
+	if args.TypeToken == stackReferenceType {
+		outputs["outputs"] = resource.NewObjectProperty(resource.PropertyMap{})
+	}
+
+	return id, outputs, nil
+}
+
+// urn returns the URN of a resource like the pulumi engine does
+func (r *recorder) urn(parent string, typ string, name string) string {
+
+	parentType := ""
+	if parentURN := resource.URN(parent); parentURN != "" && parentURN.QualifiedType() != resource.RootStackType {
+		parentType = string(parentURN.QualifiedType())
+	}
+
+	qualifiedType := typ
+	if parentType != "" {
+		qualifiedType = parentType + "$" + typ
+	}
+
+	return fmt.Sprintf("urn:pulumi:%v::%v::%v::%v", r.stack, r.project, qualifiedType, name)
+}
+
+// value converts the values that can not be written as JSON, secrets are masked
+func value(v resource.PropertyValue) (interface{}, bool) {
+
+	switch {
+	case v.IsSecret():
+		return "[secret]", true
+	case v.IsComputed():
+		return "[unknown]", true
+	case v.IsOutput():
+		output := v.OutputValue()
+		if output.Secret {
+			return "[secret]", true
+		}
+		if !output.Known {
+			return "[unknown]", true
+		}
+		return output.Element.MapRepl(nil, value), true
+	case v.IsAsset():
+		return v.AssetValue().Path, true
+	case v.IsArchive():
+		return v.ArchiveValue().Path, true
+	case v.IsResourceReference():
+		return string(v.ResourceReferenceValue().URN), true
+	}
+
+	return nil, false
+}
+
+// Run runs program with mocks and returns every resource that it registers, nothing is created and no backend is needed.
+// config gives the pulumi config of the program such as config:path. Invokes return their arguments and resources return their inputs,
+// so outputs that are computed by the cloud such as ARNs are empty. Outputs of stack references are unknown.
+func Run(program pulumi.RunFunc, project string, stack string, config map[string]string) (Plan, error) {
+
+	r := &recorder{project: project, stack: stack}
+
+	err := pulumi.RunErr(program, pulumi.WithMocks(project, stack, r), func(info *pulumi.RunInfo) {
+		info.Config = config
+		info.DryRun = true
+	})
+
+	resources := r.resources
+	sort.SliceStable(resources, func(i, j int) bool {
+		return resources[i].URN < resources[j].URN
+	})
+
+	return Plan{Project: project, Stack: stack, Resources: resources}, err
+}
+
+// WriteJSON writes the plan as JSON
+func (p Plan) WriteJSON(w io.Writer) error {
+
+	content, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("plan: %w", err)
+	}
+
+	_, err = fmt.Fprintln(w, string(content))
+	return err
+}
+
+// WriteTree writes the resources as a tree of their parents, each resource is followed by its inputs and dependencies.
+// Long input values are cut, JSON has the full values.
+func (p Plan) WriteTree(w io.Writer) error {
+
+	names := map[string]string{}
+	children := map[string][]Resource{}
+
+	for _, r := range p.Resources {
+		names[r.URN] = fmt.Sprintf("%v %v", r.Type, r.Name)
+
+		parent := r.Parent
+		if resource.URN(parent).QualifiedType() == resource.RootStackType {
+			parent = ""
+		}
+		children[parent] = append(children[parent], r)
+	}
+
+	lines := []string{fmt.Sprintf("%v %v-%v", resource.RootStackType, p.Project, p.Stack)}
+	lines = append(lines, p.tree(children, names, "", "")...)
+
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+// tree returns the lines of the children of parent, indent is added to every line
+func (p Plan) tree(children map[string][]Resource, names map[string]string, parent string, indent string) []string {
+
+	lines := []string{}
+
+	for i, r := range children[parent] {
+		branch, next := "├── ", "│   "
+		if i == len(children[parent])-1 {
+			branch, next = "└── ", "    "
+		}
+
+		title := names[r.URN]
+		if r.Read {
+			title += " (read)"
+		}
+		if r.Protect {
+			title += " (protected)"
+		}
+		lines = append(lines, indent+branch+title)
+
+		keys := []string{}
+		for key := range r.Inputs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			lines = append(lines, fmt.Sprintf("%v%v    %v: %v", indent, next, key, format(r.Inputs[key])))
+		}
+
+		dependencies := []string{}
+		for _, urn := range r.Dependencies {
+			if name, ok := names[urn]; ok {
+				dependencies = append(dependencies, name)
+			} else {
+				dependencies = append(dependencies, urn)
+			}
+		}
+
+		if len(dependencies) > 0 {
+			lines = append(lines, fmt.Sprintf("%v%v    depends on: %v", indent, next, strings.Join(dependencies, ", ")))
+		}
+
+		lines = append(lines, p.tree(children, names, r.URN, indent+next)...)
+	}
+
+	return lines
+}
+
+// format returns value as a single line, strings are written as they are and others as JSON
+func format(value interface{}) string {
+
+	text, ok := value.(string)
+	if !ok {
+		content, err := json.Marshal(value)
+		if err != nil {
+			text = fmt.Sprint(value)
+		} else {
+			text = string(content)
+		}
+	}
+
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > maxValueLength {
+		text = string(runes[:maxValueLength]) + "..."
+	}
+
+	return text
+}
@@ -0,0 +1,160 @@
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/s3"
+	"github.com/pulumi/pulumi-random/sdk/v4/go/random"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type planTestSuite struct {
+	suite.Suite
+}
+
+// program registers a bucket and a role that uses the bucket arn, the role depends on the bucket by its output
+func program(ctx *pulumi.Context) error {
+
+	bucket, err := s3.NewBucket(ctx, "storage", &s3.BucketArgs{
+		Bucket:       pulumi.String(ctx.Stack() + "-storage"),
+		ForceDestroy: pulumi.Bool(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = iam.NewRole(ctx, "firehose", &iam.RoleArgs{
+		AssumeRolePolicy: pulumi.String("{}"),
+		Description:      pulumi.Sprintf("writes to %v", bucket.Bucket),
+		Name:             pulumi.ToSecret(pulumi.String("firehose")).(pulumi.StringOutput),
+	})
+
+	return err
+}
+
+func (ts *planTestSuite) TestRun() {
+	p, err := Run(program, "project", "dev", nil)
+	ts.Require().NoError(err)
+
+	ts.Equal("project", p.Project)
+	ts.Equal("dev", p.Stack)
+	ts.Require().Len(p.Resources, 2)
+
+	role, bucket := p.Resources[0], p.Resources[1]
+
+	ts.Equal("urn:pulumi:dev::project::aws:s3/bucket:Bucket::storage", bucket.URN)
+	ts.Equal("aws:s3/bucket:Bucket", bucket.Type)
+	ts.True(bucket.Custom)
+	ts.Equal(map[string]interface{}{"bucket": "dev-storage", "forceDestroy": true}, bucket.Inputs)
+	ts.Empty(bucket.Dependencies)
+
+	// Outputs of other resources are dependencies, secrets are masked
+	ts.Equal("firehose", role.Name)
+	ts.Equal("writes to dev-storage", role.Inputs["description"])
+	ts.Equal("[secret]", role.Inputs["name"])
+	ts.Equal([]string{bucket.URN}, role.Dependencies)
+}
+
+func (ts *planTestSuite) TestRunWithStackReference() {
+	p, err := Run(func(ctx *pulumi.Context) error {
+		platform, err := pulumi.NewStackReference(ctx, "acme/platform/prod", nil)
+		if err != nil {
+			return err
+		}
+
+		_, err = iam.NewRole(ctx, "firehose", &iam.RoleArgs{
+			AssumeRolePolicy:    pulumi.String("{}"),
+			PermissionsBoundary: platform.GetStringOutput(pulumi.String("boundaryArn")),
+		})
+		return err
+	}, "project", "dev", nil)
+	ts.Require().NoError(err)
+	ts.Require().Len(p.Resources, 2)
+
+	role, platform := p.Resources[0], p.Resources[1]
+
+	ts.True(platform.Read)
+	// Unknown inputs are not sent during a preview
+	ts.NotContains(role.Inputs, "permissionsBoundary")
+	ts.Equal([]string{platform.URN}, role.Dependencies)
+}
+
+func (ts *planTestSuite) TestRunWithProtectedResource() {
+	p, err := Run(func(ctx *pulumi.Context) error {
+		_, err := s3.NewBucket(ctx, "storage", nil, pulumi.Protect(true))
+		return err
+	}, "project", "prod", nil)
+	ts.Require().NoError(err)
+	ts.Require().Len(p.Resources, 1)
+	ts.True(p.Resources[0].Protect)
+
+	var out bytes.Buffer
+	ts.Require().NoError(p.WriteTree(&out))
+	ts.Contains(out.String(), "aws:s3/bucket:Bucket storage (protected)")
+}
+
+func (ts *planTestSuite) TestRunWithGeneratedPassword() {
+	p, err := Run(func(ctx *pulumi.Context) error {
+		password, err := random.NewRandomPassword(ctx, "password", &random.RandomPasswordArgs{Length: pulumi.Int(32)})
+		if err != nil {
+			return err
+		}
+
+		// Secrets that are made of generated values can be sent to the mocks
+		_, err = iam.NewRole(ctx, "firehose", &iam.RoleArgs{
+			AssumeRolePolicy: pulumi.String("{}"),
+			Description:      pulumi.ToSecret(password.Result).(pulumi.StringOutput),
+		})
+		return err
+	}, "project", "dev", nil)
+	ts.Require().NoError(err)
+	ts.Require().Len(p.Resources, 2)
+	ts.Equal("[secret]", p.Resources[0].Inputs["description"])
+}
+
+func (ts *planTestSuite) TestWriteTree() {
+	p, err := Run(program, "project", "dev", nil)
+	ts.Require().NoError(err)
+
+	var out bytes.Buffer
+	ts.Require().NoError(p.WriteTree(&out))
+
+	ts.Equal(`pulumi:pulumi:Stack project-dev
+├── aws:iam/role:Role firehose
+│       assumeRolePolicy: {}
+│       description: writes to dev-storage
+│       name: [secret]
+│       depends on: aws:s3/bucket:Bucket storage
+└── aws:s3/bucket:Bucket storage
+        bucket: dev-storage
+        forceDestroy: true
+`, out.String())
+}
+
+func (ts *planTestSuite) TestWriteJSON() {
+	p, err := Run(program, "project", "dev", nil)
+	ts.Require().NoError(err)
+
+	var out bytes.Buffer
+	ts.Require().NoError(p.WriteJSON(&out))
+
+	var decoded Plan
+	ts.Require().NoError(json.Unmarshal(out.Bytes(), &decoded))
+	ts.Equal(p.Resources[1].URN, decoded.Resources[1].URN)
+	ts.Equal(p.Resources[0].Dependencies, decoded.Resources[0].Dependencies)
+}
+
+func (ts *planTestSuite) TestFormat() {
+	ts.Equal("a b", format("a\n   b"))
+	ts.Equal(`["x",1]`, format([]interface{}{"x", 1}))
+
+	long := format(string(bytes.Repeat([]byte("ü"), maxValueLength+1)))
+	ts.Equal(maxValueLength+3, len([]rune(long)))
+}
+
+func TestRunPlanSuite(t *testing.T) {
+	suite.Run(t, &planTestSuite{})
+}
@@ -0,0 +1,63 @@
+package plan_test
+
+import (
+	"github.com/cemayan/pulumi-template/internal/plan"
+	"github.com/cemayan/pulumi-template/internal/program"
+	"github.com/stretchr/testify/suite"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type shippedTestSuite struct {
+	suite.Suite
+}
+
+// runConfig plans the template program with the config in given path like ptemplate plan does.
+// It runs in a temporary directory since the program writes files such as the OpenAPI spec of GCP.
+func runConfig(t *testing.T, path string) (plan.Plan, error) {
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return plan.Plan{}, err
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return plan.Plan{}, err
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		return plan.Plan{}, err
+	}
+	defer os.Chdir(wd)
+
+	return plan.Run(program.Run, "pulumi-template", "plan", map[string]string{
+		"config:path":    path,
+		"gcp:project":    "ptemplate-plan",
+		"gcp:region":     "europe-west3",
+		"azure:location": "westeurope",
+	})
+}
+
+func (ts *shippedTestSuite) TestRunShippedConfigs() {
+	paths := []string{}
+	err := filepath.WalkDir("../../configs", func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && entry.Name() == "config.yaml" {
+			paths = append(paths, path)
+		}
+		return err
+	})
+	ts.Require().NoError(err)
+	ts.Require().NotEmpty(paths)
+
+	for _, path := range paths {
+		p, err := runConfig(ts.T(), path)
+		ts.NoError(err, path)
+		ts.NotEmpty(p.Resources, path)
+	}
+}
+
+func TestRunShippedSuite(t *testing.T) {
+	suite.Run(t, &shippedTestSuite{})
+}
@@ -0,0 +1,132 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cemayan/pulumi-template/internal/plan"
+	"slices"
+	"strings"
+)
+
+// Types of the resources that the policies check
+const (
+	redshiftClusterType = "aws:redshift/cluster:Cluster"
+	s3BucketType        = "aws:s3/bucket:Bucket"
+	gcsBucketType       = "gcp:storage/bucket:Bucket"
+	iamRoleType         = "aws:iam/role:Role"
+	functionUrlType     = "aws:lambda/functionUrl:FunctionUrl"
+	methodType          = "aws:apigateway/method:Method"
+)
+
+// redshiftPublicAccess reports the clusters that are publicly accessible
+func redshiftPublicAccess(r plan.Resource) []string {
+
+	if r.Type != redshiftClusterType || r.Inputs["publiclyAccessible"] != true {
+		return nil
+	}
+
+	return []string{"cluster is publicly accessible, set dwh.redshift.public_access to false"}
+}
+
+// forceDestroy reports the buckets that are deleted with their objects
+func forceDestroy(r plan.Resource) []string {
+
+	if (r.Type != s3BucketType && r.Type != gcsBucketType) || r.Inputs["forceDestroy"] != true {
+		return nil
+	}
+
+	return []string{"bucket is deleted with its objects, set force_destroy to false"}
+}
+
+// wildcardPolicy reports the statements of inline policies that allow every action of a service, such as s3:*, on every resource
+func wildcardPolicy(r plan.Resource) []string {
+
+	if r.Type != iamRoleType {
+		return nil
+	}
+
+	policies, _ := r.Inputs["inlinePolicies"].([]interface{})
+
+	violations := []string{}
+	for _, policy := range policies {
+		policy, _ := policy.(map[string]interface{})
+		document, _ := policy["policy"].(string)
+
+		var content struct {
+			Statement []struct {
+				Effect   string
+				Action   interface{}
+				Resource interface{}
+			}
+		}
+
+		// Unknown, secret and invalid documents are not checked, documents are validated with the config
+		if err := json.Unmarshal([]byte(document), &content); err != nil {
+			continue
+		}
+
+		for _, statement := range content.Statement {
+			if statement.Effect != "Allow" || !slices.Contains(values(statement.Resource), "*") {
+				continue
+			}
+
+			wildcards := []string{}
+			for _, action := range values(statement.Action) {
+				if action == "*" || strings.HasSuffix(action, ":*") {
+					wildcards = append(wildcards, action)
+				}
+			}
+
+			if len(wildcards) > 0 {
+				violations = append(violations, fmt.Sprintf("inline policy %v allows %v on every resource", policy["name"], strings.Join(wildcards, ", ")))
+			}
+		}
+	}
+
+	return violations
+}
+
+// functionUrlAnyOrigin reports the function URLs that allow every origin
+func functionUrlAnyOrigin(r plan.Resource) []string {
+
+	if r.Type != functionUrlType {
+		return nil
+	}
+
+	cors, _ := r.Inputs["cors"].(map[string]interface{})
+	if !slices.Contains(values(cors["allowOrigins"]), "*") {
+		return nil
+	}
+
+	return []string{"function URL allows every origin"}
+}
+
+// methodWithoutAuth reports the methods that have NONE authorization.
+// OPTIONS methods are left out since CORS preflight requests are sent without credentials.
+func methodWithoutAuth(r plan.Resource) []string {
+
+	if r.Type != methodType || r.Inputs["authorization"] != "NONE" || r.Inputs["httpMethod"] == "OPTIONS" {
+		return nil
+	}
+
+	return []string{fmt.Sprintf("%v method has NONE authorization", r.Inputs["httpMethod"])}
+}
+
+// values returns the strings of a policy field that is either a string or a list of strings
+func values(value interface{}) []string {
+
+	switch value := value.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		strs := []string{}
+		for _, item := range value {
+			if str, ok := item.(string); ok {
+				strs = append(strs, str)
+			}
+		}
+		return strs
+	}
+
+	return nil
+}
@@ -0,0 +1,240 @@
+// Package policy checks the resources of a plan against the built-in policies.
+// Each policy has a level, mandatory violations fail the check and advisory ones are only reported.
+// Levels are overridden by env in Defaults and by policies in config. Ex:
+//
+//	policies:
+//	  force-destroy: mandatory
+//	  function-url-any-origin: disabled
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cemayan/pulumi-template/internal/plan"
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Level represents how a violation of a policy is handled
+type Level string
+
+const (
+	// Advisory violations are reported, they do not fail the check
+	Advisory Level = "advisory"
+	// Mandatory violations fail the check
+	Mandatory Level = "mandatory"
+	// Disabled policies are not checked
+	Disabled Level = "disabled"
+)
+
+// Levels gives the levels that can be given in config
+var Levels = []Level{Advisory, Mandatory, Disabled}
+
+// Names of the policies
+const (
+	RedshiftPublicAccess = "redshift-public-access"
+	ForceDestroy         = "force-destroy"
+	WildcardPolicy       = "wildcard-policy"
+	FunctionUrlAnyOrigin = "function-url-any-origin"
+	MethodWithoutAuth    = "method-without-auth"
+)
+
+// Policy represents a rule that every resource of a plan is checked against
+type Policy struct {
+	Name        string
+	Description string
+	// Level is used if the level is not overridden by env or config
+	Level Level
+	// Check returns the violations of the resource, it returns nothing for the resources that the policy is not about
+	Check func(r plan.Resource) []string
+}
+
+// Policies gives the built-in policies
+var Policies = []Policy{
+	{
+		Name:        RedshiftPublicAccess,
+		Description: "Redshift clusters must not be publicly accessible",
+		Level:       Mandatory,
+		Check:       redshiftPublicAccess,
+	},
+	{
+		Name:        ForceDestroy,
+		Description: "buckets should not be deleted with their objects",
+		Level:       Advisory,
+		Check:       forceDestroy,
+	},
+	{
+		Name:        WildcardPolicy,
+		Description: "IAM policies should not allow every action of a service on every resource",
+		Level:       Advisory,
+		Check:       wildcardPolicy,
+	},
+	{
+		Name:        FunctionUrlAnyOrigin,
+		Description: "Lambda function URLs should not allow every origin",
+		Level:       Advisory,
+		Check:       functionUrlAnyOrigin,
+	},
+	{
+		Name:        MethodWithoutAuth,
+		Description: "API Gateway methods should have an authorization",
+		Level:       Advisory,
+		Check:       methodWithoutAuth,
+	},
+}
+
+// Defaults gives the levels of the policies for an env, they override the levels of the policies.
+// Data can not be dropped and APIs can not be open in prod.
+var Defaults = map[string]map[string]Level{
+	"prod": {
+		ForceDestroy:      Mandatory,
+		MethodWithoutAuth: Mandatory,
+	},
+}
+
+// Names returns the names of the policies
+func Names() []string {
+
+	names := []string{}
+	for _, policy := range Policies {
+		names = append(names, policy.Name)
+	}
+
+	return names
+}
+
+// LevelsOf returns the level of every policy for config.
+// Levels of the policies are overridden by the defaults of the env, then by policies in config.
+func LevelsOf(config types.Config) map[string]Level {
+
+	levels := map[string]Level{}
+
+	for _, policy := range Policies {
+		levels[policy.Name] = policy.Level
+	}
+
+	for name, level := range Defaults[config.Env] {
+		levels[name] = level
+	}
+
+	for name, level := range config.Policies {
+		levels[name] = Level(level)
+	}
+
+	return levels
+}
+
+// Validate checks the policies in config, problems are returned by policy name
+func Validate(config types.Config) map[string]string {
+
+	problems := map[string]string{}
+
+	levels := []string{}
+	for _, level := range Levels {
+		levels = append(levels, string(level))
+	}
+
+	for name, level := range config.Policies {
+		if !slices.Contains(Names(), name) {
+			problems[name] = fmt.Sprintf("unknown policy, must be one of %v", strings.Join(Names(), ", "))
+		} else if !slices.Contains(levels, level) {
+			problems[name] = fmt.Sprintf("must be one of %v, got %q", strings.Join(levels, ", "), level)
+		}
+	}
+
+	return problems
+}
+
+// Violation represents a resource that does not comply with a policy
+type Violation struct {
+	Policy  string `json:"policy"`
+	Level   Level  `json:"level"`
+	URN     string `json:"urn"`
+	Message string `json:"message"`
+}
+
+// Report represents the violations of a plan
+type Report struct {
+	Violations []Violation `json:"violations"`
+}
+
+// Check checks every resource of p against the policies with given levels, disabled policies are skipped.
+// Violations are ordered by level, mandatory first, then by policy and resource.
+func Check(p plan.Plan, levels map[string]Level) Report {
+
+	report := Report{Violations: []Violation{}}
+
+	for _, policy := range Policies {
+		level, ok := levels[policy.Name]
+		if !ok {
+			level = policy.Level
+		}
+
+		if level == Disabled {
+			continue
+		}
+
+		for _, r := range p.Resources {
+			for _, message := range policy.Check(r) {
+				report.Violations = append(report.Violations, Violation{Policy: policy.Name, Level: level, URN: r.URN, Message: message})
+			}
+		}
+	}
+
+	sort.SliceStable(report.Violations, func(i, j int) bool {
+		return report.Violations[i].Level == Mandatory && report.Violations[j].Level != Mandatory
+	})
+
+	return report
+}
+
+// Err returns an error if the report has mandatory violations
+func (r Report) Err() error {
+
+	count := 0
+	for _, violation := range r.Violations {
+		if violation.Level == Mandatory {
+			count++
+		}
+	}
+
+	if count == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("policy check failed with %v mandatory violations", count)
+}
+
+// Write writes every violation on a line with its level, policy and the type and name of its resource
+func (r Report) Write(w io.Writer) error {
+
+	if len(r.Violations) == 0 {
+		_, err := fmt.Fprintln(w, "no violations")
+		return err
+	}
+
+	lines := []string{}
+	for _, violation := range r.Violations {
+		urn := resource.URN(violation.URN)
+		lines = append(lines, fmt.Sprintf("[%v] %v: %v %v: %v", violation.Level, violation.Policy, urn.Type(), urn.Name(), violation.Message))
+	}
+
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+// WriteJSON writes the report as JSON
+func (r Report) WriteJSON(w io.Writer) error {
+
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("policy report: %w", err)
+	}
+
+	_, err = fmt.Fprintln(w, string(content))
+	return err
+}
@@ -0,0 +1,95 @@
+package policy
+
+import (
+	"bytes"
+	"github.com/cemayan/pulumi-template/internal/plan"
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type policyTestSuite struct {
+	suite.Suite
+}
+
+// urn returns the URN of a resource in the test stack
+func urn(typ string, name string) string {
+	return "urn:pulumi:dev::project::" + typ + "::" + name
+}
+
+// resources gives a resource that violates every policy and the ones that comply with them
+var resources = plan.Plan{Project: "project", Stack: "dev", Resources: []plan.Resource{
+	{URN: urn(redshiftClusterType, "cluster"), Type: redshiftClusterType, Inputs: map[string]interface{}{"publiclyAccessible": true}},
+	{URN: urn(redshiftClusterType, "private"), Type: redshiftClusterType, Inputs: map[string]interface{}{"publiclyAccessible": false}},
+	{URN: urn(s3BucketType, "storage"), Type: s3BucketType, Inputs: map[string]interface{}{"forceDestroy": true}},
+	{URN: urn(gcsBucketType, "storage"), Type: gcsBucketType, Inputs: map[string]interface{}{}},
+	{URN: urn(iamRoleType, "firehose"), Type: iamRoleType, Inputs: map[string]interface{}{"inlinePolicies": []interface{}{
+		map[string]interface{}{"name": "firehose-inline", "policy": `{"Statement": [
+			{"Effect": "Allow", "Action": ["s3:*", "firehose:PutRecord"], "Resource": "*"},
+			{"Effect": "Allow", "Action": "firehose:*", "Resource": ["arn:aws:firehose:eu-west-1:1:deliverystream/events"]},
+			{"Effect": "Deny", "Action": "*", "Resource": "*"}
+		]}`},
+		map[string]interface{}{"name": "secret-inline", "policy": "[secret]"},
+	}}},
+	{URN: urn(functionUrlType, "function-url"), Type: functionUrlType, Inputs: map[string]interface{}{"cors": map[string]interface{}{"allowOrigins": []interface{}{"*"}}}},
+	{URN: urn(methodType, "post"), Type: methodType, Inputs: map[string]interface{}{"httpMethod": "POST", "authorization": "NONE"}},
+	{URN: urn(methodType, "options"), Type: methodType, Inputs: map[string]interface{}{"httpMethod": "OPTIONS", "authorization": "NONE"}},
+	{URN: urn(methodType, "get"), Type: methodType, Inputs: map[string]interface{}{"httpMethod": "GET", "authorization": "COGNITO_USER_POOLS"}},
+}}
+
+func (ts *policyTestSuite) TestCheck() {
+	report := Check(resources, LevelsOf(types.Config{Env: "dev"}))
+
+	ts.Equal([]Violation{
+		{Policy: RedshiftPublicAccess, Level: Mandatory, URN: urn(redshiftClusterType, "cluster"), Message: "cluster is publicly accessible, set dwh.redshift.public_access to false"},
+		{Policy: ForceDestroy, Level: Advisory, URN: urn(s3BucketType, "storage"), Message: "bucket is deleted with its objects, set force_destroy to false"},
+		{Policy: WildcardPolicy, Level: Advisory, URN: urn(iamRoleType, "firehose"), Message: "inline policy firehose-inline allows s3:* on every resource"},
+		{Policy: FunctionUrlAnyOrigin, Level: Advisory, URN: urn(functionUrlType, "function-url"), Message: "function URL allows every origin"},
+		{Policy: MethodWithoutAuth, Level: Advisory, URN: urn(methodType, "post"), Message: "POST method has NONE authorization"},
+	}, report.Violations)
+
+	ts.EqualError(report.Err(), "policy check failed with 1 mandatory violations")
+
+	var out bytes.Buffer
+	ts.Require().NoError(report.Write(&out))
+	ts.Contains(out.String(), "[mandatory] redshift-public-access: aws:redshift/cluster:Cluster cluster: cluster is publicly accessible")
+}
+
+func (ts *policyTestSuite) TestLevels() {
+	// Env defaults make the policies mandatory in prod, config overrides them
+	config := types.Config{Env: "prod", Policies: map[string]string{RedshiftPublicAccess: "disabled", MethodWithoutAuth: "advisory"}}
+	report := Check(resources, LevelsOf(config))
+
+	levels := map[string]Level{}
+	for _, violation := range report.Violations {
+		levels[violation.Policy] = violation.Level
+	}
+
+	ts.Equal(map[string]Level{
+		ForceDestroy:         Mandatory,
+		WildcardPolicy:       Advisory,
+		FunctionUrlAnyOrigin: Advisory,
+		MethodWithoutAuth:    Advisory,
+	}, levels)
+	ts.Equal(ForceDestroy, report.Violations[0].Policy)
+
+	report = Check(plan.Plan{}, LevelsOf(config))
+	ts.NoError(report.Err())
+
+	var out bytes.Buffer
+	ts.Require().NoError(report.Write(&out))
+	ts.Equal("no violations\n", out.String())
+}
+
+func (ts *policyTestSuite) TestValidate() {
+	config := types.Config{Policies: map[string]string{ForceDestroy: "strict", "public-bucket": "advisory", WildcardPolicy: "disabled"}}
+
+	ts.Equal(map[string]string{
+		ForceDestroy:    `must be one of advisory, mandatory, disabled, got "strict"`,
+		"public-bucket": "unknown policy, must be one of redshift-public-access, force-destroy, wildcard-policy, function-url-any-origin, method-without-auth",
+	}, Validate(config))
+}
+
+func TestRunPolicySuite(t *testing.T) {
+	suite.Run(t, &policyTestSuite{})
+}
@@ -0,0 +1,82 @@
+package program
+
+import (
+	"errors"
+	"fmt"
+	_cloud "github.com/cemayan/pulumi-template/internal/cloud"
+	"github.com/cemayan/pulumi-template/internal/interpolate"
+	"github.com/cemayan/pulumi-template/internal/loader"
+	"github.com/cemayan/pulumi-template/internal/schema"
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+)
+
+// readConfigFromFile reads and decodes the config yaml in given path.
+// Base files given with extends and the overlays of env such as config.prod.yaml are merged in order.
+// Keys that do not match any field of types.Config are returned as unknown keys.
+func readConfigFromFile(path string, env string) (types.Config, []string, error) {
+	return loader.Load(path, env)
+}
+
+// pipelineError adds the pipeline name to given error if the pipeline is an entry of pipelines
+func pipelineError(pipeline types.Config, err error) error {
+	if pipeline.Name == "" {
+		return err
+	}
+	return fmt.Errorf("pipeline %v: %w", pipeline.Name, err)
+}
+
+// Run is the pulumi program, it creates the pipelines in the config that is given with config:path.
+// It is run by cmd/infra with the pulumi CLI and inline by cmd/ptemplate with the Automation API.
+func Run(ctx *pulumi.Context) error {
+
+	conf := config.New(ctx, "config") // conf gives "config" value in pulumi config file.
+	path := conf.Require("path")      // path gives "config:path" value in pulumi config file.
+	env := conf.Get("env")            // env gives "config:env" value in pulumi config file, env in yaml is used if it is not given.
+
+	appConfigs, unknownKeys, err := readConfigFromFile(path, env)
+	if err != nil {
+		ctx.Log.Error("config file read error", nil)
+		return err
+	}
+
+	// References such as ${stream.name}, ${env} and ${stack} are resolved, references to outputs are resolved by the cloud.
+	appConfigs, err = interpolate.Config(appConfigs, map[string]string{"env": appConfigs.Env, "stack": ctx.Stack()})
+	if err != nil {
+		return err
+	}
+
+	// Config is validated before anything is registered, every problem is reported with its yaml path.
+	if err := schema.Validate(appConfigs, unknownKeys); err != nil {
+		return err
+	}
+
+	pipelines, err := _cloud.Pipelines(appConfigs)
+	if err != nil {
+		return err
+	}
+
+	// Every pipeline is resolved before anything is created, so an invalid pipeline does not leave others half created.
+	builders := []*_cloud.Builder{}
+	for _, pipeline := range pipelines {
+		ctx.Log.Info(fmt.Sprintf("selected cloud is %v %v", pipeline.Cloud, pipeline.Name), nil)
+
+		// It will be initialized according the given cloud provider
+		builder, err := _cloud.NewBuilder(ctx, pipeline)
+		if err != nil {
+			return pipelineError(pipeline, err)
+		}
+
+		builders = append(builders, builder)
+	}
+
+	var errs []error
+	for i, builder := range builders {
+		if err := builder.Build(); err != nil {
+			errs = append(errs, pipelineError(pipelines[i], err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,352 @@
+package schema
+
+import (
+	"fmt"
+	"github.com/cemayan/pulumi-template/internal/policy"
+	"github.com/cemayan/pulumi-template/internal/secret"
+	"github.com/cemayan/pulumi-template/internal/stackref"
+	"github.com/cemayan/pulumi-template/types"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// schemaVersion is the JSON Schema draft that is generated
+const schemaVersion = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema returns the JSON Schema of the config format.
+// Properties are reflected from the mapstructure tags of types.Config, enums and conditional requirements come from Rules,
+// so the schema accepts the same configs as Validate. instructions gives the valid values of template.instructions.
+func JSONSchema(instructions []string) map[string]interface{} {
+
+	pipeline := typeSchema(reflect.TypeOf(types.Config{}))
+
+	properties := pipeline["properties"].(map[string]interface{})
+	properties["extends"] = map[string]interface{}{"type": "string", "description": "path of the base config, relative to this file"}
+	properties["pipelines"] = map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"$ref":     "#/$defs/pipeline",
+			"required": []string{"name"},
+		},
+	}
+
+	if len(instructions) > 0 {
+		instructionsSchema := propertyAt(pipeline, "template.instructions")
+		instructionsSchema["items"] = map[string]interface{}{"enum": instructions}
+	}
+
+	levels := []string{}
+	for _, level := range policy.Levels {
+		levels = append(levels, string(level))
+	}
+	properties["policies"] = map[string]interface{}{
+		"type":                 "object",
+		"propertyNames":        map[string]interface{}{"enum": policy.Names()},
+		"additionalProperties": map[string]interface{}{"enum": levels},
+	}
+
+	conditions := []interface{}{}
+
+	addEnums(pipeline)
+
+	for _, rule := range Rules {
+		if rule.Required || rule.JSON || rule.Secret || rule.StackRef || rule.Duration || rule.Conflicts != "" || len(rule.Clouds) > 0 || rule.When != nil {
+			conditions = append(conditions, ruleSchema(rule))
+		}
+	}
+
+	// Rules are applied on the pipeline itself, not on a config that only lists pipelines.
+	pipeline["allOf"] = []interface{}{
+		map[string]interface{}{
+			"if":   map[string]interface{}{"required": []string{"pipelines"}},
+			"else": map[string]interface{}{"allOf": conditions},
+		},
+	}
+
+	return map[string]interface{}{
+		"$schema": schemaVersion,
+		"$id":     "https://github.com/cemayan/pulumi-template/config.schema.json",
+		"title":   "pulumi-template config",
+		"$ref":    "#/$defs/pipeline",
+		"$defs":   map[string]interface{}{"pipeline": pipeline},
+	}
+}
+
+// typeSchema returns the schema of given type according to its mapstructure tags
+func typeSchema(t reflect.Type) map[string]interface{} {
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			tag := strings.Split(t.Field(i).Tag.Get("mapstructure"), ",")[0]
+			if tag == "" || tag == "-" || t.Field(i).Type == t {
+				continue
+			}
+			if t.Field(i).Type.Kind() == reflect.Slice && t.Field(i).Type.Elem() == t {
+				continue
+			}
+			properties[tag] = typeSchema(t.Field(i).Type)
+		}
+		return map[string]interface{}{"type": "object", "properties": properties, "additionalProperties": false}
+	}
+
+	// interface{} fields accept any value
+	return map[string]interface{}{}
+}
+
+// propertyAt returns the schema of the field at given path, list items are used for "[]" segments
+func propertyAt(schema map[string]interface{}, path string) map[string]interface{} {
+
+	for _, segment := range strings.Split(path, ".") {
+		name, _, isList := parseSegment(segment)
+
+		schema = schema["properties"].(map[string]interface{})[name].(map[string]interface{})
+		if isList {
+			schema = schema["items"].(map[string]interface{})
+		}
+	}
+
+	return schema
+}
+
+// addEnums adds the enum values of rules to the properties, so editors can complete the values of every cloud.
+// If a field is free on some cloud, values are given as examples instead of enum.
+func addEnums(pipeline map[string]interface{}) {
+
+	values := map[string][]string{}
+	clouds := map[string]map[types.CloudProvider]bool{}
+	paths := []string{}
+
+	for _, rule := range Rules {
+		if len(rule.Enum) == 0 {
+			continue
+		}
+
+		if _, ok := values[rule.Path]; !ok {
+			paths = append(paths, rule.Path)
+			clouds[rule.Path] = map[types.CloudProvider]bool{}
+		}
+
+		for _, value := range rule.Enum {
+			if !slices.Contains(values[rule.Path], value) {
+				values[rule.Path] = append(values[rule.Path], value)
+			}
+		}
+
+		ruleClouds := rule.Clouds
+		if len(ruleClouds) == 0 {
+			ruleClouds = []types.CloudProvider{types.Aws, types.Gcp, types.Azure}
+		}
+
+		for _, cloud := range ruleClouds {
+			clouds[rule.Path][cloud] = true
+		}
+	}
+
+	for _, path := range paths {
+		property := propertyAt(pipeline, path)
+
+		if len(clouds[path]) == len(types.CloudMap) {
+			property["enum"] = values[path]
+		} else {
+			property["examples"] = values[path]
+		}
+	}
+}
+
+// ruleSchema returns the conditional schema of rule.
+// Clouds and instructions are checked at the top level, When is checked in the list item if it shares a list with the rule path.
+func ruleSchema(rule Rule) map[string]interface{} {
+
+	// shared gives the path of the deepest list that both rule path and When path are in
+	shared := ""
+	if rule.When != nil {
+		segments := strings.Split(rule.Path, ".")
+		whenSegments := strings.Split(rule.When.Path, ".")
+		for i := 0; i < len(segments)-1 && i < len(whenSegments)-1 && segments[i] == whenSegments[i]; i++ {
+			if strings.HasSuffix(segments[i], "[]") {
+				shared = strings.Join(segments[:i+1], ".")
+			}
+		}
+	}
+
+	relative := func(path string) string {
+		if shared == "" {
+			return path
+		}
+		return strings.TrimPrefix(path, shared+".")
+	}
+
+	constraint := valueSchema(rule, relative(rule.Path))
+
+	if rule.Conflicts != "" {
+		constraint = map[string]interface{}{
+			"allOf": []interface{}{
+				constraint,
+				map[string]interface{}{"not": map[string]interface{}{"allOf": []interface{}{givenSchema(rule.Path), givenSchema(rule.Conflicts)}}},
+			},
+		}
+	}
+
+	// The rule holds if one of the fields in Unless is given
+	if len(rule.Unless) > 0 {
+		anyOf := []interface{}{}
+		for _, path := range rule.Unless {
+			anyOf = append(anyOf, givenSchema(path))
+		}
+		constraint = map[string]interface{}{"anyOf": append(anyOf, constraint)}
+	}
+
+	// The rule holds only if one of the fields in With is given
+	if len(rule.With) > 0 {
+		anyOf := []interface{}{}
+		for _, path := range rule.With {
+			anyOf = append(anyOf, givenSchema(path))
+		}
+		constraint = map[string]interface{}{"if": map[string]interface{}{"anyOf": anyOf}, "then": constraint}
+	}
+
+	if rule.When != nil {
+		constraint = map[string]interface{}{
+			"if":   pathSchema(relative(rule.When.Path), map[string]interface{}{"enum": rule.When.Values}, true),
+			"then": constraint,
+		}
+	}
+
+	if shared != "" {
+		constraint = pathSchema(shared, constraint, false)
+	}
+
+	conditions := []interface{}{}
+
+	if len(rule.Clouds) > 0 {
+		clouds := []string{}
+		for _, cloud := range rule.Clouds {
+			clouds = append(clouds, cloud.String())
+		}
+		conditions = append(conditions, pathSchema("cloud", map[string]interface{}{"enum": clouds}, true))
+	}
+
+	if len(rule.Instructions) > 0 {
+		conditions = append(conditions, pathSchema("template.instructions", map[string]interface{}{"contains": map[string]interface{}{"enum": rule.Instructions}}, true))
+	}
+
+	if len(conditions) == 0 {
+		return constraint
+	}
+
+	return map[string]interface{}{
+		"if":   map[string]interface{}{"allOf": conditions},
+		"then": constraint,
+	}
+}
+
+// valueSchema returns the schema that checks the field at given path according to rule
+func valueSchema(rule Rule, path string) map[string]interface{} {
+
+	leaf := map[string]interface{}{}
+
+	if len(rule.Enum) > 0 {
+		leaf["enum"] = rule.Enum
+	}
+
+	if rule.JSON {
+		leaf["contentMediaType"] = "application/json"
+	}
+
+	// A secret is either a plain value or a reference to one of the sources
+	if rule.Secret {
+		leaf["anyOf"] = []interface{}{
+			map[string]interface{}{"not": map[string]interface{}{"pattern": "^" + secret.Scheme}},
+			map[string]interface{}{"pattern": fmt.Sprintf("^%v(%v)/.+", secret.Scheme, strings.Join(secret.Sources, "|"))},
+		}
+	}
+
+	if rule.Duration {
+		leaf["pattern"] = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+	}
+
+	// A stack reference gives the stack as org/project/stack and the output after #
+	if rule.StackRef {
+		leaf["anyOf"] = []interface{}{
+			map[string]interface{}{"not": map[string]interface{}{"pattern": "^" + stackref.Scheme}},
+			map[string]interface{}{"pattern": fmt.Sprintf("^%v[^/#]+/[^/#]+/[^/#]+#.+$", stackref.Scheme)},
+		}
+	}
+
+	if rule.Required {
+		leaf["not"] = map[string]interface{}{"enum": []interface{}{"", []interface{}{}, nil}}
+	}
+
+	// One of the items of the list must start with the prefix, case is ignored
+	if rule.Prefix != "" {
+		list, name := splitList(path)
+		return pathSchema(list, map[string]interface{}{"contains": pathSchema(name, map[string]interface{}{"pattern": "^" + caseless(rule.Prefix)}, true)}, true)
+	}
+
+	return pathSchema(path, leaf, rule.Required)
+}
+
+// caseless returns the pattern that matches given text in any case
+func caseless(text string) string {
+	pattern := ""
+	for _, r := range text {
+		if lower, upper := unicode.ToLower(r), unicode.ToUpper(r); lower != upper {
+			pattern += fmt.Sprintf("[%c%c]", lower, upper)
+		} else {
+			pattern += regexp.QuoteMeta(string(r))
+		}
+	}
+	return pattern
+}
+
+// givenSchema returns the schema of a config that has a value at given path
+func givenSchema(path string) map[string]interface{} {
+	return pathSchema(path, map[string]interface{}{"not": map[string]interface{}{"enum": []interface{}{"", []interface{}{}, nil}}}, true)
+}
+
+// pathSchema nests leaf in the properties of given path, every item is checked for "[]" segments.
+// If required is true, objects on the path must have the next field. Like Validate, an empty list is valid,
+// so only the fields after the last list are required.
+func pathSchema(path string, leaf map[string]interface{}, required bool) map[string]interface{} {
+
+	segments := strings.Split(path, ".")
+	schema := leaf
+	inList := false
+
+	for i := len(segments) - 1; i >= 0; i-- {
+		name, _, isList := parseSegment(segments[i])
+
+		if isList {
+			schema = map[string]interface{}{"items": schema}
+		}
+
+		object := map[string]interface{}{"properties": map[string]interface{}{name: schema}}
+		if required && !inList && !isList {
+			object["required"] = []string{name}
+		}
+
+		inList = inList || isList
+		schema = object
+	}
+
+	return schema
+}
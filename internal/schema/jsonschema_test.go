@@ -0,0 +1,126 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	_ "github.com/cemayan/pulumi-template/internal/cloud"
+	"github.com/cemayan/pulumi-template/pipeline"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/yaml.v3"
+	"os"
+	"testing"
+)
+
+type jsonSchemaTestSuite struct {
+	suite.Suite
+	schema *jsonschema.Schema
+}
+
+// instructions returns the names of the registered instructions like cmd/schema does
+func instructions() []string {
+	names := []string{}
+	for _, instruction := range pipeline.Instructions() {
+		names = append(names, instruction.Name)
+	}
+	return names
+}
+
+func (ts *jsonSchemaTestSuite) SetupSuite() {
+	content, err := json.Marshal(JSONSchema(instructions()))
+	ts.Require().NoError(err)
+
+	compiler := jsonschema.NewCompiler()
+	ts.Require().NoError(compiler.AddResource("config.schema.json", bytes.NewReader(content)))
+
+	ts.schema, err = compiler.Compile("config.schema.json")
+	ts.Require().NoError(err)
+}
+
+// document converts given yaml to the JSON document that is validated by the schema
+func (ts *jsonSchemaTestSuite) document(content string) interface{} {
+	var value interface{}
+	ts.Require().NoError(yaml.Unmarshal([]byte(content), &value))
+
+	encoded, err := json.Marshal(value)
+	ts.Require().NoError(err)
+
+	var document interface{}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	ts.Require().NoError(decoder.Decode(&document))
+
+	return document
+}
+
+func (ts *jsonSchemaTestSuite) TestShippedConfigsMatchSchema() {
+	for _, path := range shippedConfigs(ts.T()) {
+		content, err := os.ReadFile(path)
+		ts.NoError(err)
+
+		ts.NoError(ts.schema.Validate(ts.document(string(content))), path)
+	}
+}
+
+func (ts *jsonSchemaTestSuite) TestSchemaRejectsWhatValidateRejects() {
+	invalid := []string{
+		// unknown key
+		"cloud: aws\ntemplate:\n  instructions: [createStorage]\nstorage:\n  name: events\n  nam: events\n",
+		// enum of the selected cloud
+		"cloud: gcp\ntemplate:\n  instructions: [createStorage]\nstream:\n  destination: s3\n",
+		// required by instruction
+		"cloud: aws\ntemplate:\n  instructions: [createStorage]\n",
+		// required in the same list item
+		"cloud: azure\nresource_group: rg\ntemplate:\n  instructions: [configureIAM]\niam:\n  roles:\n    - name: sender\n      type: roleassignment\n      role: reader\n      scope: storage\n",
+		// pipeline without name
+		"pipelines:\n  - cloud: aws\n    template:\n      instructions: [createVpc]\n",
+		// unknown instruction
+		"cloud: aws\ntemplate:\n  instructions: [createMonitoring]\n",
+		// unknown secret source
+		"cloud: aws\ntemplate:\n  instructions: [createVpc]\ndwh:\n  redshift:\n    master_pass: secret://vault/redshift_pass\n",
+		// existing and imported at the same time
+		"cloud: aws\ntemplate:\n  instructions: [createStorage]\nstorage:\n  name: events\n  existing_id: events\n  import_id: events\n",
+		// stack reference without output
+		"cloud: aws\ntemplate:\n  instructions: [createVpc]\nstream:\n  role_arn: stackref://acme/platform/prod\n",
+		// adopted cluster without its password
+		"cloud: aws\ntemplate:\n  instructions: [createDWH]\niam:\n  roles:\n    - name: redshift_service_role\n      assume_policy: \"{}\"\ndwh:\n  redshift:\n    identifier: cluster\n    db_name: db\n    master_user: master\n    node_type: dc2.large\n    import_id: legacy-cluster\n",
+		// cluster without the role that Redshift assumes
+		"cloud: aws\ntemplate:\n  instructions: [createDWH]\niam:\n  roles:\n    - name: firehose_role\n      assume_policy: \"{}\"\ndwh:\n  redshift:\n    identifier: cluster\n    db_name: db\n    master_user: master\n    node_type: dc2.large\n",
+		// capture without container
+		"cloud: azure\nresource_group: rg\ntemplate:\n  instructions: [createStream]\nstream:\n  name: events\n  destination: blob\n  eventhub_conf:\n    namespace:\n      name: events-ns\n      sku: Standard\n",
+	}
+
+	for _, content := range invalid {
+		ts.Error(ts.schema.Validate(ts.document(content)), content)
+	}
+}
+
+func (ts *jsonSchemaTestSuite) TestSchemaAcceptsConditionalFieldsWhenConditionDoesNotHold() {
+	valid := []string{
+		"cloud: azure\nresource_group: rg\ntemplate:\n  instructions: [configureIAM]\niam:\n  roles:\n    - name: identity\n      type: managedidentity\n",
+		"cloud: gcp\ntemplate:\n  instructions: [createVpc]\n",
+		"cloud: aws\ntemplate:\n  instructions: [createVpc]\ndwh:\n  redshift:\n    master_pass: secret://env/REDSHIFT_PASS\n",
+		"cloud: aws\ntemplate:\n  instructions: [createVpc]\nstream:\n  role_arn: stackref://acme/platform/prod#firehoseRoleArn\n",
+		"cloud: aws\ntemplate:\n  instructions: [createDWH]\ndwh:\n  redshift:\n    identifier: cluster\n    existing_id: legacy-cluster\n",
+		"cloud: aws\ntemplate:\n  instructions: [createDWH]\niam:\n  roles:\n    - name: Redshift_Service_Role\n      assume_policy: \"{}\"\ndwh:\n  redshift:\n    identifier: cluster\n    db_name: db\n    master_user: master\n    node_type: dc2.large\n",
+		"env: development\npipelines:\n  - name: studio-a\n    cloud: aws\n    template:\n      instructions: [createStorage]\n    storage:\n      name: events\n",
+	}
+
+	for _, content := range valid {
+		ts.NoError(ts.schema.Validate(ts.document(content)), content)
+	}
+}
+
+func (ts *jsonSchemaTestSuite) TestShippedSchemaIsUpToDate() {
+	content, err := json.MarshalIndent(JSONSchema(instructions()), "", "  ")
+	ts.NoError(err)
+
+	shipped, err := os.ReadFile("../../configs/config.schema.json")
+	ts.NoError(err)
+
+	ts.Equal(string(content)+"\n", string(shipped), "run make schema")
+}
+
+func TestRunJSONSchemaSuite(t *testing.T) {
+	suite.Run(t, &jsonSchemaTestSuite{})
+}
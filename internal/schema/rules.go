@@ -0,0 +1,175 @@
+package schema
+
+import (
+	"fmt"
+	"github.com/cemayan/pulumi-template/internal/lifecycle"
+	"github.com/cemayan/pulumi-template/types"
+)
+
+// Condition limits a rule to configs that have one of Values at Path.
+// If Path is in the same list as the rule path, value of the same item is used. Ex: iam.roles[].type for iam.roles[].member
+type Condition struct {
+	Path   string
+	Values []string
+}
+
+// Rule represents a constraint on a config field
+// Rules are the single source of truth of the config format, both Validate and JSONSchema are built from them.
+type Rule struct {
+	// Path is the yaml path of the field, "[]" means every item of the list. Ex: iam.roles[].type
+	Path string
+	// Clouds limits the rule to given clouds, rule is applied on every cloud if it is empty.
+	Clouds []types.CloudProvider
+	// Instructions limits the rule to templates that have one of given instructions.
+	Instructions []string
+	// When limits the rule to configs that satisfy the condition.
+	When *Condition
+	// Unless gives the fields that turn the rule off if one of them is given, such as existing_id of a resource that is read instead of created.
+	Unless []string
+	// With limits the rule to configs that give one of given fields, such as import_id of a resource that is adopted.
+	With []string
+	// Conflicts gives the field that can not be given together with the field.
+	Conflicts string
+	// Required means the field can not be empty.
+	Required bool
+	// Enum gives the valid values of the field.
+	Enum []string
+	// JSON means the field must be a valid JSON document.
+	JSON bool
+	// Secret means the field is a secret, it can be given as a secret reference such as secret://config/redshift_pass.
+	Secret bool
+	// StackRef means the field can be given as a stack reference such as stackref://acme/platform/prod#userPoolArn.
+	StackRef bool
+	// Duration means the field is a duration such as 30m or 1h30m.
+	Duration bool
+	// Prefix means one of the items of the list in Path must start with it, case is ignored. Ex: lambda_firehose for iam.roles[].name
+	Prefix string
+}
+
+var (
+	aws   = []types.CloudProvider{types.Aws}
+	gcp   = []types.CloudProvider{types.Gcp}
+	azure = []types.CloudProvider{types.Azure}
+)
+
+// Rules gives the constraints of the config format.
+var Rules = []Rule{
+	{Path: "cloud", Required: true, Enum: []string{"aws", "gcp", "azure"}},
+	{Path: "template.instructions", Required: true},
+	{Path: "template.on_error", Enum: []string{types.FailFast, types.ContinueOnError}},
+
+	// aws
+	{Path: "iam.roles[].name", Clouds: aws, Instructions: []string{"configureIAM"}, Required: true},
+	{Path: "iam.roles[].assume_policy", Clouds: aws, Instructions: []string{"configureIAM"}, Required: true, JSON: true},
+	{Path: "iam.roles[].inline_policy", Clouds: aws, JSON: true},
+	{Path: "iam.roles[].name", Clouds: aws, Instructions: []string{"createFunction"}, Prefix: "lambda_firehose"},
+	{Path: "iam.roles[].name", Clouds: aws, Instructions: []string{"createDWH"}, Unless: []string{"dwh.redshift.existing_id"}, Prefix: "redshift_service"},
+	{Path: "iam.roles[].name", Clouds: aws, Instructions: []string{"createStream"}, Unless: []string{"stream.role_arn"}, Prefix: "kinesis_firehose"},
+	{Path: "iam.roles[].name", Clouds: aws, Instructions: []string{"createApiGateway"}, Unless: []string{"api_gateway.role_arn"}, Prefix: "api_gateway"},
+	{Path: "storage.name", Clouds: aws, Instructions: []string{"createStorage"}, Required: true},
+	{Path: "storage.import_id", Conflicts: "storage.existing_id"},
+	{Path: "dwh.redshift.import_id", Clouds: aws, Conflicts: "dwh.redshift.existing_id"},
+	{Path: "dwh.redshift.identifier", Clouds: aws, Instructions: []string{"createDWH"}, Required: true},
+	{Path: "dwh.redshift.db_name", Clouds: aws, Instructions: []string{"createDWH"}, Unless: []string{"dwh.redshift.existing_id"}, Required: true},
+	{Path: "dwh.redshift.master_user", Clouds: aws, Instructions: []string{"createDWH"}, Unless: []string{"dwh.redshift.existing_id"}, Required: true},
+	{Path: "dwh.redshift.master_pass", Clouds: aws, Secret: true},
+	{Path: "dwh.redshift.master_pass", Clouds: aws, Instructions: []string{"createDWH"}, With: []string{"dwh.redshift.import_id"}, Required: true},
+	{Path: "dwh.redshift.node_type", Clouds: aws, Instructions: []string{"createDWH"}, Unless: []string{"dwh.redshift.existing_id"}, Required: true},
+	{Path: "dwh.redshift.cluster_type", Clouds: aws, Enum: []string{"single-node", "multi-node"}},
+	{Path: "stream.name", Clouds: aws, Instructions: []string{"createStream"}, Required: true},
+	{Path: "stream.destination", Clouds: aws, Instructions: []string{"createStream"}, Required: true, Enum: []string{"s3", "redshift"}},
+	{Path: "stream.redshift_conf.username", Clouds: aws, Instructions: []string{"createStream"}, When: &Condition{Path: "stream.destination", Values: []string{"redshift"}}, With: []string{"stream.redshift_conf.password"}, Required: true},
+	{Path: "stream.redshift_conf.password", Clouds: aws, Secret: true},
+	{Path: "stream.role_arn", Clouds: aws, StackRef: true},
+	{Path: "stream.s3Config.bucket_arn", Clouds: aws, StackRef: true},
+	{Path: "stream.redshift_conf.data_table_name", Clouds: aws, Instructions: []string{"createStream"}, When: &Condition{Path: "stream.destination", Values: []string{"redshift"}}, Required: true},
+	{Path: "function.name", Clouds: aws, Instructions: []string{"createFunction"}, Required: true},
+	{Path: "function.auth", Clouds: aws, Enum: []string{"NONE", "AWS_IAM"}},
+	{Path: "function.build.handler", Clouds: aws, Instructions: []string{"createFunction"}, Required: true},
+	{Path: "function.build.runtime", Clouds: aws, Instructions: []string{"createFunction"}, Required: true},
+	{Path: "function.build.source.zip", Clouds: aws, Instructions: []string{"createFunction"}, Required: true},
+	{Path: "function.build.source.output_path", Clouds: aws, Instructions: []string{"createFunction"}, Required: true},
+	{Path: "authorizer.type", Clouds: aws, Enum: []string{"TOKEN", "REQUEST", "COGNITO_USER_POOLS"}},
+	{Path: "authorizer.user_pool.name", Clouds: aws, Instructions: []string{"createIdentityManagement"}, Required: true},
+	{Path: "authorizer.user_pool.user_client.name", Clouds: aws, Instructions: []string{"createIdentityManagement"}, Required: true},
+	{Path: "authorizer.user_pool.user_domain.name", Clouds: aws, Instructions: []string{"createIdentityManagement"},
+		Unless: []string{"authorizer.user_pool.existing_id", "authorizer.user_pool.import_id"}, Required: true},
+	{Path: "authorizer.user_pool.import_id", Clouds: aws, Conflicts: "authorizer.user_pool.existing_id"},
+	{Path: "api_gateway.name", Clouds: aws, Instructions: []string{"createApiGateway"}, Required: true},
+	{Path: "api_gateway.stage", Clouds: aws, Instructions: []string{"createApiGateway"}, Required: true},
+	{Path: "api_gateway.role_arn", Clouds: aws, StackRef: true},
+	{Path: "authorizer.user_pool.arn", Clouds: aws, StackRef: true},
+	{Path: "api_gateway.routes[].name", Clouds: aws, Instructions: []string{"createApiGateway"}, Required: true},
+	{Path: "api_gateway.routes[].integrations[].name", Clouds: aws, Instructions: []string{"createApiGateway"}, Required: true},
+	{Path: "api_gateway.routes[].integrations[].method.auth", Clouds: aws, Enum: []string{"NONE", "AWS_IAM", "CUSTOM", "COGNITO_USER_POOLS"}},
+
+	// gcp
+	{Path: "iam.service_acc.account_id", Clouds: gcp, Instructions: []string{"createFunction", "configureIAM"}, Required: true},
+	{Path: "iam.service_acc.project", Clouds: gcp, Instructions: []string{"createFunction", "configureIAM"}, Required: true},
+	{Path: "iam.roles[].name", Clouds: gcp, Instructions: []string{"configureIAM"}, Required: true},
+	{Path: "iam.roles[].role", Clouds: gcp, Instructions: []string{"configureIAM"}, Required: true},
+	{Path: "iam.roles[].type", Clouds: gcp, Instructions: []string{"configureIAM"}, Required: true,
+		Enum: []string{"cloudfuncv2member", "pubsubmember", "cloudrunbinding", "bucketmember", "projectmember"}},
+	{Path: "storage.name", Clouds: gcp, Instructions: []string{"createStorage"}, Required: true},
+	{Path: "storage.location", Clouds: gcp, Instructions: []string{"createStorage"}, Unless: []string{"storage.existing_id"}, Required: true},
+	{Path: "dwh.bq.dataset", Clouds: gcp, Instructions: []string{"createDWH"}, Required: true},
+	{Path: "dwh.bq.table_id", Clouds: gcp, Instructions: []string{"createDWH"}, Required: true},
+	{Path: "dwh.bq.schema", Clouds: gcp, Instructions: []string{"createDWH"}, Required: true, JSON: true},
+	{Path: "stream.destination", Clouds: gcp, Instructions: []string{"createStream"}, Required: true, Enum: []string{"cloudstorage", "bigquery"}},
+	{Path: "stream.pubsub_conf.topic.name", Clouds: gcp, Instructions: []string{"createStream"}, Required: true},
+	{Path: "stream.pubsub_conf.topic.import_id", Clouds: gcp, Conflicts: "stream.pubsub_conf.topic.existing_id"},
+	{Path: "stream.pubsub_conf.subscription.name", Clouds: gcp, Instructions: []string{"createStream"}, Required: true},
+	{Path: "function.name", Clouds: gcp, Instructions: []string{"createFunction"}, Required: true},
+	{Path: "function.region", Clouds: gcp, Instructions: []string{"createFunction"}, Required: true},
+	{Path: "function.build.runtime", Clouds: gcp, Instructions: []string{"createFunction"}, Required: true},
+	{Path: "function.build.entry_point", Clouds: gcp, Instructions: []string{"createFunction"}, Required: true},
+	{Path: "function.build.source.storage.bucket.name", Clouds: gcp, Instructions: []string{"createFunction"}, Required: true},
+	{Path: "function.build.source.storage.bucket.object.name", Clouds: gcp, Instructions: []string{"createFunction"}, Required: true},
+	{Path: "function.build.source.storage.bucket.object.path", Clouds: gcp, Instructions: []string{"createFunction"}, Required: true},
+	{Path: "api_gateway.name", Clouds: gcp, Instructions: []string{"createApiGateway"}, Required: true},
+	{Path: "api_gateway.region", Clouds: gcp, Instructions: []string{"createApiGateway"}, Required: true},
+	{Path: "api_gateway.open_api_spec", Clouds: gcp, Instructions: []string{"createApiGateway"}, Required: true},
+
+	// azure
+	{Path: "resource_group", Clouds: azure, Required: true},
+	{Path: "iam.roles[].name", Clouds: azure, Instructions: []string{"configureIAM"}, Required: true},
+	{Path: "iam.roles[].type", Clouds: azure, Instructions: []string{"configureIAM"}, Required: true, Enum: []string{"managedidentity", "roleassignment"}},
+	{Path: "iam.roles[].role", Clouds: azure, Instructions: []string{"configureIAM"}, When: &Condition{Path: "iam.roles[].type", Values: []string{"roleassignment"}}, Required: true},
+	{Path: "iam.roles[].member", Clouds: azure, Instructions: []string{"configureIAM"}, When: &Condition{Path: "iam.roles[].type", Values: []string{"roleassignment"}}, Required: true},
+	{Path: "iam.roles[].scope", Clouds: azure, Instructions: []string{"configureIAM"}, When: &Condition{Path: "iam.roles[].type", Values: []string{"roleassignment"}}, Required: true,
+		Enum: []string{"storage", "stream", "resource_group"}},
+	{Path: "storage.name", Clouds: azure, Instructions: []string{"createStorage"}, Required: true},
+	{Path: "storage.account_tier", Clouds: azure, Instructions: []string{"createStorage"}, Unless: []string{"storage.existing_id"}, Required: true, Enum: []string{"Standard", "Premium"}},
+	{Path: "storage.replication", Clouds: azure, Instructions: []string{"createStorage"}, Unless: []string{"storage.existing_id"}, Required: true,
+		Enum: []string{"LRS", "GRS", "RAGRS", "ZRS", "GZRS", "RAGZRS"}},
+	{Path: "dwh.adx.cluster", Clouds: azure, Instructions: []string{"createDWH"}, Required: true},
+	{Path: "dwh.adx.sku", Clouds: azure, Instructions: []string{"createDWH"}, Required: true},
+	{Path: "dwh.adx.database", Clouds: azure, Instructions: []string{"createDWH"}, Required: true},
+	{Path: "dwh.adx.table", Clouds: azure, Instructions: []string{"createDWH"}, Required: true},
+	{Path: "dwh.adx.schema", Clouds: azure, Instructions: []string{"createDWH"}, Required: true, JSON: true},
+	{Path: "stream.name", Clouds: azure, Instructions: []string{"createStream"}, Required: true},
+	{Path: "stream.destination", Clouds: azure, Enum: []string{"blob", "adx"}},
+	{Path: "storage.bucket.name", Clouds: azure, Instructions: []string{"createStream"}, When: &Condition{Path: "stream.destination", Values: []string{"blob"}}, Required: true},
+	{Path: "stream.eventhub_conf.namespace.name", Clouds: azure, Instructions: []string{"createStream"}, Required: true},
+	{Path: "stream.eventhub_conf.namespace.sku", Clouds: azure, Instructions: []string{"createStream"}, Required: true, Enum: []string{"Basic", "Standard", "Premium"}},
+	{Path: "function.name", Clouds: azure, Instructions: []string{"createFunction"}, Required: true},
+	{Path: "function.build.runtime", Clouds: azure, Instructions: []string{"createFunction"}, Required: true, Enum: []string{"node", "python", "dotnet", "java"}},
+	{Path: "function.auth", Clouds: azure, Enum: []string{"anonymous", "function", "admin"}},
+	{Path: "function.build.runtime_version", Clouds: azure, Instructions: []string{"createFunction"}, Required: true},
+	{Path: "function.build.source.zip", Clouds: azure, Instructions: []string{"createFunction"}, Required: true},
+	{Path: "function.build.source.output_path", Clouds: azure, Instructions: []string{"createFunction"}, Required: true},
+	{Path: "api_gateway.name", Clouds: azure, Instructions: []string{"createApiGateway"}, Required: true},
+	{Path: "api_gateway.sku", Clouds: azure, Instructions: []string{"createApiGateway"}, Required: true},
+	{Path: "api_gateway.publisher_name", Clouds: azure, Instructions: []string{"createApiGateway"}, Required: true},
+	{Path: "api_gateway.publisher_email", Clouds: azure, Instructions: []string{"createApiGateway"}, Required: true},
+	{Path: "api_gateway.routes[].name", Clouds: azure, Instructions: []string{"createApiGateway"}, Required: true},
+}
+
+func init() {
+	// Timeouts in the options block of every component are durations
+	for _, component := range lifecycle.Components {
+		for _, operation := range []string{"create", "update", "delete"} {
+			Rules = append(Rules, Rule{Path: fmt.Sprintf("%v.options.timeouts.%v", component, operation), Duration: true})
+		}
+	}
+}
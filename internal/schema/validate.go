@@ -0,0 +1,408 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cemayan/pulumi-template/internal/policy"
+	"github.com/cemayan/pulumi-template/internal/secret"
+	"github.com/cemayan/pulumi-template/internal/stackref"
+	"github.com/cemayan/pulumi-template/internal/tags"
+	"github.com/cemayan/pulumi-template/types"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Problem represents an invalid value in config with its yaml path
+type Problem struct {
+	Path    string
+	Message string
+}
+
+func (p Problem) Error() string {
+	return fmt.Sprintf("%v: %v", p.Path, p.Message)
+}
+
+// Problems represents every problem that is found in config
+type Problems []Problem
+
+func (p Problems) Error() string {
+	lines := []string{}
+	for _, problem := range p {
+		lines = append(lines, problem.Error())
+	}
+	return fmt.Sprintf("config is not valid:\n%v", strings.Join(lines, "\n"))
+}
+
+// field represents a value in config with its concrete path such as iam.roles[1].type
+type field struct {
+	path  string
+	value reflect.Value
+}
+
+// Validate checks config against Rules according to the selected cloud and instructions.
+// unknownKeys gives the keys in yaml that do not match any field of types.Config, they are reported too.
+// If config has pipelines, each entry is checked with its own cloud and instructions, tags of the top level are checked with the cloud of every entry.
+// Policies are checked at the top level only.
+// Every problem is returned together as Problems, nil is returned if config is valid.
+func Validate(config types.Config, unknownKeys []string) error {
+
+	problems := Problems{}
+
+	for _, key := range unknownKeys {
+		problems = append(problems, Problem{Path: key, Message: "unknown key"})
+	}
+
+	if len(config.Pipelines) == 0 {
+		problems = append(problems, validate(config, "")...)
+	}
+
+	for name, message := range policy.Validate(config) {
+		problems = append(problems, Problem{Path: "policies." + name, Message: message})
+	}
+
+	seen := map[Problem]bool{}
+
+	for i, pipeline := range config.Pipelines {
+		prefix := fmt.Sprintf("pipelines[%v].", i)
+
+		for _, problem := range validateTags(types.Config{Cloud: pipeline.Cloud, Tags: config.Tags}, "") {
+			if !seen[problem] {
+				seen[problem] = true
+				problems = append(problems, problem)
+			}
+		}
+
+		if pipeline.Name == "" {
+			problems = append(problems, Problem{Path: prefix + "name", Message: "is required"})
+		}
+
+		if len(pipeline.Policies) > 0 {
+			problems = append(problems, Problem{Path: prefix + "policies", Message: "is only read at the top level of the config"})
+		}
+
+		problems = append(problems, validate(pipeline, prefix)...)
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Path < problems[j].Path
+	})
+
+	return problems
+}
+
+// validate checks config against every rule that applies to it, prefix is added to the paths of problems
+func validate(config types.Config, prefix string) []Problem {
+
+	problems := []Problem{}
+	root := reflect.ValueOf(config)
+
+	for _, rule := range Rules {
+		if !applies(rule, config) || given(root, rule.Unless...) || (len(rule.With) > 0 && !given(root, rule.With...)) {
+			continue
+		}
+
+		if rule.Prefix != "" {
+			if list, name := splitList(rule.Path); !hasPrefix(lookup(root, rule.Path), rule.Prefix) {
+				problems = append(problems, Problem{Path: prefix + list, Message: fmt.Sprintf("must have an item whose %v starts with %v", name, rule.Prefix) + rule.context()})
+			}
+			continue
+		}
+
+		for _, f := range lookup(root, rule.Path) {
+			if rule.When != nil && !rule.When.holds(root, f.path) {
+				continue
+			}
+
+			if message := rule.check(f.value); message != "" {
+				problems = append(problems, Problem{Path: prefix + f.path, Message: message + rule.context()})
+			}
+
+			if rule.Conflicts != "" && !isEmpty(f.value) && given(root, rule.Conflicts) {
+				problems = append(problems, Problem{Path: prefix + f.path, Message: fmt.Sprintf("can not be given with %v", rule.Conflicts)})
+			}
+		}
+	}
+
+	return append(problems, validateTags(config, prefix)...)
+}
+
+// splitList splits given path at its last list, ex: iam.roles[].name is iam.roles and name
+func splitList(path string) (string, string) {
+	index := strings.LastIndex(path, "[].")
+	return path[:index], path[index+len("[]."):]
+}
+
+// hasPrefix returns true if one of the fields starts with prefix, case is ignored
+func hasPrefix(fields []field, prefix string) bool {
+	for _, f := range fields {
+		if f.value.IsValid() && f.value.Kind() == reflect.String && strings.HasPrefix(strings.ToLower(f.value.String()), strings.ToLower(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// given returns true if one of the fields at given paths is not empty
+func given(root reflect.Value, paths ...string) bool {
+	for _, path := range paths {
+		for _, f := range lookup(root, path) {
+			if !isEmpty(f.value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validateTags checks the tags of config against the rules of its cloud, prefix is added to the paths of problems
+func validateTags(config types.Config, prefix string) []Problem {
+
+	problems := []Problem{}
+
+	for key, message := range tags.Validate(config) {
+		problems = append(problems, Problem{Path: fmt.Sprintf("%vtags.%v", prefix, key), Message: message})
+	}
+
+	return problems
+}
+
+// applies returns true if rule is given for the cloud and instructions of config
+func applies(rule Rule, config types.Config) bool {
+
+	if len(rule.Clouds) > 0 && !slices.Contains(rule.Clouds, types.CloudMap[config.Cloud]) {
+		return false
+	}
+
+	if len(rule.Instructions) == 0 {
+		return true
+	}
+
+	for _, instruction := range rule.Instructions {
+		if slices.Contains(config.Template.Instructions, instruction) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// check returns the problem of given value, it returns empty string if the value is valid
+func (r Rule) check(value reflect.Value) string {
+
+	if isEmpty(value) {
+		if r.Required {
+			return "is required"
+		}
+		return ""
+	}
+
+	if value.Kind() != reflect.String {
+		return ""
+	}
+
+	if len(r.Enum) > 0 && !slices.Contains(r.Enum, value.String()) {
+		return fmt.Sprintf("must be one of %v, got %q", strings.Join(r.Enum, ", "), value.String())
+	}
+
+	if r.JSON {
+		var document interface{}
+		if err := json.Unmarshal([]byte(value.String()), &document); err != nil {
+			return fmt.Sprintf("is not valid JSON: %v", err)
+		}
+	}
+
+	if r.Secret {
+		if _, _, err := secret.Parse(value.String()); err != nil {
+			return err.Error()
+		}
+	}
+
+	if r.Duration {
+		if _, err := time.ParseDuration(value.String()); err != nil {
+			return fmt.Sprintf("must be a duration such as 30m, got %q", value.String())
+		}
+	}
+
+	if r.StackRef {
+		if _, _, err := stackref.Parse(value.String()); err != nil {
+			return err.Error()
+		}
+	}
+
+	return ""
+}
+
+// context explains when the rule is applied, it is added to the messages
+func (r Rule) context() string {
+
+	parts := []string{}
+
+	if len(r.Instructions) > 0 {
+		parts = append(parts, fmt.Sprintf("by %v", strings.Join(r.Instructions, ", ")))
+	}
+
+	if r.When != nil {
+		parts = append(parts, fmt.Sprintf("when %v is %v", r.When.Path, strings.Join(r.When.Values, ", ")))
+	}
+
+	if len(r.With) > 0 {
+		parts = append(parts, fmt.Sprintf("with %v", strings.Join(r.With, ", ")))
+	}
+
+	if len(r.Clouds) > 0 {
+		clouds := []string{}
+		for _, cloud := range r.Clouds {
+			clouds = append(clouds, cloud.String())
+		}
+		parts = append(parts, fmt.Sprintf("on %v", strings.Join(clouds, ", ")))
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+
+	return " " + strings.Join(parts, " ")
+}
+
+// holds returns true if the value at the condition path is one of the condition values.
+// Indexes of the lists that are shared with given concrete path are used. Ex: iam.roles[].type is iam.roles[1].type for iam.roles[1].member
+func (c Condition) holds(root reflect.Value, concretePath string) bool {
+
+	segments := strings.Split(c.Path, ".")
+	concreteSegments := strings.Split(concretePath, ".")
+
+	for i, segment := range segments {
+		if i >= len(concreteSegments) {
+			break
+		}
+
+		if segment == concreteSegments[i] {
+			continue
+		}
+
+		name, ok := strings.CutSuffix(segment, "[]")
+		if !ok || !strings.HasPrefix(concreteSegments[i], name+"[") {
+			break
+		}
+
+		segments[i] = concreteSegments[i]
+	}
+
+	fields := lookup(root, strings.Join(segments, "."))
+	if len(fields) == 0 {
+		return false
+	}
+
+	for _, f := range fields {
+		if isEmpty(f.value) || !slices.Contains(c.Values, fmt.Sprint(f.value.Interface())) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// lookup returns the fields at given path, every item is returned for the lists in path.
+// A segment can be a concrete item such as roles[1]. Fields under a nil pointer are returned as invalid values.
+// It panics if path does not match types.Config since rules are defined in code.
+func lookup(root reflect.Value, path string) []field {
+
+	fields := []field{{value: root}}
+
+	for _, segment := range strings.Split(path, ".") {
+		name, index, isList := parseSegment(segment)
+
+		next := []field{}
+		for _, f := range fields {
+			child := f.path + name
+			if f.path != "" {
+				child = f.path + "." + name
+			}
+
+			value := fieldByTag(f.value, name, path)
+
+			if !isList {
+				next = append(next, field{path: child, value: value})
+				continue
+			}
+
+			if !value.IsValid() {
+				continue
+			}
+
+			for i := 0; i < value.Len(); i++ {
+				if index >= 0 && i != index {
+					continue
+				}
+				next = append(next, field{path: fmt.Sprintf("%v[%v]", child, i), value: value.Index(i)})
+			}
+		}
+
+		fields = next
+	}
+
+	return fields
+}
+
+// parseSegment returns the name and the index of given path segment, index is -1 for every item of the list
+func parseSegment(segment string) (string, int, bool) {
+
+	start := strings.Index(segment, "[")
+	if start < 0 {
+		return segment, -1, false
+	}
+
+	index := -1
+	if content := segment[start+1 : len(segment)-1]; content != "" {
+		fmt.Sscan(content, &index)
+	}
+
+	return segment[:start], index, true
+}
+
+// fieldByTag returns the struct field that has given mapstructure tag, invalid value is returned under a nil pointer
+func fieldByTag(value reflect.Value, tag string, path string) reflect.Value {
+
+	if !value.IsValid() {
+		return value
+	}
+
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return reflect.Value{}
+		}
+		value = value.Elem()
+	}
+
+	for i := 0; i < value.NumField(); i++ {
+		if strings.Split(value.Type().Field(i).Tag.Get("mapstructure"), ",")[0] == tag {
+			return value.Field(i)
+		}
+	}
+
+	panic(fmt.Sprintf("path %v does not match config, %v has no field %v", path, value.Type(), tag))
+}
+
+// isEmpty returns true if the value is not given in yaml
+func isEmpty(value reflect.Value) bool {
+
+	if !value.IsValid() {
+		return true
+	}
+
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	case reflect.Pointer:
+		return value.IsNil()
+	}
+
+	return value.IsZero()
+}
@@ -0,0 +1,301 @@
+package schema
+
+import (
+	"github.com/cemayan/pulumi-template/internal/interpolate"
+	"github.com/cemayan/pulumi-template/internal/loader"
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type validateTestSuite struct {
+	suite.Suite
+}
+
+// decode decodes given yaml like cmd/infra does and returns the unknown keys
+func (ts *validateTestSuite) decode(content string) (types.Config, []string, error) {
+	path := filepath.Join(ts.T().TempDir(), "config.yaml")
+	ts.Require().NoError(os.WriteFile(path, []byte(content), 0644))
+
+	return loader.Load(path, "")
+}
+
+// shippedConfigs returns the paths of the configs in configs folder, env overlays are not returned
+func shippedConfigs(t *testing.T) []string {
+	paths := []string{}
+	for _, pattern := range []string{"../../configs/*/*/*.yaml", "../../configs/*/*/*/*.yaml", "../../configs/*/*/*/*/*.yaml"} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, match := range matches {
+			if strings.Count(filepath.Base(match), ".") == 1 {
+				paths = append(paths, match)
+			}
+		}
+	}
+
+	if len(paths) == 0 {
+		t.Fatal("no config is found")
+	}
+
+	return paths
+}
+
+func (ts *validateTestSuite) TestShippedConfigsAreValid() {
+	for _, path := range shippedConfigs(ts.T()) {
+		// Config is checked without overlay and with each of its env overlays
+		envs := []string{""}
+
+		overlays, err := filepath.Glob(strings.TrimSuffix(path, ".yaml") + ".*.yaml")
+		ts.NoError(err)
+
+		for _, overlay := range overlays {
+			envs = append(envs, strings.TrimSuffix(strings.TrimPrefix(overlay, strings.TrimSuffix(path, "yaml")), ".yaml"))
+		}
+
+		for _, env := range envs {
+			config, unknownKeys, err := loader.Load(path, env)
+			ts.NoError(err)
+
+			config, err = interpolate.Config(config, map[string]string{"env": config.Env, "stack": "test"})
+			ts.NoError(err, path, env)
+
+			ts.NoError(Validate(config, unknownKeys), path, env)
+		}
+	}
+}
+
+func (ts *validateTestSuite) TestReportsEveryProblemWithPath() {
+	config, unknownKeys, err := ts.decode(`
+cloud: aws
+template:
+  instructions: ["configureIAM", "createStream", "createIdentityManagement"]
+  on_error: ignore
+iam:
+  roles:
+    - name: "firehose_role"
+      assume_policy: "{"
+authorizer:
+  type: "JWT"
+stream:
+  name: "events"
+  destination: s4
+  s3_config:
+    buffering_size: 5
+`)
+	ts.NoError(err)
+
+	err = Validate(config, unknownKeys)
+
+	ts.EqualError(err, `config is not valid:
+authorizer.type: must be one of TOKEN, REQUEST, COGNITO_USER_POOLS, got "JWT" on aws
+authorizer.user_pool.name: is required by createIdentityManagement on aws
+authorizer.user_pool.user_client.name: is required by createIdentityManagement on aws
+authorizer.user_pool.user_domain.name: is required by createIdentityManagement on aws
+iam.roles: must have an item whose name starts with kinesis_firehose by createStream on aws
+iam.roles[0].assume_policy: is not valid JSON: unexpected end of JSON input by configureIAM on aws
+stream.destination: must be one of s3, redshift, got "s4" by createStream on aws
+stream.s3_config: unknown key
+template.on_error: must be one of fail_fast, continue, got "ignore"`)
+}
+
+func (ts *validateTestSuite) TestConditionUsesSameListItem() {
+	config := types.Config{
+		Cloud:         "azure",
+		ResourceGroup: "rg",
+		Template:      types.Template{Instructions: []string{"configureIAM"}},
+		Iam: types.Iam{Roles: []types.Roles{
+			{Name: "identity", Type: "managedidentity"},
+			{Name: "sender", Type: "roleassignment", Role: "Azure Event Hubs Data Sender", Scope: "queue"},
+		}},
+	}
+
+	err := Validate(config, nil)
+
+	ts.EqualError(err, `config is not valid:
+iam.roles[1].member: is required by configureIAM when iam.roles[].type is roleassignment on azure
+iam.roles[1].scope: must be one of storage, stream, resource_group, got "queue" by configureIAM when iam.roles[].type is roleassignment on azure`)
+}
+
+func (ts *validateTestSuite) TestBlobCaptureNeedsContainer() {
+	config := types.Config{
+		Cloud:         "azure",
+		ResourceGroup: "rg",
+		Template:      types.Template{Instructions: []string{"createStream"}},
+		Stream: types.Stream{Name: "events", Destination: "blob", EventHubConf: types.EventHubConf{
+			Namespace: types.EventHubNamespace{Name: "events-ns", Sku: "Standard"},
+		}},
+	}
+
+	ts.EqualError(Validate(config, nil), `config is not valid:
+storage.bucket.name: is required by createStream when stream.destination is blob on azure`)
+
+	config.Storage.Bucket.Name = "capture"
+	ts.NoError(Validate(config, nil))
+}
+
+func (ts *validateTestSuite) TestPipelinesAreValidatedWithTheirCloud() {
+	config := types.Config{
+		Pipelines: []types.Config{
+			{Name: "studio-a", Cloud: "gcp", Template: types.Template{Instructions: []string{"createDWH"}},
+				Dwh: types.Dwh{BigQuery: types.BigQuery{Dataset: "events", TableId: "events", Schema: `[{"name": "game_name"}]`}}},
+			{Cloud: "oracle", Template: types.Template{Instructions: []string{"createStorage"}}},
+		},
+	}
+
+	err := Validate(config, nil)
+
+	ts.EqualError(err, `config is not valid:
+pipelines[1].cloud: must be one of aws, gcp, azure, got "oracle"
+pipelines[1].name: is required`)
+}
+
+func (ts *validateTestSuite) TestSecretReferences() {
+	config := types.Config{
+		Cloud:    "aws",
+		Template: types.Template{Instructions: []string{"createDWH"}},
+		Iam:      types.Iam{Roles: []types.Roles{{Name: "redshift_service_role"}}},
+		Dwh: types.Dwh{Redshift: types.Redshift{
+			Identifier: "cluster", DbName: "db", MasterUser: "master", NodeType: "dc2.large", MasterPass: "secret://vault/redshift",
+		}},
+	}
+
+	ts.EqualError(Validate(config, nil), `config is not valid:
+dwh.redshift.master_pass: secret reference must be one of secret://config|env|file/<key>, got secret://vault on aws`)
+
+	config.Dwh.Redshift.MasterPass = "secret://config/redshift_pass"
+	ts.NoError(Validate(config, nil))
+}
+
+func (ts *validateTestSuite) TestExistingResources() {
+	config := types.Config{
+		Cloud:    "aws",
+		Template: types.Template{Instructions: []string{"createStorage", "createDWH"}},
+		Iam:      types.Iam{Roles: []types.Roles{{Name: "redshift_service_role"}}},
+		Storage:  types.Storage{Name: "events", ExistingId: "legacy-events", ImportId: "legacy-events"},
+		Dwh:      types.Dwh{Redshift: types.Redshift{Identifier: "cluster", ExistingId: "legacy-cluster"}},
+	}
+
+	// Fields that are only used to create the cluster are not required
+	ts.EqualError(Validate(config, nil), `config is not valid:
+storage.import_id: can not be given with storage.existing_id`)
+
+	config.Storage.ExistingId = ""
+	ts.NoError(Validate(config, nil))
+
+	// Password of an adopted cluster must be given, a generated one would replace it
+	config.Dwh.Redshift = types.Redshift{Identifier: "cluster", DbName: "db", MasterUser: "master", NodeType: "dc2.large", ImportId: "legacy-cluster"}
+	ts.EqualError(Validate(config, nil), `config is not valid:
+dwh.redshift.master_pass: is required by createDWH with dwh.redshift.import_id on aws`)
+
+	config.Dwh.Redshift.MasterPass = "secret://config/redshift_pass"
+	ts.NoError(Validate(config, nil))
+}
+
+func (ts *validateTestSuite) TestRolesOfInstructions() {
+	config := types.Config{
+		Cloud:      "aws",
+		Template:   types.Template{Instructions: []string{"createStream", "createFunction", "createApiGateway"}},
+		Iam:        types.Iam{Roles: []types.Roles{{Name: "Lambda_Firehose_Role"}}},
+		Stream:     types.Stream{Name: "events", Destination: "s3"},
+		Function:   types.Function{Name: "ingest", Build: types.Build{Handler: "main", Runtime: "go1.x", Source: &types.Source{Zip: "src", OutputPath: "main.zip"}}},
+		APIGateway: types.APIGateway{Name: "events", Stage: "v1"},
+	}
+
+	ts.EqualError(Validate(config, nil), `config is not valid:
+iam.roles: must have an item whose name starts with kinesis_firehose by createStream on aws
+iam.roles: must have an item whose name starts with api_gateway by createApiGateway on aws`)
+
+	// A role that is given by ARN does not have to be created
+	config.Stream.RoleArn = "stackref://acme/platform/prod#firehoseRoleArn"
+	config.APIGateway.RoleArn = "arn:aws:iam::123456789012:role/api"
+	ts.NoError(Validate(config, nil))
+}
+
+func (ts *validateTestSuite) TestStackReferences() {
+	config := types.Config{
+		Cloud:      "aws",
+		Template:   types.Template{Instructions: []string{"createVpc"}},
+		Authorizer: types.Authorizer{UserPool: types.UserPool{Arn: "stackref://platform/prod#userPoolArn"}},
+	}
+
+	ts.EqualError(Validate(config, nil), `config is not valid:
+authorizer.user_pool.arn: stack reference must be stackref://org/project/stack#output, got stackref://platform/prod#userPoolArn on aws`)
+
+	config.Authorizer.UserPool.Arn = "stackref://acme/platform/prod#userPoolArn"
+	ts.NoError(Validate(config, nil))
+}
+
+func (ts *validateTestSuite) TestLifecycleOptions() {
+	config := types.Config{
+		Cloud:    "aws",
+		Template: types.Template{Instructions: []string{"createVpc"}},
+		Dwh:      types.Dwh{Options: types.Options{Timeouts: types.Timeouts{Create: "75 minutes", Delete: "1h30m"}}},
+	}
+
+	ts.EqualError(Validate(config, nil), `config is not valid:
+dwh.options.timeouts.create: must be a duration such as 30m, got "75 minutes"`)
+
+	config.Dwh.Options.Timeouts.Create = "75m"
+	ts.NoError(Validate(config, nil))
+}
+
+func (ts *validateTestSuite) TestPolicies() {
+	config := types.Config{
+		Policies: map[string]string{"force-destroy": "strict"},
+		Pipelines: []types.Config{
+			{Name: "studio-a", Cloud: "aws", Template: types.Template{Instructions: []string{"createVpc"}}, Policies: map[string]string{"force-destroy": "advisory"}},
+		},
+	}
+
+	ts.EqualError(Validate(config, nil), `config is not valid:
+pipelines[0].policies: is only read at the top level of the config
+policies.force-destroy: must be one of advisory, mandatory, disabled, got "strict"`)
+
+	config.Policies["force-destroy"] = "mandatory"
+	config.Pipelines[0].Policies = nil
+	ts.NoError(Validate(config, nil))
+}
+
+func (ts *validateTestSuite) TestTags() {
+	config := types.Config{
+		Tags: map[string]string{"env": "prod", "Team": "data", "team": "games", "aws:owner": "data"},
+		Pipelines: []types.Config{
+			{Name: "studio-a", Cloud: "aws", Template: types.Template{Instructions: []string{"createVpc"}}},
+			{Name: "studio-b", Cloud: "gcp", Template: types.Template{Instructions: []string{"createVpc"}}, Tags: map[string]string{"1st": "yes"}},
+		},
+	}
+
+	ts.EqualError(Validate(config, nil), `config is not valid:
+pipelines[1].tags.1st: key must start with a letter on gcp
+tags.aws:owner: key can not start with "aws:" on aws
+tags.env: is added automatically, tags can not have env, stack, template, pipeline
+tags.team: key is the same label as tags.Team on gcp, labels are "team"`)
+}
+
+func (ts *validateTestSuite) TestRulePathsMatchConfig() {
+	for _, rule := range Rules {
+		ts.NotPanics(func() { lookup(reflectConfig(), rule.Path) }, rule.Path)
+		if rule.When != nil {
+			ts.NotPanics(func() { lookup(reflectConfig(), rule.When.Path) }, rule.When.Path)
+		}
+	}
+}
+
+// reflectConfig returns a config with a list item in every list, so every path can be looked up
+func reflectConfig() reflect.Value {
+	return reflect.ValueOf(types.Config{
+		Iam:        types.Iam{Roles: []types.Roles{{}}, ServiceAcc: &types.ServiceAcc{}},
+		APIGateway: types.APIGateway{Routes: []types.Routes{{Integrations: []types.Integrations{{}}}}},
+	})
+}
+
+func TestRunValidateSuite(t *testing.T) {
+	suite.Run(t, &validateTestSuite{})
+}
@@ -0,0 +1,91 @@
+package secret
+
+import (
+	"fmt"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Scheme is the prefix of secret references in config values
+const Scheme = "secret://"
+
+// Sources gives where a secret reference can be read from.
+// config reads the pulumi secret config (secret://config/redshift_pass or secret://config/aws:pass), env reads an environment variable
+// (secret://env/REDSHIFT_PASS) and file reads a local file (secret://file/secrets/redshift_pass), trailing newlines of the file are removed.
+var Sources = []string{"config", "env", "file"}
+
+// Reference represents a parsed secret reference
+type Reference struct {
+	Source string
+	Key    string
+}
+
+// Parse parses given value as a secret reference, ok is false if the value does not start with Scheme
+func Parse(value string) (reference Reference, ok bool, err error) {
+
+	rest, ok := strings.CutPrefix(value, Scheme)
+	if !ok {
+		return reference, false, nil
+	}
+
+	source, key, _ := strings.Cut(rest, "/")
+
+	if !slices.Contains(Sources, source) {
+		return reference, true, fmt.Errorf("secret reference must be one of %v%v/<key>, got %v%v", Scheme, strings.Join(Sources, "|"), Scheme, source)
+	}
+
+	if key == "" {
+		return reference, true, fmt.Errorf("secret reference %v%v has no key", Scheme, source)
+	}
+
+	return Reference{Source: source, Key: key}, true, nil
+}
+
+// Resolve returns the secret that value refers to as a pulumi secret, so it is encrypted in state and masked in logs.
+// A value that is not a reference is returned as a secret too.
+func Resolve(ctx *pulumi.Context, value string) (pulumi.StringOutput, error) {
+
+	reference, ok, err := Parse(value)
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+
+	if !ok {
+		return toSecret(value), nil
+	}
+
+	switch reference.Source {
+	case "config":
+		key := reference.Key
+		// Keys without a namespace are read from the "config" namespace like config:userpass
+		if !strings.Contains(key, ":") {
+			key = "config:" + key
+		}
+
+		output, err := config.TrySecret(ctx, key)
+		if err != nil {
+			return pulumi.StringOutput{}, fmt.Errorf("secret config %v: %w", key, err)
+		}
+		return output, nil
+	case "env":
+		secret, ok := os.LookupEnv(reference.Key)
+		if !ok {
+			return pulumi.StringOutput{}, fmt.Errorf("secret environment variable %v is not set", reference.Key)
+		}
+		return toSecret(secret), nil
+	default:
+		content, err := os.ReadFile(reference.Key)
+		if err != nil {
+			return pulumi.StringOutput{}, fmt.Errorf("secret file: %w", err)
+		}
+		return toSecret(strings.TrimRight(string(content), "\r\n")), nil
+	}
+}
+
+// toSecret returns value as a pulumi secret
+func toSecret(value string) pulumi.StringOutput {
+	return pulumi.ToSecret(pulumi.String(value)).(pulumi.StringOutput)
+}
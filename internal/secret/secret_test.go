@@ -0,0 +1,98 @@
+package secret
+
+import (
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/stretchr/testify/suite"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+type secretTestSuite struct {
+	suite.Suite
+}
+
+type mocks int
+
+func (m mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	return args.Args, nil
+}
+
+func (m mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	return args.Name + "_id", args.Inputs, nil
+}
+
+// withSecretConfig gives config:redshift_pass as a secret config
+func withSecretConfig(info *pulumi.RunInfo) {
+	info.Config = map[string]string{"config:redshift_pass": "from-config"}
+	info.ConfigSecretKeys = []string{"config:redshift_pass"}
+}
+
+// assertSecret checks that output is a secret with given value
+func (ts *secretTestSuite) assertSecret(expected string, output pulumi.StringOutput) {
+	ts.True(pulumi.IsSecret(output))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	output.ApplyT(func(value string) error {
+		ts.Equal(expected, value)
+		wg.Done()
+		return nil
+	})
+
+	wg.Wait()
+}
+
+func (ts *secretTestSuite) TestParse() {
+	reference, ok, err := Parse("secret://env/REDSHIFT_PASS")
+	ts.NoError(err)
+	ts.True(ok)
+	ts.Equal(Reference{Source: "env", Key: "REDSHIFT_PASS"}, reference)
+
+	_, ok, err = Parse("Verysecretpass1!!")
+	ts.NoError(err)
+	ts.False(ok)
+
+	_, _, err = Parse("secret://vault/redshift")
+	ts.EqualError(err, "secret reference must be one of secret://config|env|file/<key>, got secret://vault")
+
+	_, _, err = Parse("secret://env/")
+	ts.EqualError(err, "secret reference secret://env has no key")
+}
+
+func (ts *secretTestSuite) TestResolve() {
+	path := filepath.Join(ts.T().TempDir(), "redshift_pass")
+	ts.Require().NoError(os.WriteFile(path, []byte("from-file\n"), 0600))
+	ts.T().Setenv("PTEMPLATE_REDSHIFT_PASS", "from-env")
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+
+		for value, expected := range map[string]string{
+			"secret://config/redshift_pass":        "from-config",
+			"secret://config/config:redshift_pass": "from-config",
+			"secret://env/PTEMPLATE_REDSHIFT_PASS": "from-env",
+			"secret://file/" + path:                "from-file",
+			"plain":                                "plain",
+		} {
+			output, err := Resolve(ctx, value)
+			ts.NoError(err, value)
+			ts.assertSecret(expected, output)
+		}
+
+		_, err := Resolve(ctx, "secret://env/PTEMPLATE_MISSING")
+		ts.EqualError(err, "secret environment variable PTEMPLATE_MISSING is not set")
+
+		_, err = Resolve(ctx, "secret://config/missing")
+		ts.ErrorContains(err, "secret config config:missing: ")
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", mocks(0)), withSecretConfig)
+	ts.NoError(err)
+}
+
+func TestRunSecretSuite(t *testing.T) {
+	suite.Run(t, &secretTestSuite{})
+}
@@ -0,0 +1,115 @@
+package stackref
+
+import (
+	"fmt"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"strings"
+)
+
+// Scheme is the prefix of stack references in config values
+const Scheme = "stackref://"
+
+// Reference represents a parsed stack reference such as stackref://acme/platform/prod#userPoolArn.
+// Output can be a path in a map output, ex: stackref://acme/platform/prod#storage.uri
+type Reference struct {
+	Stack  string
+	Output string
+}
+
+// String returns the reference as it is given in config
+func (r Reference) String() string {
+	return fmt.Sprintf("%v%v#%v", Scheme, r.Stack, r.Output)
+}
+
+// Parse parses given value as a stack reference, ok is false if the value does not start with Scheme
+func Parse(value string) (reference Reference, ok bool, err error) {
+
+	rest, ok := strings.CutPrefix(value, Scheme)
+	if !ok {
+		return reference, false, nil
+	}
+
+	stack, output, _ := strings.Cut(rest, "#")
+
+	parts := strings.Split(stack, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return reference, true, fmt.Errorf("stack reference must be %vorg/project/stack#output, got %v", Scheme, value)
+	}
+
+	if output == "" {
+		return reference, true, fmt.Errorf("stack reference %v has no output", value)
+	}
+
+	return Reference{Stack: stack, Output: output}, true, nil
+}
+
+// Resolver resolves stack references of a program, a StackReference resource is registered once for each stack.
+type Resolver struct {
+	ctx    *pulumi.Context
+	name   func(stack string) string
+	stacks map[string]*pulumi.StackReference
+}
+
+// NewResolver returns a Resolver, name returns the logical name of the StackReference of given stack.
+func NewResolver(ctx *pulumi.Context, name func(stack string) string) *Resolver {
+	return &Resolver{ctx: ctx, name: name, stacks: map[string]*pulumi.StackReference{}}
+}
+
+// Resolve returns the output that value refers to, a value that is not a reference is returned as it is.
+// Secret outputs stay secret. Resolving fails during the update if the stack has no such output.
+func (r *Resolver) Resolve(value string) (pulumi.StringOutput, error) {
+
+	reference, ok, err := Parse(value)
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+
+	if !ok {
+		return pulumi.String(value).ToStringOutput(), nil
+	}
+
+	stack, ok := r.stacks[reference.Stack]
+	if !ok {
+		stack, err = pulumi.NewStackReference(r.ctx, r.name(reference.Stack), &pulumi.StackReferenceArgs{Name: pulumi.String(reference.Stack)})
+		if err != nil {
+			return pulumi.StringOutput{}, fmt.Errorf("stack reference %v: %w", reference.Stack, err)
+		}
+		r.stacks[reference.Stack] = stack
+	}
+
+	name, path, _ := strings.Cut(reference.Output, ".")
+
+	return stack.GetOutput(pulumi.String(name)).ApplyT(func(output interface{}) (string, error) {
+		value, err := lookup(output, path)
+		if err != nil {
+			return "", fmt.Errorf("%v: %w", reference, err)
+		}
+		return value, nil
+	}).(pulumi.StringOutput), nil
+}
+
+// lookup returns the string at given dotted path of output
+func lookup(output interface{}, path string) (string, error) {
+
+	if path != "" {
+		for _, key := range strings.Split(path, ".") {
+			if output == nil {
+				break
+			}
+			values, ok := output.(map[string]interface{})
+			if !ok {
+				return "", fmt.Errorf("output is not a map at %v", key)
+			}
+			output = values[key]
+		}
+	}
+
+	switch value := output.(type) {
+	case nil:
+		return "", fmt.Errorf("stack has no such output")
+	case string:
+		return value, nil
+	default:
+		return "", fmt.Errorf("output is %T, not a string", output)
+	}
+}
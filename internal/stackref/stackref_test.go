@@ -0,0 +1,122 @@
+package stackref
+
+import (
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/stretchr/testify/suite"
+	"sync"
+	"testing"
+)
+
+type stackrefTestSuite struct {
+	suite.Suite
+}
+
+type mocks struct {
+	mu     sync.Mutex
+	stacks []string
+}
+
+func (m *mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	return args.Args, nil
+}
+
+// NewResource gives the outputs of the platform stack to stack references and records their names
+func (m *mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	outputs := args.Inputs.Copy()
+
+	if args.TypeToken == "pulumi:pulumi:StackReference" {
+		m.mu.Lock()
+		m.stacks = append(m.stacks, args.Name)
+		m.mu.Unlock()
+
+		outputs["outputs"] = resource.NewObjectProperty(resource.NewPropertyMapFromMap(map[string]interface{}{
+			"userPoolArn": "arn:aws:cognito-idp:eu-central-1:1:userpool/pool",
+			"storage":     map[string]interface{}{"uri": "s3://platform-events"},
+		}))
+	}
+
+	return args.Name + "_id", outputs, nil
+}
+
+// assertValue checks that output resolves to expected
+func (ts *stackrefTestSuite) assertValue(expected string, output pulumi.StringOutput) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	output.ApplyT(func(value string) error {
+		ts.Equal(expected, value)
+		wg.Done()
+		return nil
+	})
+
+	wg.Wait()
+}
+
+func (ts *stackrefTestSuite) TestParse() {
+	reference, ok, err := Parse("stackref://acme/platform/prod#storage.uri")
+	ts.NoError(err)
+	ts.True(ok)
+	ts.Equal(Reference{Stack: "acme/platform/prod", Output: "storage.uri"}, reference)
+	ts.Equal("stackref://acme/platform/prod#storage.uri", reference.String())
+
+	_, ok, err = Parse("arn:aws:s3:::events")
+	ts.NoError(err)
+	ts.False(ok)
+
+	_, _, err = Parse("stackref://platform/prod#userPoolArn")
+	ts.EqualError(err, "stack reference must be stackref://org/project/stack#output, got stackref://platform/prod#userPoolArn")
+
+	_, _, err = Parse("stackref://acme/platform/prod")
+	ts.EqualError(err, "stack reference stackref://acme/platform/prod has no output")
+}
+
+func (ts *stackrefTestSuite) TestResolve() {
+	m := &mocks{}
+
+	err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		resolver := NewResolver(ctx, func(stack string) string { return "studio-a-" + stack })
+
+		userPoolArn, err := resolver.Resolve("stackref://acme/platform/prod#userPoolArn")
+		ts.NoError(err)
+		ts.assertValue("arn:aws:cognito-idp:eu-central-1:1:userpool/pool", userPoolArn)
+
+		// Outputs of the same stack use the same StackReference, map outputs are read by their path
+		storageUri, err := resolver.Resolve("stackref://acme/platform/prod#storage.uri")
+		ts.NoError(err)
+		ts.assertValue("s3://platform-events", storageUri)
+
+		plain, err := resolver.Resolve("arn:aws:s3:::events")
+		ts.NoError(err)
+		ts.assertValue("arn:aws:s3:::events", plain)
+
+		return nil
+	}, pulumi.WithMocks("project", "stack", m))
+	ts.NoError(err)
+
+	ts.Equal([]string{"studio-a-acme/platform/prod"}, m.stacks)
+}
+
+func (ts *stackrefTestSuite) TestLookup() {
+	output := map[string]interface{}{"storage": map[string]interface{}{"uri": "s3://platform-events"}, "count": 1.0}
+
+	value, err := lookup(output, "storage.uri")
+	ts.NoError(err)
+	ts.Equal("s3://platform-events", value)
+
+	_, err = lookup(output, "dwh.table")
+	ts.EqualError(err, "stack has no such output")
+
+	_, err = lookup(output, "storage.uri.scheme")
+	ts.EqualError(err, "output is not a map at scheme")
+
+	_, err = lookup(nil, "")
+	ts.EqualError(err, "stack has no such output")
+
+	_, err = lookup(output["count"], "")
+	ts.EqualError(err, "output is float64, not a string")
+}
+
+func TestRunStackRefSuite(t *testing.T) {
+	suite.Run(t, &stackrefTestSuite{})
+}
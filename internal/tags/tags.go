@@ -0,0 +1,210 @@
+package tags
+
+import (
+	"fmt"
+	"github.com/cemayan/pulumi-template/types"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Keys of the tags that are added to every resource automatically
+const (
+	EnvKey      = "env"
+	StackKey    = "stack"
+	TemplateKey = "template"
+	PipelineKey = "pipeline"
+)
+
+// Reserved gives the keys of automatic tags, they can not be given in tags
+var Reserved = []string{EnvKey, StackKey, TemplateKey, PipelineKey}
+
+// AWS tag limits, automatic tags are counted too
+// https://docs.aws.amazon.com/tag-editor/latest/userguide/tagging.html
+const (
+	awsMaxTags        = 50
+	awsMaxKeyLength   = 128
+	awsMaxValueLength = 256
+)
+
+// awsCharset matches the characters that AWS accepts in tag keys and values
+var awsCharset = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+
+// GCP label limits, automatic labels are counted too
+// https://cloud.google.com/resource-manager/docs/labels-overview#requirements
+const (
+	gcpMaxLabels = 64
+	gcpMaxLength = 63
+)
+
+// gcpInvalid matches the characters that GCP does not accept in label keys and values
+var gcpInvalid = regexp.MustCompile(`[^a-z0-9_-]`)
+
+// Azure tag limits, automatic tags are counted too. Keys can be 512 characters on most resources but 128 on storage accounts.
+// https://learn.microsoft.com/en-us/azure/azure-resource-manager/management/tag-resources#limitations
+const (
+	azureMaxTags        = 50
+	azureMaxKeyLength   = 128
+	azureMaxValueLength = 256
+)
+
+// azureInvalid matches the characters that Azure does not accept in tag keys
+var azureInvalid = regexp.MustCompile(`[<>%&\\?/]`)
+
+// New returns the tags of the resources of given pipeline, stack is the pulumi stack.
+// Tags in config are returned with env, stack, template and pipeline, automatic tags that are empty are left out.
+func New(config types.Config, stack string) map[string]string {
+
+	tags := map[string]string{}
+
+	for key, value := range config.Tags {
+		tags[key] = value
+	}
+
+	for key, value := range map[string]string{
+		EnvKey:      config.Env,
+		StackKey:    stack,
+		TemplateKey: config.Template.Name,
+		PipelineKey: config.Name,
+	} {
+		if value != "" {
+			tags[key] = value
+		}
+	}
+
+	return tags
+}
+
+// Labels returns tags as GCP labels.
+// Keys and values are lowercased, characters other than letters, numbers, underscores and hyphens are replaced with underscores
+// and they are cut at 63 characters. Ex: "Cost Center: Data" is "cost_center__data"
+func Labels(tags map[string]string) map[string]string {
+
+	labels := map[string]string{}
+
+	for key, value := range tags {
+		labels[label(key)] = label(value)
+	}
+
+	return labels
+}
+
+// label converts value to a GCP label key or value
+func label(value string) string {
+
+	value = gcpInvalid.ReplaceAllString(strings.ToLower(value), "_")
+	if len(value) > gcpMaxLength {
+		value = value[:gcpMaxLength]
+	}
+
+	return value
+}
+
+// Validate checks the tags in config against the rules of the selected cloud.
+// Problems are returned by tag key, automatic tags can not be given and they are counted in the limits.
+func Validate(config types.Config) map[string]string {
+
+	problems := map[string]string{}
+
+	keys := []string{}
+	for key := range config.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if slices.Contains(Reserved, key) {
+			problems[key] = fmt.Sprintf("is added automatically, tags can not have %v", strings.Join(Reserved, ", "))
+		}
+	}
+
+	switch types.CloudMap[config.Cloud] {
+	case types.Aws:
+		validateAws(config.Tags, keys, problems)
+	case types.Gcp:
+		validateGcp(config.Tags, keys, problems)
+	case types.Azure:
+		validateAzure(config.Tags, keys, problems)
+	}
+
+	return problems
+}
+
+// validateAws adds the problems of tags on AWS
+func validateAws(tags map[string]string, keys []string, problems map[string]string) {
+
+	if len(keys)+len(Reserved) > awsMaxTags {
+		problems[keys[len(keys)-1]] = fmt.Sprintf("aws resources can have %v tags, %v of them are added automatically", awsMaxTags, len(Reserved))
+	}
+
+	for _, key := range keys {
+		value := tags[key]
+
+		if len(key) > awsMaxKeyLength {
+			problems[key] = fmt.Sprintf("key is %v characters, it can be %v on aws", len(key), awsMaxKeyLength)
+		} else if len(value) > awsMaxValueLength {
+			problems[key] = fmt.Sprintf("value is %v characters, it can be %v on aws", len(value), awsMaxValueLength)
+		} else if strings.HasPrefix(strings.ToLower(key), "aws:") {
+			problems[key] = `key can not start with "aws:" on aws`
+		} else if !awsCharset.MatchString(key) || !awsCharset.MatchString(value) {
+			problems[key] = "can have letters, numbers, spaces and _.:/=+-@ on aws"
+		}
+	}
+}
+
+// validateGcp adds the problems of tags on GCP, keys must start with a letter and be unique after they are converted to labels
+func validateGcp(tags map[string]string, keys []string, problems map[string]string) {
+
+	if len(keys)+len(Reserved) > gcpMaxLabels {
+		problems[keys[len(keys)-1]] = fmt.Sprintf("gcp resources can have %v labels, %v of them are added automatically", gcpMaxLabels, len(Reserved))
+	}
+
+	// labels gives the tag key of each label, automatic tags are given as "" since they are not in tags
+	labels := map[string]string{}
+	for _, key := range Reserved {
+		labels[key] = ""
+	}
+
+	for _, key := range keys {
+		l := label(key)
+
+		if l == "" || l[0] < 'a' || l[0] > 'z' {
+			problems[key] = "key must start with a letter on gcp"
+			continue
+		}
+
+		other, ok := labels[l]
+		if ok && other == "" && l != key {
+			problems[key] = fmt.Sprintf("key is the same label as the automatic tag %v on gcp", l)
+			continue
+		}
+
+		if ok && other != "" {
+			problems[key] = fmt.Sprintf("key is the same label as tags.%v on gcp, labels are %q", other, l)
+			continue
+		}
+
+		labels[l] = key
+	}
+}
+
+// validateAzure adds the problems of tags on Azure
+func validateAzure(tags map[string]string, keys []string, problems map[string]string) {
+
+	if len(keys)+len(Reserved) > azureMaxTags {
+		problems[keys[len(keys)-1]] = fmt.Sprintf("azure resources can have %v tags, %v of them are added automatically", azureMaxTags, len(Reserved))
+	}
+
+	for _, key := range keys {
+		value := tags[key]
+
+		if len(key) > azureMaxKeyLength {
+			problems[key] = fmt.Sprintf("key is %v characters, it can be %v on azure", len(key), azureMaxKeyLength)
+		} else if len(value) > azureMaxValueLength {
+			problems[key] = fmt.Sprintf("value is %v characters, it can be %v on azure", len(value), azureMaxValueLength)
+		} else if azureInvalid.MatchString(key) {
+			problems[key] = `key can not have <>%&\?/ on azure`
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package tags
+
+import (
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"testing"
+)
+
+type tagsTestSuite struct {
+	suite.Suite
+}
+
+func (ts *tagsTestSuite) TestNewAddsAutomaticTags() {
+	config := types.Config{
+		Name:     "studio-a",
+		Env:      "prod",
+		Template: types.Template{Name: "data-pipeline"},
+		Tags:     map[string]string{"team": "data"},
+	}
+
+	ts.Equal(map[string]string{
+		"team":     "data",
+		"env":      "prod",
+		"stack":    "game-prod",
+		"template": "data-pipeline",
+		"pipeline": "studio-a",
+	}, New(config, "game-prod"))
+
+	// empty automatic tags are left out
+	ts.Equal(map[string]string{"stack": "dev"}, New(types.Config{}, "dev"))
+}
+
+func (ts *tagsTestSuite) TestLabels() {
+	labels := Labels(map[string]string{
+		"Cost Center": "Games: Mobile",
+		"owner":       "data@ptemplate.io",
+		"long":        strings.Repeat("a", 70),
+	})
+
+	ts.Equal(map[string]string{
+		"cost_center": "games__mobile",
+		"owner":       "data_ptemplate_io",
+		"long":        strings.Repeat("a", 63),
+	}, labels)
+}
+
+func (ts *tagsTestSuite) TestValidate() {
+	ts.Empty(Validate(types.Config{Cloud: "aws", Tags: map[string]string{"Cost Center": "games/mobile"}}))
+	ts.Empty(Validate(types.Config{Cloud: "gcp", Tags: map[string]string{"Cost Center": "games/mobile"}}))
+
+	ts.Equal(map[string]string{
+		"owner": "value is 300 characters, it can be 256 on aws",
+		"team":  "can have letters, numbers, spaces and _.:/=+-@ on aws",
+	}, Validate(types.Config{Cloud: "aws", Tags: map[string]string{"owner": strings.Repeat("a", 300), "team": "data#games"}}))
+
+	ts.Equal(map[string]string{
+		"_team": "key must start with a letter on gcp",
+		"Stack": "key is the same label as the automatic tag stack on gcp",
+	}, Validate(types.Config{Cloud: "gcp", Tags: map[string]string{"_team": "data", "Stack": "games"}}))
+
+	ts.Empty(Validate(types.Config{Cloud: "azure", Tags: map[string]string{"Cost Center": "games/mobile"}}))
+
+	ts.Equal(map[string]string{
+		"cost/center": "key can not have <>%&\\?/ on azure",
+		"owner":       "value is 300 characters, it can be 256 on azure",
+	}, Validate(types.Config{Cloud: "azure", Tags: map[string]string{"cost/center": "data", "owner": strings.Repeat("a", 300)}}))
+}
+
+func TestRunTagsSuite(t *testing.T) {
+	suite.Run(t, &tagsTestSuite{})
+}
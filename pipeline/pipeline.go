@@ -0,0 +1,148 @@
+// Package pipeline lets other modules add instructions to template.instructions without forking the template.
+// An instruction is registered with Register and runs on the Cloud of the pipeline, where the resources and references that
+// the other instructions created can be used. The module runs the program with ptemplate.Run from its own main.
+package pipeline
+
+import (
+	"fmt"
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Cloud represents the methods that needs implement on selected cloud
+// Each method do same job on different cloud.
+// Ex: If selected cloud is AWS, CreateStorage method will be created the S3 bucket according to given values.
+// If selected cloud is GCP, CreateStorage method will be created the Cloud Storage according to given values.
+// If selected cloud is Azure, CreateStorage method will be created the Storage Account according to given values.
+type Cloud interface {
+	CreateStorage() error
+	CreateDWH() error
+	CreateStream() error
+	CreateApiGateway() error
+	CreateVpc() error
+	ConfigureIAM() error
+	CreateFunction() error
+	CreateIdentityManagement() error
+	Context() *pulumi.Context
+	Config() types.Config
+	Outputs() pulumi.Map
+	// Resources returns the main resource of every kind that the instructions created, such as the bucket for types.StorageResource.
+	// Kinds that are not created yet are left out, so are roles since there are several of them.
+	Resources() map[types.Resource]pulumi.Resource
+	// References returns the values that can be used in config as ${outputs.<name>}, such as outputs.s3.arn.
+	References() map[string]pulumi.StringOutput
+}
+
+// Instruction represents a named step that can be given in template.instructions.
+// Built-in instructions are registered by the template, other modules can add their own with Register.
+// Ex:
+//
+//	pipeline.Register(pipeline.Instruction{
+//		Name:        "createMonitoring",
+//		Description: "Creates alarms for the stream",
+//		Clouds:      []types.CloudProvider{types.Aws},
+//		Prerequisites: func(types.CloudProvider, types.Config) types.Dependency {
+//			return types.Dependency{Needs: []types.Resource{types.StreamResource}}
+//		},
+//		Run: func(c pipeline.Cloud) error {
+//			stream := c.Resources()[types.StreamResource]
+//			...
+//		},
+//	})
+type Instruction struct {
+	Name        string
+	Description string
+	// Clouds gives the clouds that the instruction can be executed on.
+	Clouds []types.CloudProvider
+	// Prerequisites returns what the instruction needs and produces on given cloud according to given values.
+	// It may be nil if the instruction neither needs nor produces anything.
+	Prerequisites func(cloud types.CloudProvider, config types.Config) types.Dependency
+	// Run executes the instruction on the selected cloud.
+	Run func(c Cloud) error
+}
+
+// Supports reports whether the instruction can be executed on given cloud.
+func (i Instruction) Supports(cloud types.CloudProvider) bool {
+	for _, c := range i.Clouds {
+		if c == cloud {
+			return true
+		}
+	}
+	return false
+}
+
+// Dependency returns prerequisites of the instruction on given cloud.
+func (i Instruction) Dependency(cloud types.CloudProvider, config types.Config) types.Dependency {
+	if i.Prerequisites == nil {
+		return types.Dependency{}
+	}
+	return i.Prerequisites(cloud, config)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Instruction{}
+)
+
+// Register makes an instruction available by its name in template.instructions.
+// It panics if the name is empty, Run is nil or an instruction is already registered with the same name.
+func Register(instruction Instruction) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if instruction.Name == "" {
+		panic("pipeline: instruction name is empty")
+	}
+	if instruction.Run == nil {
+		panic(fmt.Sprintf("pipeline: instruction %v has no Run func", instruction.Name))
+	}
+	if _, ok := registry[instruction.Name]; ok {
+		panic(fmt.Sprintf("pipeline: instruction %v is registered twice", instruction.Name))
+	}
+
+	registry[instruction.Name] = instruction
+}
+
+// Instructions returns registered instructions sorted by name.
+func Instructions() []Instruction {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	instructions := make([]Instruction, 0, len(registry))
+	for _, instruction := range registry {
+		instructions = append(instructions, instruction)
+	}
+
+	sort.Slice(instructions, func(i, j int) bool {
+		return instructions[i].Name < instructions[j].Name
+	})
+
+	return instructions
+}
+
+// Lookup returns the registered instruction with given name for given cloud.
+// The error lists the valid instruction names if the name is not registered.
+func Lookup(name string, cloud types.CloudProvider) (Instruction, error) {
+	registryMu.RLock()
+	instruction, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		names := []string{}
+		for _, i := range Instructions() {
+			if i.Supports(cloud) {
+				names = append(names, i.Name)
+			}
+		}
+		return Instruction{}, fmt.Errorf("instruction %v is not defined, valid instructions for %v are: %v", name, cloud, strings.Join(names, ", "))
+	}
+
+	if !instruction.Supports(cloud) {
+		return Instruction{}, fmt.Errorf("instruction %v is not supported on %v", name, cloud)
+	}
+
+	return instruction, nil
+}
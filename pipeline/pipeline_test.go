@@ -0,0 +1,59 @@
+package pipeline
+
+import (
+	"github.com/cemayan/pulumi-template/types"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type pipelineTestSuite struct {
+	suite.Suite
+}
+
+func (ts *pipelineTestSuite) TearDownTest() {
+	registryMu.Lock()
+	delete(registry, "createMonitoring")
+	registryMu.Unlock()
+}
+
+func (ts *pipelineTestSuite) TestLookupUnsupportedCloud() {
+	Register(Instruction{
+		Name:   "createMonitoring",
+		Clouds: []types.CloudProvider{types.Gcp},
+		Run:    func(c Cloud) error { return nil },
+	})
+
+	_, err := Lookup("createMonitoring", types.Aws)
+
+	ts.EqualError(err, "instruction createMonitoring is not supported on aws")
+}
+
+func (ts *pipelineTestSuite) TestLookupUnknownInstruction() {
+	Register(Instruction{
+		Name:   "createMonitoring",
+		Clouds: []types.CloudProvider{types.Aws},
+		Run:    func(c Cloud) error { return nil },
+	})
+
+	_, err := Lookup("createAlarms", types.Aws)
+
+	ts.EqualError(err, "instruction createAlarms is not defined, valid instructions for aws are: createMonitoring")
+}
+
+func (ts *pipelineTestSuite) TestRegisterTwicePanics() {
+	Register(Instruction{Name: "createMonitoring", Run: func(c Cloud) error { return nil }})
+
+	ts.Panics(func() {
+		Register(Instruction{Name: "createMonitoring", Run: func(c Cloud) error { return nil }})
+	})
+}
+
+func (ts *pipelineTestSuite) TestRegisterWithoutRunPanics() {
+	ts.Panics(func() {
+		Register(Instruction{Name: "createMonitoring"})
+	})
+}
+
+func TestRunPipelineSuite(t *testing.T) {
+	suite.Run(t, &pipelineTestSuite{})
+}
@@ -0,0 +1,18 @@
+// Package ptemplate runs the pulumi program of the template from other modules.
+// A module that registers its own instructions with pipeline.Register runs the program from its main. Ex:
+//
+//	func main() {
+//		pipeline.Register(pipeline.Instruction{Name: "createMonitoring", ...})
+//		pulumi.Run(ptemplate.Run)
+//	}
+package ptemplate
+
+import (
+	"github.com/cemayan/pulumi-template/internal/program"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Run is the pulumi program, it creates the pipelines in the config that is given with config:path.
+func Run(ctx *pulumi.Context) error {
+	return program.Run(ctx)
+}
@@ -1,18 +1,60 @@
 package types
 
 // Config represents the config yaml
+// A config either defines a single pipeline or lists several pipelines in Pipelines, each entry is a full pipeline definition.
 type Config struct {
-	Env        string     `mapstructure:"env"`
-	Cloud      string     `mapstructure:"cloud"`
-	Template   Template   `mapstructure:"template"`
-	Iam        Iam        `mapstructure:"iam"`
-	Storage    Storage    `mapstructure:"storage"`
-	Stream     Stream     `mapstructure:"stream"`
-	Dwh        Dwh        `mapstructure:"dwh"`
-	APIGateway APIGateway `mapstructure:"api_gateway"`
-	Function   Function   `mapstructure:"function"`
-	Authorizer Authorizer `mapstructure:"authorizer"`
-	Idp        Idp        `mapstructure:"idp"`
+	Name          string            `mapstructure:"name"`
+	Env           string            `mapstructure:"env"`
+	Cloud         string            `mapstructure:"cloud"`
+	ResourceGroup string            `mapstructure:"resource_group"`
+	Template      Template          `mapstructure:"template"`
+	Iam           Iam               `mapstructure:"iam"`
+	Storage       Storage           `mapstructure:"storage"`
+	Stream        Stream            `mapstructure:"stream"`
+	Dwh           Dwh               `mapstructure:"dwh"`
+	APIGateway    APIGateway        `mapstructure:"api_gateway"`
+	Function      Function          `mapstructure:"function"`
+	Authorizer    Authorizer        `mapstructure:"authorizer"`
+	Idp           Idp               `mapstructure:"idp"`
+	Naming        Naming            `mapstructure:"naming"`
+	Tags          map[string]string `mapstructure:"tags"`
+	// Policies overrides the levels of the policy checks by policy name, levels are advisory, mandatory and disabled.
+	// It is read at the top level of the config, env overlays can override it.
+	Policies map[string]string `mapstructure:"policies"`
+	// Estimates gives the traffic assumptions of the cost estimate, entries of pipelines use the values of the top level that they do not give.
+	Estimates Estimates `mapstructure:"estimates"`
+	Pipelines []Config  `mapstructure:"pipelines"`
+}
+
+// Estimates represents the monthly traffic assumptions of the cost estimate, defaults of the estimator are used for the values that are not given.
+type Estimates struct {
+	// IngestGB is the data that the stream takes in, StorageGB is the data that is kept in object storage and DwhStorageGB is the data that is kept in BigQuery.
+	IngestGB     float64 `mapstructure:"ingest_gb"`
+	StorageGB    float64 `mapstructure:"storage_gb"`
+	DwhStorageGB float64 `mapstructure:"dwh_storage_gb"`
+	ApiRequests  float64 `mapstructure:"api_requests"`
+	// FunctionMemoryMB is used on AWS and Azure, function.service_conf.available_mem is used on GCP.
+	FunctionInvocations float64 `mapstructure:"function_invocations"`
+	FunctionDurationMs  float64 `mapstructure:"function_duration_ms"`
+	FunctionMemoryMB    float64 `mapstructure:"function_memory_mb"`
+}
+
+// Naming represents how the physical names of resources are derived from the names in config.
+// Pattern can have {project}, {env}, {stack}, {pipeline} and {component}, component is the name in config. Ex: {project}-{env}-{component}
+type Naming struct {
+	Pattern  string            `mapstructure:"pattern"`
+	Patterns map[string]string `mapstructure:"patterns"`
+	Project  string            `mapstructure:"project"`
+	Suffix   bool              `mapstructure:"suffix"`
+}
+
+// LogicalName returns the pulumi logical name of a resource
+// If the config is an entry of pipelines, name is prefixed with the pipeline name so resources of different pipelines do not collide.
+func (c Config) LogicalName(name string) string {
+	if c.Name == "" {
+		return name
+	}
+	return c.Name + "-" + name
 }
 
 type Idp struct {
@@ -46,12 +88,18 @@ type UserPool struct {
 	User       User       `mapstructure:"user"`
 	UserClient UserClient `mapstructure:"user_client"`
 	UserDomain UserDomain `mapstructure:"user_domain"`
+	// Arn is the ARN of an existing user pool that the authorizer uses, it can be a stack reference such as stackref://acme/platform/prod#userPoolArn.
+	Arn string `mapstructure:"arn"`
+	// ExistingId is the id of a user pool that is read instead of created, ImportId is the id of a user pool that is adopted into the stack.
+	ExistingId string `mapstructure:"existing_id"`
+	ImportId   string `mapstructure:"import_id"`
 }
 
 type Authorizer struct {
 	UserPool UserPool `mapstructure:"user_pool"`
 	Name     string   `mapstructure:"name"`
 	Type     string   `mapstructure:"type"`
+	Options  Options  `mapstructure:"options"`
 }
 
 type ServiceAcc struct {
@@ -76,12 +124,13 @@ type Source struct {
 	OutputPath string  `mapstructure:"output_path"`
 }
 type Build struct {
-	Runtime    string            `mapstructure:"runtime"`
-	Handler    string            `mapstructure:"handler"`
-	EntryPoint string            `mapstructure:"entry_point"`
-	DockerRepo string            `mapstructure:"docker_repo"`
-	Source     *Source           `mapstructure:"source"`
-	Envs       map[string]string `mapstructure:"envs"`
+	Runtime        string            `mapstructure:"runtime"`
+	RuntimeVersion string            `mapstructure:"runtime_version"`
+	Handler        string            `mapstructure:"handler"`
+	EntryPoint     string            `mapstructure:"entry_point"`
+	DockerRepo     string            `mapstructure:"docker_repo"`
+	Source         *Source           `mapstructure:"source"`
+	Envs           map[string]string `mapstructure:"envs"`
 }
 type Trigger struct {
 	EventType string `mapstructure:"event_type"`
@@ -94,6 +143,7 @@ type Function struct {
 	Build       Build       `mapstructure:"build"`
 	Trigger     *Trigger    `mapstructure:"trigger"`
 	ServiceConf ServiceConf `mapstructure:"service_conf"`
+	Options     Options     `mapstructure:"options"`
 }
 
 type BigQuery struct {
@@ -114,16 +164,33 @@ type Redshift struct {
 	SkipSnapshot  bool   `mapstructure:"skip_snapshot"`
 	Sql           string `mapstructure:"sql"`
 	PublicAccess  bool   `mapstructure:"public_access"`
+	// ExistingId is the identifier of a cluster that is read instead of created, ImportId is the identifier of a cluster that is adopted into the stack.
+	ExistingId string `mapstructure:"existing_id"`
+	ImportId   string `mapstructure:"import_id"`
+}
+
+type Adx struct {
+	Cluster          string `mapstructure:"cluster"`
+	Sku              string `mapstructure:"sku"`
+	Capacity         int    `mapstructure:"capacity"`
+	Database         string `mapstructure:"database"`
+	Table            string `mapstructure:"table"`
+	HotCachePeriod   string `mapstructure:"hot_cache_period"`
+	SoftDeletePeriod string `mapstructure:"soft_delete_period"`
+	Schema           string `mapstructure:"schema"`
 }
 
 type Dwh struct {
 	BigQuery BigQuery `mapstructure:"bq"`
 	Redshift Redshift `mapstructure:"redshift"`
+	Adx      Adx      `mapstructure:"adx"`
+	Options  Options  `mapstructure:"options"`
 }
 
 type Template struct {
 	Name         string   `mapstructure:"name"`
 	Instructions []string `mapstructure:"instructions"`
+	OnError      string   `mapstructure:"on_error"`
 }
 
 type Roles struct {
@@ -134,6 +201,7 @@ type Roles struct {
 	ForceDetachPolicies bool   `mapstructure:"force_detach_policies"`
 	AssumePolicy        string `mapstructure:"assume_policy"`
 	InlinePolicy        string `mapstructure:"inline_policy"`
+	Scope               string `mapstructure:"scope"`
 }
 type Iam struct {
 	ServiceAcc *ServiceAcc `mapstructure:"service_acc"`
@@ -155,12 +223,21 @@ type Storage struct {
 	Location     string `mapstructure:"location"`
 	Bucket       Bucket `mapstructure:"bucket"`
 	ForceDestroy bool   `mapstructure:"force_destroy"`
+	AccountTier  string `mapstructure:"account_tier"`
+	Replication  string `mapstructure:"replication"`
+	// ExistingId is the id of a bucket or storage account that is read instead of created,
+	// ImportId is the id of a bucket or storage account that is adopted into the stack.
+	ExistingId string  `mapstructure:"existing_id"`
+	ImportId   string  `mapstructure:"import_id"`
+	Options    Options `mapstructure:"options"`
 }
 type S3Conf struct {
 	BufferingSize     int    `mapstructure:"buffering_size"`
 	BufferingInterval int    `mapstructure:"buffering_interval"`
 	PartitionEnabled  bool   `mapstructure:"partition_enabled"`
 	S3Prefix          string `mapstructure:"s3_prefix"`
+	// BucketArn is the ARN of an existing bucket that the stream writes to, it can be a stack reference.
+	BucketArn string `mapstructure:"bucket_arn"`
 }
 
 type RedshiftConf struct {
@@ -173,6 +250,10 @@ type RedshiftConf struct {
 
 type Topic struct {
 	Name string `mapstructure:"name"`
+	// ExistingId is the id of a topic that is read instead of created such as projects/<project>/topics/<name>,
+	// ImportId is the id of a topic that is adopted into the stack.
+	ExistingId string `mapstructure:"existing_id"`
+	ImportId   string `mapstructure:"import_id"`
 }
 
 type CloudStorageConf struct {
@@ -191,12 +272,36 @@ type PubSubConf struct {
 	Subscription Subscription `mapstructure:"subscription"`
 }
 
+type EventHubNamespace struct {
+	Name     string `mapstructure:"name"`
+	Sku      string `mapstructure:"sku"`
+	Capacity int    `mapstructure:"capacity"`
+}
+
+type Capture struct {
+	Encoding          string `mapstructure:"encoding"`
+	Interval          int    `mapstructure:"interval"`
+	SizeLimit         int    `mapstructure:"size_limit"`
+	ArchiveNameFormat string `mapstructure:"archive_name_format"`
+}
+
+type EventHubConf struct {
+	Namespace        EventHubNamespace `mapstructure:"namespace"`
+	PartitionCount   int               `mapstructure:"partition_count"`
+	MessageRetention int               `mapstructure:"message_retention"`
+	Capture          Capture           `mapstructure:"capture"`
+}
+
 type Stream struct {
 	Name         string       `mapstructure:"name"`
 	Destination  string       `mapstructure:"destination"`
 	PubSubConf   PubSubConf   `mapstructure:"pubsub_conf"`
 	S3Conf       S3Conf       `mapstructure:"s3Config"`
 	RedshiftConf RedshiftConf `mapstructure:"redshift_conf"`
+	EventHubConf EventHubConf `mapstructure:"eventhub_conf"`
+	// RoleArn is the ARN of an existing role that the stream assumes, it can be a stack reference.
+	RoleArn string  `mapstructure:"role_arn"`
+	Options Options `mapstructure:"options"`
 }
 type ResponseParams struct {
 	Key string `mapstructure:"key"`
@@ -245,10 +350,33 @@ type Routes struct {
 	Integrations []Integrations `mapstructure:"integrations"`
 }
 type APIGateway struct {
-	Name         string   `mapstructure:"name"`
-	Stage        string   `mapstructure:"stage"`
-	DeploymentId int      `mapstructure:"deployment_id"`
-	Region       string   `mapstructure:"region"`
-	Routes       []Routes `mapstructure:"routes"`
-	OpenApiSpec  string   `mapstructure:"open_api_spec"`
+	Name           string   `mapstructure:"name"`
+	Stage          string   `mapstructure:"stage"`
+	DeploymentId   int      `mapstructure:"deployment_id"`
+	Region         string   `mapstructure:"region"`
+	Routes         []Routes `mapstructure:"routes"`
+	OpenApiSpec    string   `mapstructure:"open_api_spec"`
+	Sku            string   `mapstructure:"sku"`
+	PublisherName  string   `mapstructure:"publisher_name"`
+	PublisherEmail string   `mapstructure:"publisher_email"`
+	// RoleArn is the ARN of an existing role that integrations assume, it can be a stack reference.
+	RoleArn string  `mapstructure:"role_arn"`
+	Options Options `mapstructure:"options"`
+}
+
+// Options represents the pulumi resource options of the resources of a component.
+// Protect is a pointer so that the default of the env, such as protecting storage in prod, can be turned off.
+type Options struct {
+	Protect             *bool    `mapstructure:"protect"`
+	RetainOnDelete      bool     `mapstructure:"retain_on_delete"`
+	IgnoreChanges       []string `mapstructure:"ignore_changes"`
+	DeleteBeforeReplace bool     `mapstructure:"delete_before_replace"`
+	Timeouts            Timeouts `mapstructure:"timeouts"`
+}
+
+// Timeouts represents the custom timeouts of the resources such as 30m
+type Timeouts struct {
+	Create string `mapstructure:"create"`
+	Update string `mapstructure:"update"`
+	Delete string `mapstructure:"delete"`
 }
@@ -0,0 +1,55 @@
+package types
+
+// Auth types of ingest.auth.type
+const (
+	// AuthNone means requests are not authenticated.
+	AuthNone = "none"
+	// AuthIam means requests are signed with cloud credentials, such as SigV4 on AWS or an identity token of a service account on GCP.
+	AuthIam = "iam"
+	// AuthJwt means requests have a token of auth.issuer for auth.client_id.
+	AuthJwt = "jwt"
+	// AuthKey means requests have a key of the function, such as the x-functions-key header on Azure.
+	AuthKey = "key"
+)
+
+// StackOutputs represents the outputs that every cloud exports with the same keys, ex: ingest.url is the url that events are sent to.
+// Outputs of a pipeline that is an entry of pipelines are under the pipeline name. Values of resources that are not created are left out.
+type StackOutputs struct {
+	Ingest  IngestOutputs  `json:"ingest" mapstructure:"ingest"`
+	Auth    AuthOutputs    `json:"auth" mapstructure:"auth"`
+	Storage StorageOutputs `json:"storage" mapstructure:"storage"`
+	Dwh     DwhOutputs     `json:"dwh" mapstructure:"dwh"`
+	Stream  StreamOutputs  `json:"stream" mapstructure:"stream"`
+}
+
+// IngestOutputs represents the endpoint that events are sent to, the API gateway if it is created, otherwise the function
+type IngestOutputs struct {
+	URL  string            `json:"url" mapstructure:"url"`
+	Auth IngestAuthOutputs `json:"auth" mapstructure:"auth"`
+}
+
+// IngestAuthOutputs represents how requests to the ingest url are authenticated, Type is one of none, iam, jwt and key
+type IngestAuthOutputs struct {
+	Type string `json:"type" mapstructure:"type"`
+}
+
+// AuthOutputs represents the identity provider that gives the tokens if ingest.auth.type is jwt
+type AuthOutputs struct {
+	Issuer   string `json:"issuer" mapstructure:"issuer"`
+	ClientID string `json:"client_id" mapstructure:"client_id"`
+}
+
+// StorageOutputs represents the storage, Uri is such as s3://bucket, gs://bucket or https://account.blob.core.windows.net/container
+type StorageOutputs struct {
+	URI string `json:"uri" mapstructure:"uri"`
+}
+
+// DwhOutputs represents the table that events are written to, ex: project.dataset.table on BigQuery
+type DwhOutputs struct {
+	Table string `json:"table" mapstructure:"table"`
+}
+
+// StreamOutputs represents the stream, Id is the ARN of the Firehose stream, the Pub/Sub topic id or the Event Hub id
+type StreamOutputs struct {
+	ID string `json:"id" mapstructure:"id"`
+}
@@ -31,3 +31,28 @@ type IamPolicies struct {
 	InlinePolicy interface{} `mapstructure:"inlinePolicy" yaml:"inlinePolicy"`
 	AssumePolicy interface{} `mapstructure:"assumePolicy" yaml:"assumePolicy"`
 }
+
+// Resource represents an infrastructure piece that an instruction needs or produces.
+type Resource string
+
+const (
+	StorageResource  Resource = "storage"
+	DwhResource      Resource = "dwh"
+	StreamResource   Resource = "stream"
+	RolesResource    Resource = "roles"
+	UserPoolResource Resource = "user pool"
+	FunctionResource Resource = "function"
+)
+
+// Dependency represents what an instruction needs before it runs and what it produces.
+type Dependency struct {
+	Needs    []Resource
+	Produces []Resource
+}
+
+// Values of template.on_error in yaml.
+// FailFast stops at the first failed instruction, ContinueOnError runs every instruction and reports all failures at the end.
+const (
+	FailFast        = "fail_fast"
+	ContinueOnError = "continue"
+)